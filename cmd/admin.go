@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"scgptEval/logic"
+	"scgptEval/models"
+)
+
+// setRole 设置用户角色，用于创建首个管理员(此时还没有管理员可以调用设置角色的接口)
+// 用户需先通过注册接口注册，角色变化后其已登录的会话被吊销
+// 用法: ./scgpt_eval admin -username alice [-role admin|reviewer|annotator]
+func setRole(args []string) error {
+	fs := flag.NewFlagSet("admin", flag.ContinueOnError)
+	username := fs.String("username", "", "用户名")
+	role := fs.String("role", models.RoleAdmin, "设置的角色admin、reviewer或annotator")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" {
+		return errors.New("-username is required")
+	}
+	switch *role {
+	case models.RoleAdmin, models.RoleReviewer, models.RoleAnnotator:
+	default:
+		return fmt.Errorf("invalid role %q", *role)
+	}
+	userID, err := logic.SetUserRoleByName(*username, *role)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "user %s (%d) is now %s\n", *username, userID, *role)
+	return nil
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+)
+
+// cmd 命令行子命令，用法: ./scgpt_eval <子命令> [参数]
+// 不带子命令(或以-开头的参数)启动时仍为web服务
+
+// commands 子命令名 -> 执行函数
+var commands = map[string]func(args []string) error{
+	"import": importQuizzes,
+	"export": exportRecords,
+	"admin":  setRole,
+}
+
+// IsCommand 判断参数是否为已注册的子命令
+func IsCommand(name string) bool {
+	_, ok := commands[name]
+	return ok
+}
+
+// Run 执行子命令
+func Run(name string, args []string) error {
+	run, ok := commands[name]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for n := range commands {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown command %q, available: %v", name, names)
+	}
+	return run(args)
+}
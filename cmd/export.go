@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"scgptEval/logic"
+	"scgptEval/models"
+)
+
+// exportRecords 导出做题记录数据集
+// 用法: ./scgpt_eval export [-o records.jsonl] [-format jsonl|csv] [-start 2023-07-01] [-end 2023-07-31]
+// [-type 1] [-user 123] [-pseudonymous]
+// dev模式下日志同样会输出到标准输出，此时应使用-o指定输出文件
+func exportRecords(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	out := fs.String("o", "", "输出文件路径，缺省时输出到标准输出")
+	format := fs.String("format", "", "文件格式jsonl或csv，缺省时按输出文件扩展名推断，否则为jsonl")
+	start := fs.String("start", "", "起始时间(包含)，日期或RFC3339格式")
+	end := fs.String("end", "", "结束时间，日期格式时包含当天")
+	quizType := fs.String("type", "", "题目类型")
+	userID := fs.Int64("user", 0, "用户id")
+	campaignID := fs.Int64("campaign", 0, "评测项目id")
+	pseudonymous := fs.Bool("pseudonymous", false, "假名化用户id，密钥从环境变量SCGPTEVAL_PSEUDONYM_SECRET读取")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format == "" {
+		if *format = logic.FormatFromFilename(*out); *format == "" {
+			*format = logic.FormatJSONL
+		}
+	}
+	filter := &models.RecordFilter{QuizType: *quizType, UserID: *userID, CampaignID: *campaignID}
+	var err error
+	if filter.Start, err = logic.ParseTimeParam(*start, false); err != nil {
+		return err
+	}
+	if filter.End, err = logic.ParseTimeParam(*end, true); err != nil {
+		return err
+	}
+	if err = logic.CheckExportParams(*format, *pseudonymous); err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	count, err := logic.ExportRecords(w, *format, filter, *pseudonymous)
+	fmt.Fprintf(os.Stderr, "exported %d records\n", count)
+	return err
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"scgptEval/logic"
+)
+
+// importQuizzes 批量导入题目
+// 用法: ./scgpt_eval import -file quizzes.jsonl [-format jsonl|csv] [-dry-run] [-batch 500]
+func importQuizzes(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	file := fs.String("file", "", "导入文件路径(.jsonl或.csv)")
+	format := fs.String("format", "", "文件格式jsonl或csv，缺省时按扩展名推断")
+	dryRun := fs.Bool("dry-run", false, "只校验不写入")
+	batch := fs.Int("batch", 500, "每批写入的条数")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("-file is required")
+	}
+	if *format == "" {
+		*format = logic.FormatFromFilename(*file)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	report, err := logic.ImportQuizzes(f, *format, *dryRun, *batch)
+	if report != nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	}
+	return err
+}
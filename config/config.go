@@ -3,6 +3,8 @@ package config
 import (
 	"github.com/fsnotify/fsnotify"
 	"go.uber.org/zap"
+	"os"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -12,15 +14,23 @@ var Conf = new(AppConfig)
 
 // AppConfig 使用结构体变量保存配置信息；相比于直接用viper来保存，这种方式对程序员更友好
 type AppConfig struct {
-	Name         string `mapstructure:"name"`
-	Mode         string `mapstructure:"mode"`
-	Version      string `mapstructure:"version"`
-	StartTime    string `mapstructure:"start_time"`
-	MachineID    int64  `mapstructure:"machine_id"`
-	Port         int    `mapstructure:"port"`
-	*LogConfig   `mapstructure:"log"`
-	*MySQLConfig `mapstructure:"mysql"`
-	*RedisConfig `mapstructure:"redis"`
+	Name             string `mapstructure:"name"`
+	Mode             string `mapstructure:"mode"`
+	Version          string `mapstructure:"version"`
+	StartTime        string `mapstructure:"start_time"`
+	MachineID        int64  `mapstructure:"machine_id"`
+	Port             int    `mapstructure:"port"`
+	*LogConfig       `mapstructure:"log"`
+	*MySQLConfig     `mapstructure:"mysql"`
+	*RedisConfig     `mapstructure:"redis"`
+	*PasswordConfig  `mapstructure:"password"`
+	*AuthConfig      `mapstructure:"auth"`
+	*QuizConfig      `mapstructure:"quiz"`
+	*RatingConfig    `mapstructure:"rating"`
+	*TrustConfig     `mapstructure:"trust"`
+	*AnalyticsConfig `mapstructure:"analytics"`
+	*ConsensusConfig `mapstructure:"consensus"`
+	*RankingConfig   `mapstructure:"ranking"`
 }
 
 type LogConfig struct {
@@ -50,6 +60,277 @@ type RedisConfig struct {
 	PoolSize int    `mapstructure:"pool_size"`
 }
 
+type PasswordConfig struct {
+	Algorithm     string `mapstructure:"algorithm"` // argon2id 或 bcrypt
+	BcryptCost    int    `mapstructure:"bcrypt_cost"`
+	Argon2Memory  uint32 `mapstructure:"argon2_memory"` // 单位KiB
+	Argon2Time    uint32 `mapstructure:"argon2_time"`
+	Argon2Threads uint8  `mapstructure:"argon2_threads"`
+}
+
+type AuthConfig struct {
+	SessionMode string `mapstructure:"session_mode"` // single: 单一用户登录，新登录会挤掉旧会话；multi: 允许多端登录
+	MaxSessions int    `mapstructure:"max_sessions"` // multi模式下每个用户最多保留的会话数，0表示不限制
+}
+
+// SessionLimit 每个用户最多保留的会话数，0表示不限制
+func (c *AuthConfig) SessionLimit() int {
+	if c == nil || c.SessionMode != "multi" {
+		return 1
+	}
+	return c.MaxSessions
+}
+
+// PseudonymSecretEnv 保存假名化密钥的环境变量
+const PseudonymSecretEnv = "SCGPTEVAL_PSEUDONYM_SECRET"
+
+// PseudonymSecret 导出数据集时对用户id做假名化的HMAC密钥，只从环境变量读取，不写入配置文件，未设置时返回空串
+func PseudonymSecret() string {
+	return os.Getenv(PseudonymSecretEnv)
+}
+
+type QuizConfig struct {
+	Source           string  `mapstructure:"source"`            // 出题数据源: redis(默认) 或 mysql
+	Strategy         string  `mapstructure:"strategy"`          // 默认出题策略: random(默认)、balanced、stratified、priority、sequential
+	Seed             int64   `mapstructure:"seed"`              // sequential策略的默认种子
+	TargetJudgements int     `mapstructure:"target_judgements"` // 题目未单独设置时默认需要的作答数，达到后不再出题，0表示不限
+	GoldRatio        float64 `mapstructure:"gold_ratio"`        // 每次出题中混入金标准题的比例，0表示不混入
+	RevisionWindow   int     `mapstructure:"revision_window"`   // 提交后可修改答案的时限(秒)，0表示不允许修改
+	*LeaseConfig     `mapstructure:"lease"`
+}
+
+type LeaseConfig struct {
+	Enabled bool `mapstructure:"enabled"` // 出题时为用户预留题目，避免多人同时拿到同一道题；目标作答数为0(不限)的题目不受名额限制，租约不具排他性
+	TTL     int  `mapstructure:"ttl"`     // 租约有效期(秒)，到期未提交的题目回到题池
+}
+
+// QuizSource 出题数据源，未配置时默认为redis
+func (c *QuizConfig) QuizSource() string {
+	if c == nil || c.Source == "" {
+		return "redis"
+	}
+	return c.Source
+}
+
+// QuizStrategy 出题策略，未配置时默认为random
+func (c *QuizConfig) QuizStrategy() string {
+	if c == nil || c.Strategy == "" {
+		return "random"
+	}
+	return c.Strategy
+}
+
+// Target 题目未单独设置时默认需要的作答数，0表示不限
+func (c *QuizConfig) Target() int {
+	if c == nil || c.TargetJudgements < 0 {
+		return 0
+	}
+	return c.TargetJudgements
+}
+
+// GoldShare 每次出题中混入金标准题的比例，取值范围[0, 1]
+func (c *QuizConfig) GoldShare() float64 {
+	if c == nil || c.GoldRatio <= 0 {
+		return 0
+	}
+	if c.GoldRatio > 1 {
+		return 1
+	}
+	return c.GoldRatio
+}
+
+// LeaseTTL 题目租约有效期，未启用租约时返回0
+func (c *QuizConfig) LeaseTTL() time.Duration {
+	if c == nil || c.LeaseConfig == nil || !c.LeaseConfig.Enabled {
+		return 0
+	}
+	if c.LeaseConfig.TTL <= 0 {
+		return 30 * time.Minute
+	}
+	return time.Duration(c.LeaseConfig.TTL) * time.Second
+}
+
+// RevisionTimeout 提交后可修改答案的时限，未配置时返回0表示不允许修改
+func (c *QuizConfig) RevisionTimeout() time.Duration {
+	if c == nil || c.RevisionWindow <= 0 {
+		return 0
+	}
+	return time.Duration(c.RevisionWindow) * time.Second
+}
+
+type RatingConfig struct {
+	EloK            float64 `mapstructure:"elo_k"`            // Elo每场比较的最大调整幅度，默认4
+	BootstrapRounds int     `mapstructure:"bootstrap_rounds"` // 计算置信区间的重采样次数，默认1000
+	Interval        int     `mapstructure:"interval"`         // 定期重新计算排行榜的间隔(秒)，默认600
+}
+
+// Params 排行榜计算参数，未配置的项使用默认值
+func (c *RatingConfig) Params() (eloK float64, rounds int) {
+	eloK, rounds = 4, 1000
+	if c == nil {
+		return
+	}
+	if c.EloK > 0 {
+		eloK = c.EloK
+	}
+	if c.BootstrapRounds > 0 {
+		rounds = c.BootstrapRounds
+	}
+	return
+}
+
+// RatingInterval 定期重新计算模型排行榜的间隔
+func (c *RatingConfig) RatingInterval() time.Duration {
+	if c == nil || c.Interval <= 0 {
+		return 10 * time.Minute
+	}
+	return time.Duration(c.Interval) * time.Second
+}
+
+type TrustConfig struct {
+	Enabled          bool    `mapstructure:"enabled"`           // 是否定期计算标注员信任分，并以信任分为标注结果加权
+	Interval         int     `mapstructure:"interval"`          // 计算间隔(秒)，默认3600
+	GoldWeight       float64 `mapstructure:"gold_weight"`       // 金标准题正确率的权重
+	AgreementWeight  float64 `mapstructure:"agreement_weight"`  // 与其他标注员多数答案一致率的权重
+	TimingWeight     float64 `mapstructure:"timing_weight"`     // 提交间隔正常率的权重
+	PriorWeight      float64 `mapstructure:"prior_weight"`      // 平滑用的满分虚拟样本数，证据越少信任分越接近1，默认5
+	MinInterval      int     `mapstructure:"min_interval"`      // 相邻两次提交间隔小于该值(秒)视为过快，默认3
+	RankByTrust      bool    `mapstructure:"rank_by_trust"`     // 刷题排行榜按信任分加权的刷题量排名
+	SuspendThreshold float64 `mapstructure:"suspend_threshold"` // 信任分低于该值的标注员被自动暂停，0表示不自动暂停
+	MinEvidence      int64   `mapstructure:"min_evidence"`      // 自动暂停所需的最少证据数(金标准题与参与比对的作答数之和)，默认20
+}
+
+// TrustEnabled 是否启用信任分
+func (c *TrustConfig) TrustEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// TrustInterval 信任分的计算间隔，未启用时返回0
+func (c *TrustConfig) TrustInterval() time.Duration {
+	if !c.TrustEnabled() {
+		return 0
+	}
+	if c.Interval <= 0 {
+		return time.Hour
+	}
+	return time.Duration(c.Interval) * time.Second
+}
+
+// RankingByTrust 刷题排行榜是否按信任分加权
+func (c *TrustConfig) RankingByTrust() bool {
+	return c.TrustEnabled() && c.RankByTrust
+}
+
+type AnalyticsConfig struct {
+	CacheTTL     int `mapstructure:"cache_ttl"`      // 一致性指标的缓存时间(秒)，默认600
+	MinPairItems int `mapstructure:"min_pair_items"` // 计算Cohen's kappa时一对标注员最少的共同标注题目数，默认10
+}
+
+// AnalyticsParams 一致性指标计算参数，未配置的项使用默认值
+func (c *AnalyticsConfig) AnalyticsParams() (cacheTTL time.Duration, minPairItems int) {
+	cacheTTL, minPairItems = 10*time.Minute, 10
+	if c == nil {
+		return
+	}
+	if c.CacheTTL > 0 {
+		cacheTTL = time.Duration(c.CacheTTL) * time.Second
+	}
+	if c.MinPairItems > 0 {
+		minPairItems = c.MinPairItems
+	}
+	return
+}
+
+type ConsensusConfig struct {
+	Enabled    bool `mapstructure:"enabled"`    // 是否定期聚合题目的共识标签
+	Interval   int  `mapstructure:"interval"`   // 聚合间隔(秒)，默认3600
+	MinLabels  int  `mapstructure:"min_labels"` // 答案数少于该值的题目不计算共识标签，默认1
+	Iterations int  `mapstructure:"iterations"` // Dawid–Skene EM的最大迭代轮数，默认50
+}
+
+// ConsensusInterval 共识标签的聚合间隔，未启用时返回0
+func (c *ConsensusConfig) ConsensusInterval() time.Duration {
+	if c == nil || !c.Enabled {
+		return 0
+	}
+	if c.Interval <= 0 {
+		return time.Hour
+	}
+	return time.Duration(c.Interval) * time.Second
+}
+
+// ConsensusParams 共识标签聚合参数，未配置的项使用默认值
+func (c *ConsensusConfig) ConsensusParams() (minLabels, iterations int) {
+	minLabels, iterations = 1, 50
+	if c == nil {
+		return
+	}
+	if c.MinLabels > 0 {
+		minLabels = c.MinLabels
+	}
+	if c.Iterations > 0 {
+		iterations = c.Iterations
+	}
+	return
+}
+
+type RankingConfig struct {
+	Periods   []string `mapstructure:"periods"`   // 按时间窗口统计的排行榜周期(daily、weekly、monthly)，默认全部
+	Timezone  string   `mapstructure:"timezone"`  // 划分统计窗口的时区，如Asia/Shanghai，默认为服务器本地时区
+	Retention int      `mapstructure:"retention"` // 统计窗口结束后其排行榜在redis中保留的时长(秒)，默认7天
+	Archive   bool     `mapstructure:"archive"`   // 是否定期将已结束的统计窗口的排行榜存档到mysql
+	Interval  int      `mapstructure:"interval"`  // 存档间隔(秒)，默认3600，需小于retention
+	Batch     int      `mapstructure:"batch"`     // 存档时每次从redis读取的名次数，默认1000，排行榜中的所有用户都会被存档
+}
+
+// RankingPeriods 按时间窗口统计的排行榜周期，未配置时返回nil表示全部
+func (c *RankingConfig) RankingPeriods() []string {
+	if c == nil {
+		return nil
+	}
+	return c.Periods
+}
+
+// RankingLocation 划分统计窗口的时区，未配置或无法识别时为服务器本地时区
+func (c *RankingConfig) RankingLocation() *time.Location {
+	if c == nil || c.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		zap.L().Warn("invalid ranking timezone, fallback to local", zap.String("timezone", c.Timezone), zap.Error(err))
+		return time.Local
+	}
+	return loc
+}
+
+// RankingRetention 统计窗口结束后其排行榜在redis中保留的时长
+func (c *RankingConfig) RankingRetention() time.Duration {
+	if c == nil || c.Retention <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return time.Duration(c.Retention) * time.Second
+}
+
+// ArchiveInterval 排行榜的存档间隔，未启用时返回0
+func (c *RankingConfig) ArchiveInterval() time.Duration {
+	if c == nil || !c.Archive {
+		return 0
+	}
+	if c.Interval <= 0 {
+		return time.Hour
+	}
+	return time.Duration(c.Interval) * time.Second
+}
+
+// ArchiveBatch 存档时每次从redis读取的名次数
+func (c *RankingConfig) ArchiveBatch() int64 {
+	if c == nil || c.Batch <= 0 {
+		return 1000
+	}
+	return int64(c.Batch)
+}
+
 func Init() (err error) {
 	viper.SetConfigName("config_local") // 指定配置文件名称(无扩展名)
 	viper.AddConfigPath(".")            // 查找配置文件所在的路径(使用相对路径)
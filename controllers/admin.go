@@ -0,0 +1,422 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"scgptEval/dao/mysql"
+	"scgptEval/logic"
+	"scgptEval/models"
+	"strconv"
+)
+
+// SetUserRole 设置用户角色: POST
+func SetUserRole(c *gin.Context) {
+	var req struct {
+		UserID int64  `json:"user_id,string" binding:"required"`
+		Role   string `json:"role" binding:"required,oneof=annotator reviewer admin"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	// 角色变化时用户的全部会话被吊销，需重新登录
+	if err := logic.SetUserRole(req.UserID, req.Role); err != nil {
+		if errors.Is(err, mysql.ErrorUserNotExist) {
+			ResponseError(c, CodeUserNotExist)
+			return
+		}
+		zap.L().Error("logic.SetUserRole() failed",
+			zap.Int64("user_id", req.UserID),
+			zap.String("role", req.Role), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// SetUserStatus 暂停或恢复用户: POST
+func SetUserStatus(c *gin.Context) {
+	var req struct {
+		UserID int64  `json:"user_id,string" binding:"required"`
+		Status string `json:"status" binding:"required,oneof=active suspended"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if err := logic.SetUserStatus(req.UserID, req.Status); err != nil {
+		if errors.Is(err, mysql.ErrorUserNotExist) {
+			ResponseError(c, CodeUserNotExist)
+			return
+		}
+		zap.L().Error("logic.SetUserStatus() failed",
+			zap.Int64("user_id", req.UserID),
+			zap.String("status", req.Status), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// ListUserTrusts 分页获取用户信任分，信任分低的用户在前: GET
+func ListUserTrusts(c *gin.Context) {
+	var req struct {
+		Page int `form:"page" binding:"omitempty,min=1"`
+		Size int `form:"size" binding:"omitempty,min=1,max=100"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.Size == 0 {
+		req.Size = 20
+	}
+	data, err := logic.ListUserTrusts(req.Page, req.Size)
+	if err != nil {
+		zap.L().Error("logic.ListUserTrusts() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// RecomputeTrust 由做题记录重新计算用户信任分: POST
+func RecomputeTrust(c *gin.Context) {
+	report, err := logic.RecomputeTrust()
+	if err != nil {
+		zap.L().Error("logic.RecomputeTrust() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, report)
+}
+
+// CreateQuiz 新建题目: POST
+func CreateQuiz(c *gin.Context) {
+	var req struct {
+		Type     string          `json:"quiz_type" binding:"required"` // 题目类型编号，见pkg/quizkind
+		Content  string          `json:"content" binding:"required"`
+		Options  json.RawMessage `json:"options" binding:"required"`
+		Target   int             `json:"target_judgements" binding:"min=0"` // 可选，0表示使用默认目标作答数
+		Priority int             `json:"priority"`                          // 可选，按优先级出题时数值越大越先出
+
+		IsGold     bool            `json:"is_gold"`     // 可选，是否为金标准题
+		GoldAnswer json.RawMessage `json:"gold_answer"` // 金标准题的标准答案，写法与提交答案相同
+
+		CampaignID int64 `json:"campaign_id" binding:"min=0"` // 可选，所属评测项目，0表示全局题库
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	quiz, err := logic.CreateQuiz(&logic.QuizInput{
+		Type:     req.Type,
+		Content:  req.Content,
+		Options:  req.Options,
+		Target:   req.Target,
+		Priority: req.Priority,
+
+		IsGold:     req.IsGold,
+		GoldAnswer: req.GoldAnswer,
+		CampaignID: req.CampaignID,
+	})
+	if err != nil {
+		if errors.Is(err, logic.ErrorInvalidOptions) || errors.Is(err, logic.ErrorInvalidQuizType) ||
+			errors.Is(err, logic.ErrorInvalidTarget) || errors.Is(err, logic.ErrorModelNotRegistered) ||
+			errors.Is(err, logic.ErrorInvalidGoldAnswer) {
+			ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+			return
+		} else if errors.Is(err, mysql.ErrorCampaignNotExist) {
+			ResponseError(c, CodeCampaignNotExist)
+			return
+		} else if errors.Is(err, mysql.ErrorQuizExist) {
+			ResponseError(c, CodeQuizExist)
+			return
+		}
+		zap.L().Error("logic.CreateQuiz() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, quiz)
+}
+
+// UpdateQuiz 更新题目: POST
+func UpdateQuiz(c *gin.Context) {
+	var req struct {
+		QuizID   int64           `json:"quiz_id,string" binding:"required"`
+		Type     string          `json:"quiz_type" binding:"required"` // 题目类型编号，见pkg/quizkind
+		Content  string          `json:"content" binding:"required"`
+		Options  json.RawMessage `json:"options" binding:"required"`
+		Target   int             `json:"target_judgements" binding:"min=0"` // 可选，0表示使用默认目标作答数
+		Priority int             `json:"priority"`                          // 可选，按优先级出题时数值越大越先出
+
+		IsGold     bool            `json:"is_gold"`     // 可选，是否为金标准题
+		GoldAnswer json.RawMessage `json:"gold_answer"` // 金标准题的标准答案，写法与提交答案相同
+
+		CampaignID *int64 `json:"campaign_id" binding:"omitempty,min=0"` // 可选，所属评测项目，0表示全局题库，不传时保持不变
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	err := logic.UpdateQuiz(req.QuizID, &logic.QuizInput{
+		Type:     req.Type,
+		Content:  req.Content,
+		Options:  req.Options,
+		Target:   req.Target,
+		Priority: req.Priority,
+
+		IsGold:     req.IsGold,
+		GoldAnswer: req.GoldAnswer,
+	}, req.CampaignID)
+	if err != nil {
+		if errors.Is(err, logic.ErrorInvalidOptions) || errors.Is(err, logic.ErrorInvalidQuizType) ||
+			errors.Is(err, logic.ErrorInvalidTarget) || errors.Is(err, logic.ErrorModelNotRegistered) ||
+			errors.Is(err, logic.ErrorInvalidGoldAnswer) {
+			ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+			return
+		} else if errors.Is(err, mysql.ErrorQuizNotExist) {
+			ResponseError(c, CodeQuizNotExist)
+			return
+		} else if errors.Is(err, mysql.ErrorCampaignNotExist) {
+			ResponseError(c, CodeCampaignNotExist)
+			return
+		} else if errors.Is(err, mysql.ErrorQuizExist) {
+			ResponseError(c, CodeQuizExist)
+			return
+		}
+		zap.L().Error("logic.UpdateQuiz() failed", zap.Int64("quiz_id", req.QuizID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// RetireQuiz 下线题目: POST
+func RetireQuiz(c *gin.Context) {
+	var req struct {
+		QuizID int64 `json:"quiz_id,string" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if err := logic.RetireQuiz(req.QuizID); err != nil {
+		if errors.Is(err, mysql.ErrorQuizNotExist) {
+			ResponseError(c, CodeQuizNotExist)
+			return
+		}
+		zap.L().Error("logic.RetireQuiz() failed", zap.Int64("quiz_id", req.QuizID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// ListQuizzes 分页获取题目列表: GET
+func ListQuizzes(c *gin.Context) {
+	var req struct {
+		Page   int    `form:"page" binding:"omitempty,min=1"`
+		Size   int    `form:"size" binding:"omitempty,min=1,max=100"`
+		Type   string `form:"quiz_type"`
+		Status string `form:"status" binding:"omitempty,oneof=active retired"`
+
+		CampaignID int64 `form:"campaign_id"` // 可选，只查看该评测项目的题目
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.Size == 0 {
+		req.Size = 20
+	}
+	data, err := logic.ListQuizzes(req.Page, req.Size, req.Type, req.Status, req.CampaignID)
+	if err != nil {
+		zap.L().Error("logic.ListQuizzes() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// ListGoldAccuracy 分页获取用户在金标准题上的正确率，正确率低的用户在前: GET
+func ListGoldAccuracy(c *gin.Context) {
+	var req struct {
+		Page        int   `form:"page" binding:"omitempty,min=1"`
+		Size        int   `form:"size" binding:"omitempty,min=1,max=100"`
+		UserID      int64 `form:"user_id"`                                // 可选，只查看指定用户
+		MinAnswered int64 `form:"min_answered" binding:"omitempty,min=0"` // 可选，只统计金标准题作答数不少于该值的用户
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.Size == 0 {
+		req.Size = 20
+	}
+	data, err := logic.ListGoldAccuracy(req.Page, req.Size, req.UserID, req.MinAnswered)
+	if err != nil {
+		zap.L().Error("logic.ListGoldAccuracy() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// ListConsensusLabels 分页获取题目的共识标签，置信度低的在前: GET
+func ListConsensusLabels(c *gin.Context) {
+	var req struct {
+		Page   int    `form:"page" binding:"omitempty,min=1"`
+		Size   int    `form:"size" binding:"omitempty,min=1,max=100"`
+		QuizID int64  `form:"quiz_id"`                                                        // 可选，只查看指定题目
+		Method string `form:"method" binding:"omitempty,oneof=majority weighted dawid_skene"` // 可选，只查看指定聚合方法
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.Size == 0 {
+		req.Size = 20
+	}
+	data, err := logic.ListConsensusLabels(req.Page, req.Size, req.QuizID, req.Method)
+	if err != nil {
+		zap.L().Error("logic.ListConsensusLabels() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// RecomputeConsensus 由做题记录重新聚合题目的共识标签: POST
+func RecomputeConsensus(c *gin.Context) {
+	report, err := logic.RecomputeConsensus()
+	if err != nil {
+		zap.L().Error("logic.RecomputeConsensus() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, report)
+}
+
+// ArchiveRankings 将已结束的统计窗口的刷题排行榜存档到mysql: POST
+func ArchiveRankings(c *gin.Context) {
+	report, err := logic.ArchiveRankings()
+	if err != nil {
+		zap.L().Error("logic.ArchiveRankings() failed", zap.Any("report", report), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, report)
+}
+
+// SyncQuizBase 以mysql为准重建redis题库集合: POST
+func SyncQuizBase(c *gin.Context) {
+	if err := logic.SyncQuizBase(); err != nil {
+		zap.L().Error("logic.SyncQuizBase() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// ImportQuizzes 从JSONL或CSV文件批量导入题目: POST multipart/form-data
+// 表单参数 file: 导入文件; format: jsonl或csv，缺省时按文件扩展名推断; dry_run: 为true时只校验不写入
+func ImportQuizzes(c *gin.Context) {
+	fh, err := c.FormFile("file")
+	if err != nil {
+		ResponseErrorWithMsg(c, CodeInvalidParam, "缺少导入文件")
+		return
+	}
+	format := c.DefaultPostForm("format", logic.FormatFromFilename(fh.Filename))
+	dryRun, _ := strconv.ParseBool(c.DefaultPostForm("dry_run", "false"))
+	f, err := fh.Open()
+	if err != nil {
+		zap.L().Error("open import file failed", zap.String("filename", fh.Filename), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	defer f.Close()
+
+	report, err := logic.ImportQuizzes(f, format, dryRun, 0)
+	if err != nil {
+		if errors.Is(err, logic.ErrorUnknownFormat) || errors.Is(err, logic.ErrorInvalidHeader) {
+			ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+			return
+		}
+		zap.L().Error("logic.ImportQuizzes() failed",
+			zap.String("filename", fh.Filename),
+			zap.Any("report", report), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, report)
+}
+
+// CreateEvalModel 登记被评测的模型: POST
+func CreateEvalModel(c *gin.Context) {
+	var req struct {
+		ModelID     string `json:"model_id" binding:"required,max=64"`
+		Name        string `json:"name" binding:"max=128"`
+		Provider    string `json:"provider" binding:"max=64"`
+		Description string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	m := &models.EvalModel{
+		ModelID:     req.ModelID,
+		Name:        req.Name,
+		Provider:    req.Provider,
+		Description: req.Description,
+	}
+	if err := logic.CreateEvalModel(m); err != nil {
+		if errors.Is(err, logic.ErrorEmptyModelID) {
+			ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+			return
+		} else if errors.Is(err, mysql.ErrorModelExist) {
+			ResponseError(c, CodeModelExist)
+			return
+		}
+		zap.L().Error("logic.CreateEvalModel() failed", zap.String("model_id", req.ModelID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, m)
+}
+
+// ListEvalModels 获取已登记的模型: GET
+func ListEvalModels(c *gin.Context) {
+	ms, err := logic.ListEvalModels()
+	if err != nil {
+		zap.L().Error("logic.ListEvalModels() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, ms)
+}
+
+// RecomputeModelLeaderboard 由做题记录重新计算模型排行榜: POST
+func RecomputeModelLeaderboard(c *gin.Context) {
+	board, err := logic.ComputeModelLeaderboard()
+	if err != nil {
+		zap.L().Error("logic.ComputeModelLeaderboard() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, board)
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"scgptEval/logic"
+	"scgptEval/models"
+)
+
+// agreementFilter 一致性指标的筛选条件
+type agreementFilter struct {
+	QuizType string `form:"quiz_type"`
+	QuizID   int64  `form:"quiz_id"`
+	Campaign int64  `form:"campaign_id"` // 只统计该评测项目的题目
+	Start    string `form:"start"`       // 起止时间(日期或RFC3339)
+	End      string `form:"end"`
+	Refresh  bool   `form:"refresh"` // 为true时忽略缓存重新计算
+}
+
+func (f *agreementFilter) recordFilter() (filter *models.RecordFilter, err error) {
+	filter = &models.RecordFilter{QuizType: f.QuizType, QuizID: f.QuizID, CampaignID: f.Campaign}
+	if filter.Start, err = logic.ParseTimeParam(f.Start, false); err != nil {
+		return nil, err
+	}
+	if filter.End, err = logic.ParseTimeParam(f.End, true); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// GetAgreementSummary 按题型获取标注一致性指标: GET
+func GetAgreementSummary(c *gin.Context) {
+	var req agreementFilter
+	if err := c.ShouldBindQuery(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	filter, err := req.recordFilter()
+	if err != nil {
+		ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+		return
+	}
+	res, err := logic.GetAgreementSummary(filter, req.Refresh)
+	if err != nil {
+		if errors.Is(err, logic.ErrorAgreementUnsupported) {
+			ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+			return
+		}
+		zap.L().Error("logic.GetAgreementSummary() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, res)
+}
+
+// ListQuizAgreement 分页获取某一题型逐题的标注分布与一致率，一致率低的题目在前: GET
+func ListQuizAgreement(c *gin.Context) {
+	var req struct {
+		agreementFilter
+		Page int `form:"page" binding:"omitempty,min=1"`
+		Size int `form:"size" binding:"omitempty,min=1,max=100"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil || req.QuizType == "" {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.Size == 0 {
+		req.Size = 20
+	}
+	filter, err := req.recordFilter()
+	if err != nil {
+		ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+		return
+	}
+	data, err := logic.ListQuizAgreement(filter, req.Page, req.Size, req.Refresh)
+	if err != nil {
+		if errors.Is(err, logic.ErrorAgreementUnsupported) {
+			ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+			return
+		}
+		zap.L().Error("logic.ListQuizAgreement() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"scgptEval/dao/mysql"
+	"scgptEval/logic"
+	"strconv"
+)
+
+// campaignQuery 查询参数中可选的评测项目id，缺省时为全局题库
+type campaignQuery struct {
+	CampaignID int64 `form:"campaign_id" binding:"omitempty,min=0"`
+}
+
+// responseCampaignError 评测项目相关的错误返回对应的错误码，其他错误返回false由调用方处理
+func responseCampaignError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, mysql.ErrorCampaignNotExist):
+		ResponseError(c, CodeCampaignNotExist)
+	case errors.Is(err, logic.ErrorNotEnrolled):
+		ResponseError(c, CodeNotEnrolled)
+	case errors.Is(err, logic.ErrorCampaignClosed):
+		ResponseError(c, CodeCampaignClosed)
+	default:
+		return false
+	}
+	return true
+}
+
+// ListUserCampaigns 获取当前用户参与的评测项目: GET
+func ListUserCampaigns(c *gin.Context) {
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		zap.L().Error("getCurrentUser() failed", zap.Error(err))
+		ResponseError(c, CodeInvalidToken)
+		return
+	}
+	list, err := logic.ListUserCampaigns(userID)
+	if err != nil {
+		zap.L().Error("logic.ListUserCampaigns() failed", zap.Int64("user_id", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, list)
+}
+
+// parseIDs 将字符串形式的id列表转换为整数
+func parseIDs(ids []string) ([]int64, error) {
+	res := make([]int64, 0, len(ids))
+	for _, s := range ids {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, id)
+	}
+	return res, nil
+}
+
+// responseCampaignInputError 评测项目字段校验失败时返回对应的错误码，其他错误返回false由调用方处理
+func responseCampaignInputError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, logic.ErrorEmptyCampaignName) || errors.Is(err, logic.ErrorInvalidCampaignTime) ||
+		errors.Is(err, logic.ErrorUnknownStrategy):
+		ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+	case errors.Is(err, mysql.ErrorCampaignExist):
+		ResponseError(c, CodeCampaignExist)
+	case errors.Is(err, mysql.ErrorCampaignNotExist):
+		ResponseError(c, CodeCampaignNotExist)
+	default:
+		return false
+	}
+	return true
+}
+
+// CreateCampaign 新建评测项目: POST
+func CreateCampaign(c *gin.Context) {
+	var req logic.CampaignInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	campaign, err := logic.CreateCampaign(&req)
+	if err != nil {
+		if responseCampaignInputError(c, err) {
+			return
+		}
+		zap.L().Error("logic.CreateCampaign() failed", zap.String("name", req.Name), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, campaign)
+}
+
+// UpdateCampaign 更新评测项目: POST
+func UpdateCampaign(c *gin.Context) {
+	var req struct {
+		CampaignID int64 `json:"campaign_id" binding:"required"`
+		logic.CampaignInput
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if err := logic.UpdateCampaign(req.CampaignID, &req.CampaignInput); err != nil {
+		if responseCampaignInputError(c, err) {
+			return
+		}
+		zap.L().Error("logic.UpdateCampaign() failed", zap.Int64("campaign_id", req.CampaignID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// ListCampaigns 分页获取评测项目: GET
+func ListCampaigns(c *gin.Context) {
+	var req struct {
+		Page int `form:"page" binding:"omitempty,min=1"`
+		Size int `form:"size" binding:"omitempty,min=1,max=100"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.Size == 0 {
+		req.Size = 20
+	}
+	data, err := logic.ListCampaigns(req.Page, req.Size)
+	if err != nil {
+		zap.L().Error("logic.ListCampaigns() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, data)
+}
+
+// EnrollUsers 将用户加入评测项目: POST
+func EnrollUsers(c *gin.Context) {
+	var req struct {
+		CampaignID int64    `json:"campaign_id" binding:"required"`
+		UserIDs    []string `json:"user_ids" binding:"required,min=1,max=1000"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userIds, err := parseIDs(req.UserIDs)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if err = logic.EnrollUsers(req.CampaignID, userIds); err != nil {
+		if errors.Is(err, mysql.ErrorCampaignNotExist) {
+			ResponseError(c, CodeCampaignNotExist)
+			return
+		} else if errors.Is(err, mysql.ErrorUserNotExist) {
+			ResponseError(c, CodeUserNotExist)
+			return
+		}
+		zap.L().Error("logic.EnrollUsers() failed", zap.Int64("campaign_id", req.CampaignID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// UnenrollUser 将用户移出评测项目: POST
+func UnenrollUser(c *gin.Context) {
+	var req struct {
+		CampaignID int64 `json:"campaign_id" binding:"required"`
+		UserID     int64 `json:"user_id,string" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if err := logic.UnenrollUser(req.CampaignID, req.UserID); err != nil {
+		if errors.Is(err, mysql.ErrorCampaignNotExist) {
+			ResponseError(c, CodeCampaignNotExist)
+			return
+		}
+		zap.L().Error("logic.UnenrollUser() failed",
+			zap.Int64("campaign_id", req.CampaignID),
+			zap.Int64("user_id", req.UserID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// AssignQuizzes 将题目划入评测项目，campaign_id为0时划回全局题库: POST
+func AssignQuizzes(c *gin.Context) {
+	var req struct {
+		CampaignID int64    `json:"campaign_id" binding:"min=0"`
+		QuizIDs    []string `json:"quiz_ids" binding:"required,min=1,max=1000"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	quizIds, err := parseIDs(req.QuizIDs)
+	if err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if err = logic.AssignQuizzes(req.CampaignID, quizIds); err != nil {
+		if errors.Is(err, mysql.ErrorCampaignNotExist) {
+			ResponseError(c, CodeCampaignNotExist)
+			return
+		} else if errors.Is(err, mysql.ErrorQuizNotExist) {
+			ResponseError(c, CodeQuizNotExist)
+			return
+		}
+		zap.L().Error("logic.AssignQuizzes() failed", zap.Int64("campaign_id", req.CampaignID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
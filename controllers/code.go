@@ -16,6 +16,21 @@ const (
 	CodeInvalidToken
 	CodeNeedLogin
 	CodeInvalidAuthFormat
+	CodeTokenRevoked
+	CodeSessionNotExist
+	CodeNoPermission
+	CodeQuizExhausted
+	CodeQuizSaturated
+	CodeInvalidAnswer
+	CodeModelExist
+	CodeUserSuspended
+	CodeCampaignExist
+	CodeCampaignNotExist
+	CodeNotEnrolled
+	CodeCampaignClosed
+	CodeRecordNotExist
+	CodeRevisionExpired
+	CodeQuizExist
 )
 
 var codeMsgMap = map[ResCode]string{
@@ -31,6 +46,21 @@ var codeMsgMap = map[ResCode]string{
 	CodeNeedLogin:         "未登录",
 	CodeInvalidToken:      "无效Token",
 	CodeInvalidAuthFormat: "认证格式有误",
+	CodeTokenRevoked:      "登录状态已失效，请重新登录",
+	CodeSessionNotExist:   "会话不存在",
+	CodeNoPermission:      "权限不足",
+	CodeQuizExhausted:     "题库中的题目已全部刷完",
+	CodeQuizSaturated:     "该题目已收到足够的作答，请重新获取题目",
+	CodeInvalidAnswer:     "答案不合法",
+	CodeModelExist:        "模型已存在",
+	CodeUserSuspended:     "账号已被暂停，请联系管理员",
+	CodeCampaignExist:     "评测项目已存在",
+	CodeCampaignNotExist:  "评测项目不存在",
+	CodeNotEnrolled:       "未参与该评测项目",
+	CodeCampaignClosed:    "评测项目未开始或已结束",
+	CodeRecordNotExist:    "做题记录不存在",
+	CodeRevisionExpired:   "已超过可修改答案的时限",
+	CodeQuizExist:         "题库中已有相同内容的题目",
 }
 
 func (c ResCode) Msg() string {
@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"scgptEval/logic"
+)
+
+// GetModelLeaderboard 获取模型排行榜: GET
+func GetModelLeaderboard(c *gin.Context) {
+	board, err := logic.GetModelLeaderboard()
+	if err != nil {
+		zap.L().Error("logic.GetModelLeaderboard() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, board)
+}
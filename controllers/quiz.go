@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
@@ -8,19 +9,29 @@ import (
 	"scgptEval/dao/mysql"
 	"scgptEval/dao/redis"
 	"scgptEval/logic"
+	"scgptEval/models"
 )
 
 // GetUserAmount 获取用户刷题量: GET
+// 查询参数campaign_id可选，为评测项目内的刷题量，缺省时为全局题库
 func GetUserAmount(c *gin.Context) {
+	var req campaignQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
 	userID, err := getCurrentUser(c)
 	if err != nil {
 		zap.L().Error("getCurrentUser() failed", zap.Error(err))
 		ResponseError(c, CodeInvalidToken)
 		return
 	}
-	amount, err := redis.GetUserAmount(fmt.Sprint(userID))
+	amount, err := logic.GetUserAmount(req.CampaignID, userID)
 	if err != nil {
-		zap.L().Error("redis.GetUserAmount() failed", zap.Int64("userID", userID), zap.Error(err))
+		if responseCampaignError(c, err) {
+			return
+		}
+		zap.L().Error("logic.GetUserAmount() failed", zap.Int64("userID", userID), zap.Error(err))
 		ResponseError(c, CodeServerBusy)
 		return
 	}
@@ -32,11 +43,45 @@ func GetUserAmount(c *gin.Context) {
 }
 
 // GetRanking 获取刷题排行信息: GET
+// 查询参数campaign_id可选，为评测项目内的排行，缺省时为全局题库；
+// period可选，为all(累计，缺省)、daily、weekly或monthly；date可选，为统计窗口内的任一日期，缺省时为当前窗口；
+// offset/limit可选，为分页参数；around可选，为返回的当前用户前后的用户数
 func GetRanking(c *gin.Context) {
-	// 默认展示刷题量前20名的用户信息
-	rank, err := redis.GetRanking(20)
+	var req struct {
+		campaignQuery
+		Period string `form:"period" binding:"omitempty,oneof=all daily weekly monthly"`
+		Date   string `form:"date"`
+		Offset int64  `form:"offset" binding:"omitempty,min=0"`
+		Limit  int64  `form:"limit" binding:"omitempty,min=1,max=100"`
+		Around *int64 `form:"around" binding:"omitempty,min=0,max=10"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := getCurrentUser(c)
 	if err != nil {
-		zap.L().Error("redis.GetRanking() failed", zap.Error(err))
+		zap.L().Error("getCurrentUser() failed", zap.Error(err))
+		ResponseError(c, CodeInvalidToken)
+		return
+	}
+	// 默认展示刷题量前20名的用户信息及当前用户前后各2名，配置按信任分排名时按加权刷题量排名
+	if req.Limit == 0 {
+		req.Limit = 20
+	}
+	around := int64(2)
+	if req.Around != nil {
+		around = *req.Around
+	}
+	rank, err := logic.GetRanking(req.CampaignID, userID, req.Offset, req.Limit, around, req.Period, req.Date)
+	if err != nil {
+		if responseCampaignError(c, err) {
+			return
+		} else if errors.Is(err, logic.ErrorUnknownPeriod) || errors.Is(err, logic.ErrorInvalidTime) {
+			ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+			return
+		}
+		zap.L().Error("logic.GetRanking() failed", zap.Int64("user_id", userID), zap.Error(err))
 		ResponseError(c, CodeServerBusy)
 		return
 	}
@@ -48,8 +93,9 @@ func GetRanking(c *gin.Context) {
 func SubmitQuiz(c *gin.Context) {
 	// 1.请求参数获取并校验
 	var req struct {
-		QuizID        int64  `json:"quiz_id,string" binding:"required"`
-		SelectOptions string `json:"select_options" binding:"required"`
+		QuizID        int64           `json:"quiz_id,string" binding:"required"`
+		SelectOptions json.RawMessage `json:"select_options" binding:"required"` // 字符串或JSON字符串数组
+		CampaignID    int64           `json:"campaign_id"`                       // 可选，题目所属的评测项目
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		ResponseError(c, CodeInvalidParam)
@@ -63,13 +109,26 @@ func SubmitQuiz(c *gin.Context) {
 		return
 	}
 	// 2.执行提交逻辑：更新数据库与缓存
-	if err = logic.SubmitQuiz(userID, req.QuizID, req.SelectOptions); err != nil {
-		if errors.Is(err, mysql.ErrorQuizNotExist) {
+	answer := string(req.SelectOptions)
+	var str string
+	if json.Unmarshal(req.SelectOptions, &str) == nil {
+		answer = str
+	}
+	if err = logic.SubmitQuiz(userID, req.CampaignID, req.QuizID, answer); err != nil {
+		if responseCampaignError(c, err) {
+			return
+		} else if errors.Is(err, mysql.ErrorQuizNotExist) {
 			ResponseError(c, CodeQuizNotExist)
 			return
 		} else if errors.Is(err, mysql.ErrorRecordExist) {
 			ResponseError(c, CodeRecordExist)
 			return
+		} else if errors.Is(err, redis.ErrorQuizSaturated) {
+			ResponseError(c, CodeQuizSaturated)
+			return
+		} else if errors.Is(err, logic.ErrorInvalidAnswer) {
+			ResponseErrorWithMsg(c, CodeInvalidAnswer, err.Error())
+			return
 		}
 		zap.L().Error("logic.SubmitQuiz() failed",
 			zap.Int64("user_id", userID),
@@ -80,15 +139,72 @@ func SubmitQuiz(c *gin.Context) {
 	ResponseSuccess(c, nil)
 }
 
+// ReviseAnswer 在提交后的时限内修改已提交的答案: POST
+func ReviseAnswer(c *gin.Context) {
+	var req struct {
+		QuizID        int64           `json:"quiz_id,string" binding:"required"`
+		SelectOptions json.RawMessage `json:"select_options" binding:"required"` // 字符串或JSON字符串数组
+		CampaignID    int64           `json:"campaign_id"`                       // 可选，题目所属的评测项目
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		zap.L().Error("getCurrentUser() failed", zap.Error(err))
+		ResponseError(c, CodeInvalidToken)
+		return
+	}
+	answer := string(req.SelectOptions)
+	var str string
+	if json.Unmarshal(req.SelectOptions, &str) == nil {
+		answer = str
+	}
+	if err = logic.ReviseAnswer(userID, req.CampaignID, req.QuizID, answer); err != nil {
+		if responseCampaignError(c, err) {
+			return
+		} else if errors.Is(err, mysql.ErrorQuizNotExist) {
+			ResponseError(c, CodeQuizNotExist)
+			return
+		} else if errors.Is(err, mysql.ErrorRecordNotExist) {
+			ResponseError(c, CodeRecordNotExist)
+			return
+		} else if errors.Is(err, mysql.ErrorRevisionExpired) {
+			ResponseError(c, CodeRevisionExpired)
+			return
+		} else if errors.Is(err, logic.ErrorInvalidAnswer) {
+			ResponseErrorWithMsg(c, CodeInvalidAnswer, err.Error())
+			return
+		}
+		zap.L().Error("logic.ReviseAnswer() failed",
+			zap.Int64("user_id", userID),
+			zap.Int64("quiz_id", req.QuizID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
 // GetUntriedQuizzes 获取指定数量的题目: POST
 func GetUntriedQuizzes(c *gin.Context) {
 	var req struct {
-		QuizNum int `json:"quiz_num" binding:"oneof=20 50 100"`
+		QuizNum  int    `json:"quiz_num" binding:"oneof=20 50 100"`
+		Strategy string `json:"strategy"` // 可选，出题策略，为空时使用默认策略
+		Seed     int64  `json:"seed"`     // 可选，sequential策略的种子
+
+		CampaignID int64 `json:"campaign_id"` // 可选，在评测项目内出题，缺省时为全局题库
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		ResponseErrorWithMsg(c, CodeInvalidParam, "题目数仅限20，50，100")
 		return
 	}
+	if req.Strategy != "" {
+		if _, err := logic.GetQuizStrategy(req.Strategy); err != nil {
+			ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+			return
+		}
+	}
 	userID, err := getCurrentUser(c)
 	if err != nil {
 		zap.L().Error("getCurrentUser() failed", zap.Error(err))
@@ -96,13 +212,99 @@ func GetUntriedQuizzes(c *gin.Context) {
 		return
 	}
 	// 从redis获取用户未刷过的题目id列表，再从mysql中随机挑选目标条数的题目
-	quizList, err := logic.GetUntriedQuizzes(req.QuizNum, userID)
+	res, err := logic.GetUntriedQuizzes(req.QuizNum, userID, req.CampaignID, req.Strategy, req.Seed)
 	if err != nil {
+		if responseCampaignError(c, err) {
+			return
+		}
 		zap.L().Error("logic.GetUntriedQuizzes() failed",
 			zap.Int64("user_id", userID),
 			zap.Int("Need Quiz Num", req.QuizNum), zap.Error(err))
 		ResponseError(c, CodeServerBusy)
 		return
 	}
-	ResponseSuccess(c, quizList)
+	if len(res.Quizzes) == 0 && res.Exhausted {
+		// 用户已刷完全部题目；未刷完但题目均被他人预留时返回空列表，稍后可重试
+		ResponseError(c, CodeQuizExhausted)
+		return
+	}
+	// 剩余题目数不足时返回全部剩余题目，由remaining/exhausted告知前端
+	ResponseSuccess(c, res)
+}
+
+// GetHistory 按提交时间从新到旧分页获取当前用户的做题记录: GET
+// 查询参数 cursor: 上一页返回的next_cursor，缺省时从最新的记录开始; limit; start/end: 起止时间(日期或RFC3339); quiz_type; campaign_id
+func GetHistory(c *gin.Context) {
+	var req struct {
+		Cursor     string `form:"cursor"`
+		Limit      int    `form:"limit" binding:"omitempty,min=1,max=100"`
+		Start      string `form:"start"`
+		End        string `form:"end"`
+		QuizType   string `form:"quiz_type"`
+		CampaignID int64  `form:"campaign_id"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 20
+	}
+	filter := &models.RecordFilter{QuizType: req.QuizType, CampaignID: req.CampaignID}
+	var err error
+	if filter.Start, err = logic.ParseTimeParam(req.Start, false); err != nil {
+		ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+		return
+	}
+	if filter.End, err = logic.ParseTimeParam(req.End, true); err != nil {
+		ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+		return
+	}
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		zap.L().Error("getCurrentUser() failed", zap.Error(err))
+		ResponseError(c, CodeInvalidToken)
+		return
+	}
+	res, err := logic.GetUserHistory(userID, filter, req.Cursor, req.Limit)
+	if err != nil {
+		if errors.Is(err, logic.ErrorInvalidCursor) {
+			ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+			return
+		}
+		zap.L().Error("logic.GetUserHistory() failed", zap.Int64("user_id", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, res)
+}
+
+// GetHistoryDetail 获取当前用户的一条做题记录详情: GET
+func GetHistoryDetail(c *gin.Context) {
+	var req struct {
+		RecordID int64 `form:"record_id" binding:"required"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		zap.L().Error("getCurrentUser() failed", zap.Error(err))
+		ResponseError(c, CodeInvalidToken)
+		return
+	}
+	item, err := logic.GetUserRecord(userID, req.RecordID)
+	if err != nil {
+		if errors.Is(err, mysql.ErrorRecordNotExist) {
+			ResponseError(c, CodeRecordNotExist)
+			return
+		}
+		zap.L().Error("logic.GetUserRecord() failed",
+			zap.Int64("user_id", userID),
+			zap.Int64("record_id", req.RecordID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, item)
 }
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"scgptEval/logic"
+	"scgptEval/models"
+	"time"
+)
+
+// ExportRecords 导出做题记录数据集: GET
+// 查询参数 format: jsonl或csv; start/end: 起止时间(日期或RFC3339); quiz_type; user_id; campaign_id; pseudonymous: 是否假名化用户id
+func ExportRecords(c *gin.Context) {
+	var req struct {
+		Format       string `form:"format" binding:"omitempty,oneof=jsonl csv"`
+		Start        string `form:"start"`
+		End          string `form:"end"`
+		QuizType     string `form:"quiz_type"`
+		UserID       int64  `form:"user_id"`
+		CampaignID   int64  `form:"campaign_id"`
+		Pseudonymous bool   `form:"pseudonymous"`
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	if req.Format == "" {
+		req.Format = logic.FormatJSONL
+	}
+	filter := &models.RecordFilter{QuizType: req.QuizType, UserID: req.UserID, CampaignID: req.CampaignID}
+	var err error
+	if filter.Start, err = logic.ParseTimeParam(req.Start, false); err != nil {
+		ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+		return
+	}
+	if filter.End, err = logic.ParseTimeParam(req.End, true); err != nil {
+		ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+		return
+	}
+	if err = logic.CheckExportParams(req.Format, req.Pseudonymous); err != nil {
+		ResponseErrorWithMsg(c, CodeInvalidParam, err.Error())
+		return
+	}
+
+	// 开始写出后无法再返回JSON错误响应，只能打log
+	filename := fmt.Sprintf("records_%s.%s", time.Now().Format("20060102150405"), req.Format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	if req.Format == logic.FormatCSV {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		c.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+	}
+	count, err := logic.ExportRecords(c.Writer, req.Format, filter, req.Pseudonymous)
+	if err != nil {
+		zap.L().Error("logic.ExportRecords() failed", zap.Int("exported", count), zap.Error(err))
+	}
+}
@@ -7,7 +7,11 @@ import (
 
 // request.go 根据上下文获取请求中的一些参数信息
 
-const ContextUserIDKey = "userID"
+const (
+	ContextUserIDKey   = "userID"
+	ContextUserRoleKey = "userRole"
+	ContextFamilyIDKey = "familyID"
+)
 
 var ErrorUserNotLogin = errors.New("用户未登录")
 
@@ -25,3 +29,18 @@ func getCurrentUser(c *gin.Context) (userID int64, err error) {
 	}
 	return
 }
+
+// getCurrentFamily 获取当前登录对应的refresh token family id
+func getCurrentFamily(c *gin.Context) (familyID string, err error) {
+	fid, ok := c.Get(ContextFamilyIDKey)
+	if !ok {
+		err = ErrorUserNotLogin
+		return
+	}
+	familyID, ok = fid.(string)
+	if !ok || familyID == "" {
+		err = ErrorUserNotLogin
+		return
+	}
+	return
+}
@@ -6,12 +6,11 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"go.uber.org/zap"
-	"net/http"
 	"scgptEval/dao/mysql"
 	"scgptEval/dao/redis"
+	"scgptEval/logic"
 	"scgptEval/models"
 	"scgptEval/pkg/jwt"
-	"strings"
 )
 
 // SignUp 用户注册
@@ -75,7 +74,7 @@ func LogIn(c *gin.Context) {
 		return
 	}
 	// 2.登录并响应
-	aToken, rToken, err := mysql.LogIn(&user)
+	aToken, rToken, err := logic.LogIn(&user, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
 		if errors.Is(err, mysql.ErrorUserNotExist) {
 			// 用户不存在
@@ -85,10 +84,14 @@ func LogIn(c *gin.Context) {
 			// 密码错误
 			ResponseError(c, CodeInvalidPassword)
 			return
+		} else if errors.Is(err, mysql.ErrorUserSuspended) {
+			ResponseError(c, CodeUserSuspended)
+			return
 		}
 		// 其它登录错误，打log；返回服务繁忙
-		zap.L().Error("mysql.LogIn() failed", zap.String("username", user.Username), zap.Error(err))
+		zap.L().Error("logic.LogIn() failed", zap.String("username", user.Username), zap.Error(err))
 		ResponseError(c, CodeServerBusy)
+		return
 	}
 
 	ResponseSuccess(c, gin.H{
@@ -142,27 +145,98 @@ func ChangeName(c *gin.Context) {
 	})
 }
 
-// RefreshTokenHandler 刷新Access Token
+// RefreshTokenHandler 使用refresh token换取新的token对，旧的refresh token随即失效
 func RefreshTokenHandler(c *gin.Context) {
 	rt := c.Query("refresh_token") // 获取GET请求中携带的refresh_token参数
-	// 默认使用Bearer Token
-	authHeader := c.Request.Header.Get("Authorization")
-	if authHeader == "" {
-		ResponseErrorWithMsg(c, CodeInvalidToken, "请求头缺少Auth Token")
-		c.Abort()
+	if rt == "" {
+		ResponseErrorWithMsg(c, CodeInvalidParam, "缺少refresh_token参数")
 		return
 	}
-	// 按空格分割
-	parts := strings.SplitN(authHeader, " ", 2)
-	if !(len(parts) == 2 && parts[0] == "Bearer") {
-		ResponseErrorWithMsg(c, CodeInvalidToken, "Token格式有误")
-		c.Abort()
+	aToken, rToken, err := logic.RefreshToken(rt)
+	if err != nil {
+		if errors.Is(err, jwt.ErrorInvalidToken) {
+			ResponseError(c, CodeInvalidToken)
+			return
+		} else if errors.Is(err, redis.ErrorTokenRevoked) || errors.Is(err, redis.ErrorTokenReused) ||
+			errors.Is(err, mysql.ErrorUserNotExist) {
+			// token已被吊销、被重放或用户已不存在，均需重新登录
+			ResponseError(c, CodeTokenRevoked)
+			return
+		} else if errors.Is(err, mysql.ErrorUserSuspended) {
+			ResponseError(c, CodeUserSuspended)
+			return
+		}
+		zap.L().Error("logic.RefreshToken() failed", zap.Error(err))
+		ResponseError(c, CodeServerBusy)
 		return
 	}
-	aToken, rToken, err := jwt.RefreshToken(parts[1], rt)
-	fmt.Println(err)
-	c.JSON(http.StatusOK, gin.H{
+	ResponseSuccess(c, gin.H{
 		"access_token":  aToken,
 		"refresh_token": rToken,
 	})
 }
+
+// LogOut 用户登出: POST
+func LogOut(c *gin.Context) {
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		ResponseError(c, CodeInvalidToken)
+		return
+	}
+	familyID, err := getCurrentFamily(c)
+	if err != nil {
+		ResponseError(c, CodeInvalidToken)
+		return
+	}
+	if err = logic.LogOut(userID, familyID); err != nil && !errors.Is(err, redis.ErrorSessionNotExist) {
+		zap.L().Error("logic.LogOut() failed", zap.String("familyID", familyID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
+
+// GetSessions 获取当前用户的全部登录会话: GET
+func GetSessions(c *gin.Context) {
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		ResponseError(c, CodeInvalidToken)
+		return
+	}
+	familyID, _ := getCurrentFamily(c)
+	sessions, err := logic.GetSessions(userID, familyID)
+	if err != nil {
+		zap.L().Error("logic.GetSessions() failed", zap.Int64("userID", userID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, sessions)
+}
+
+// RevokeSession 吊销当前用户的指定会话: POST
+func RevokeSession(c *gin.Context) {
+	var req struct {
+		SessionID string `json:"session_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, CodeInvalidParam)
+		return
+	}
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		ResponseError(c, CodeInvalidToken)
+		return
+	}
+	if err = logic.RevokeSession(userID, req.SessionID); err != nil {
+		if errors.Is(err, redis.ErrorSessionNotExist) {
+			ResponseError(c, CodeSessionNotExist)
+			return
+		}
+		zap.L().Error("logic.RevokeSession() failed",
+			zap.Int64("userID", userID),
+			zap.String("sessionID", req.SessionID), zap.Error(err))
+		ResponseError(c, CodeServerBusy)
+		return
+	}
+	ResponseSuccess(c, nil)
+}
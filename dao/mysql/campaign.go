@@ -0,0 +1,154 @@
+package mysql
+
+import (
+	"errors"
+	"github.com/jinzhu/gorm"
+	"scgptEval/models"
+	"time"
+)
+
+var (
+	ErrorCampaignExist    = errors.New("评测项目已存在")
+	ErrorCampaignNotExist = errors.New("评测项目不存在")
+)
+
+// CreateCampaign 新建评测项目
+func CreateCampaign(c *models.Campaign) (err error) {
+	if res := db.Where("name = ?", c.Name).First(&models.Campaign{}); res.RowsAffected > 0 {
+		return ErrorCampaignExist
+	}
+	c.CreatedAt = time.Now()
+	c.UpdatedAt = time.Now()
+	return db.Create(c).Error
+}
+
+// UpdateCampaign 更新评测项目的名称、描述、出题策略与起止时间
+func UpdateCampaign(c *models.Campaign) (err error) {
+	if _, err = GetCampaign(c.ID); err != nil {
+		return err
+	}
+	if res := db.Where("name = ? AND id <> ?", c.Name, c.ID).First(&models.Campaign{}); res.RowsAffected > 0 {
+		return ErrorCampaignExist
+	}
+	return db.Model(&models.Campaign{}).Where("id = ?", c.ID).Updates(map[string]interface{}{
+		"name":        c.Name,
+		"description": c.Description,
+		"strategy":    c.Strategy,
+		"start_at":    c.StartAt,
+		"end_at":      c.EndAt,
+		"updated_at":  time.Now(),
+	}).Error
+}
+
+// GetCampaign 根据id获取评测项目
+func GetCampaign(campaignID int64) (c *models.Campaign, err error) {
+	c = new(models.Campaign)
+	if res := db.Where("id = ?", campaignID).First(c); res.RowsAffected == 0 {
+		if res.Error != nil && !errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return nil, res.Error
+		}
+		return nil, ErrorCampaignNotExist
+	}
+	return c, nil
+}
+
+// ListCampaigns 分页获取评测项目，新建的在前
+func ListCampaigns(page, size int) (list []*models.Campaign, total int64, err error) {
+	if err = db.Model(&models.Campaign{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	list = make([]*models.Campaign, 0, size)
+	if err = db.Order("id DESC").
+		Offset((page - 1) * size).
+		Limit(size).
+		Find(&list).Error; err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+// GetCampaignIDs 获取全部评测项目的id
+func GetCampaignIDs() (ids []int64, err error) {
+	if err = db.Model(&models.Campaign{}).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// ListUserCampaigns 获取用户参与的全部评测项目，按开始时间排列
+func ListUserCampaigns(userID int64) (list []*models.Campaign, err error) {
+	list = make([]*models.Campaign, 0)
+	if err = db.Table("campaigns AS c").
+		Select("c.*").
+		Joins("JOIN campaign_users AS cu ON cu.campaign_id = c.id").
+		Where("cu.user_id = ?", userID).
+		Order("c.start_at, c.id").
+		Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// CheckEnrolled 检查用户是否参与了评测项目
+func CheckEnrolled(campaignID, userID int64) (enrolled bool, err error) {
+	var count int64
+	if err = db.Model(&models.CampaignUser{}).
+		Where("campaign_id = ? AND user_id = ?", campaignID, userID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// EnrollUsers 将用户加入评测项目，已参与的用户保持不变；任一用户不存在时不做修改
+func EnrollUsers(campaignID int64, userIds []int64) (err error) {
+	var count int64
+	if err = db.Model(&models.User{}).Where("id IN (?)", userIds).Count(&count).Error; err != nil {
+		return err
+	}
+	if count < int64(len(userIds)) {
+		return ErrorUserNotExist
+	}
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	now := time.Now()
+	for _, userID := range userIds {
+		cu := &models.CampaignUser{CampaignID: campaignID, UserID: userID, CreatedAt: now}
+		if err = tx.Where("campaign_id = ? AND user_id = ?", campaignID, userID).FirstOrCreate(cu).Error; err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+	if err = tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	return
+}
+
+// UnenrollUser 将用户移出评测项目，用户已有的做题记录保留
+func UnenrollUser(campaignID, userID int64) (err error) {
+	return db.Where("campaign_id = ? AND user_id = ?", campaignID, userID).
+		Delete(&models.CampaignUser{}).Error
+}
+
+// AssignQuizzes 将题目划入评测项目，campaignID为0时划回全局题库；任一题目不存在时不做修改
+// 返回修改前的题目，用于把未下线的题目移到新的题库集合
+func AssignQuizzes(campaignID int64, quizIds []int64) (old []models.Quiz, err error) {
+	if err = db.Where("id IN (?)", quizIds).Find(&old).Error; err != nil {
+		return nil, err
+	}
+	if len(old) < len(quizIds) {
+		return nil, ErrorQuizNotExist
+	}
+	if err = db.Model(&models.Quiz{}).
+		Where("id IN (?)", quizIds).
+		UpdateColumn("campaign_id", campaignID).Error; err != nil {
+		return nil, err
+	}
+	return old, nil
+}
@@ -0,0 +1,89 @@
+package mysql
+
+import (
+	"scgptEval/models"
+	"strings"
+	"time"
+)
+
+// consensusInsertBatch 写入共识标签时每条INSERT语句包含的行数
+const consensusInsertBatch = 500
+
+// ReplaceConsensusLabels 在同一个事务中用新计算的结果替换全部共识标签
+// 不再有答案的题目(如记录被删除)不会残留旧的共识标签
+func ReplaceConsensusLabels(labels []*models.ConsensusLabel) (err error) {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	if err = tx.Delete(&models.ConsensusLabel{}).Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	now := time.Now()
+	for i := 0; i < len(labels); i += consensusInsertBatch {
+		end := i + consensusInsertBatch
+		if end > len(labels) {
+			end = len(labels)
+		}
+		batch := labels[i:end]
+		values := make([]interface{}, 0, 6*len(batch))
+		for _, l := range batch {
+			l.UpdatedAt = now
+			values = append(values, l.QuizID, l.Method, l.Label, l.Confidence, l.Labels, l.UpdatedAt)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("(?, ?, ?, ?, ?, ?), ", len(batch)), ", ")
+		if err = tx.Exec("INSERT INTO consensus_labels (quiz_id, method, label, confidence, labels, updated_at) VALUES "+
+			placeholders, values...).Error; err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+	if err = tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	return
+}
+
+// GetConsensusLabels 获取一批题目的共识标签，返回题目id -> 方法 -> 共识标签
+func GetConsensusLabels(quizIds []int64) (labels map[int64]map[string]*models.ConsensusLabel, err error) {
+	var list []*models.ConsensusLabel
+	if len(quizIds) > 0 {
+		if err = db.Where("quiz_id IN (?)", quizIds).Find(&list).Error; err != nil {
+			return nil, err
+		}
+	}
+	labels = make(map[int64]map[string]*models.ConsensusLabel)
+	for _, l := range list {
+		if labels[l.QuizID] == nil {
+			labels[l.QuizID] = make(map[string]*models.ConsensusLabel)
+		}
+		labels[l.QuizID][l.Method] = l
+	}
+	return labels, nil
+}
+
+// ListConsensusLabels 分页获取共识标签，置信度低的在前；quizID为0或method为空时不筛选
+func ListConsensusLabels(page, size int, quizID int64, method string) (list []*models.ConsensusLabel, total int64, err error) {
+	queryDB := db.Model(&models.ConsensusLabel{})
+	if quizID != 0 {
+		queryDB = queryDB.Where("quiz_id = ?", quizID)
+	}
+	if method != "" {
+		queryDB = queryDB.Where("method = ?", method)
+	}
+	if err = queryDB.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	list = make([]*models.ConsensusLabel, 0, size)
+	if err = queryDB.Order("confidence, quiz_id, method").
+		Offset((page - 1) * size).
+		Limit(size).
+		Find(&list).Error; err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
@@ -0,0 +1,41 @@
+package mysql
+
+import (
+	"fmt"
+	"scgptEval/models"
+	"testing"
+)
+
+// TestReplaceConsensusLabels 标签数超过一条INSERT的行数时分批写入，再次替换时不残留旧标签
+func TestReplaceConsensusLabels(t *testing.T) {
+	openTestDB(t)
+	if err := db.AutoMigrate(&models.ConsensusLabel{}).Error; err != nil {
+		t.Fatalf("migrate failed: %v", err)
+	}
+	labels := make([]*models.ConsensusLabel, 0, consensusInsertBatch+1)
+	for i := 0; i <= consensusInsertBatch; i++ {
+		labels = append(labels, &models.ConsensusLabel{QuizID: int64(i + 1), Method: models.ConsensusMajority,
+			Label: fmt.Sprint(i), Confidence: 0.5, Labels: 3})
+	}
+	if err := ReplaceConsensusLabels(labels); err != nil {
+		t.Fatalf("ReplaceConsensusLabels() failed: %v", err)
+	}
+	got, err := GetConsensusLabels([]int64{1, int64(consensusInsertBatch + 1), 9999})
+	if err != nil {
+		t.Fatalf("GetConsensusLabels() failed: %v", err)
+	}
+	last := got[int64(consensusInsertBatch+1)][models.ConsensusMajority]
+	if len(got) != 2 || last == nil || last.Label != fmt.Sprint(consensusInsertBatch) || last.Labels != 3 {
+		t.Fatalf("GetConsensusLabels = %v", got)
+	}
+
+	if err = ReplaceConsensusLabels(labels[:1]); err != nil {
+		t.Fatalf("ReplaceConsensusLabels() failed: %v", err)
+	}
+	if got, err = GetConsensusLabels([]int64{1, 2}); err != nil || len(got) != 1 {
+		t.Fatalf("after replace = %v, %v, want only quiz 1", got, err)
+	}
+	if got, err = GetConsensusLabels(nil); err != nil || len(got) != 0 {
+		t.Fatalf("GetConsensusLabels(nil) = %v, %v, want empty", got, err)
+	}
+}
@@ -0,0 +1,51 @@
+package mysql
+
+import (
+	"errors"
+	"scgptEval/models"
+	"time"
+)
+
+var ErrorModelExist = errors.New("模型已存在")
+
+// CreateEvalModel 登记被评测的模型
+func CreateEvalModel(m *models.EvalModel) (err error) {
+	if res := db.Where("model_id = ?", m.ModelID).First(&models.EvalModel{}); res.RowsAffected > 0 {
+		return ErrorModelExist
+	}
+	m.CreatedAt = time.Now()
+	m.UpdatedAt = time.Now()
+	return db.Create(m).Error
+}
+
+// ListEvalModels 获取全部已登记的模型
+func ListEvalModels() (ms []models.EvalModel, err error) {
+	if err = db.Order("id").Find(&ms).Error; err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+// IterateComparisonAnswers 按记录id升序流式遍历模型对比题的答案及作答用户，fn返回错误时停止遍历
+func IterateComparisonAnswers(quizType string, fn func(recordID, userID int64, answer string) error) (err error) {
+	rows, err := db.Table("records AS r").
+		Select("r.id, r.user_id, r.selected_option").
+		Joins("JOIN quizzes AS q ON q.id = r.quiz_id").
+		Where("q.type = ?", quizType).
+		Order("r.id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var recordID, userID int64
+		var answer string
+		if err = rows.Scan(&recordID, &userID, &answer); err != nil {
+			return err
+		}
+		if err = fn(recordID, userID, answer); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
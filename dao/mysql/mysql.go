@@ -6,6 +6,8 @@ import (
 	"go.uber.org/zap"
 	"scgptEval/config"
 	"scgptEval/models"
+	"scgptEval/pkg/quizkind"
+	"strings"
 
 	_ "github.com/jinzhu/gorm/dialects/mysql"
 )
@@ -70,15 +72,63 @@ func checkDBExist(DbName string) {
 
 // 自动同步数据库，没有表就自动新建，必要时打开
 func syncTable() {
+	if err := prepareQuizHashIndex(); err != nil {
+		fmt.Println("同步题目内容哈希失败", err.Error())
+		return
+	}
 	res := db.AutoMigrate(
 		&models.User{},
 		&models.Quiz{},
 		&models.Record{},
+		&models.RecordRevision{},
+		&models.EvalModel{},
+		&models.UserTrust{},
+		&models.ConsensusLabel{},
+		&models.Campaign{},
+		&models.CampaignUser{},
+		&models.RankingSnapshot{},
 	)
 	if res.Error != nil {
 		fmt.Println("同步数据库字段失败", res.Error.Error())
 		return
 	}
+	// AutoMigrate不会修改已有字段，新增题目类型后需扩展ENUM取值
+	codes := quizkind.Codes()
+	quizTypeEnum := "ENUM('" + strings.Join(codes, "','") + "')"
+	if res = db.Model(&models.Quiz{}).ModifyColumn("type", quizTypeEnum); res.Error != nil {
+		fmt.Println("同步题目类型失败", res.Error.Error())
+		return
+	}
+	// 早期的答案字段为varchar(255)，放不下较长的自由文本答案
+	if res = db.Model(&models.Record{}).ModifyColumn("selected_option", "text"); res.Error != nil {
+		fmt.Println("同步答案字段失败", res.Error.Error())
+		return
+	}
+	if res = db.Model(&models.RecordRevision{}).ModifyColumn("selected_option", "text"); res.Error != nil {
+		fmt.Println("同步修改记录答案字段失败", res.Error.Error())
+		return
+	}
+}
+
+// prepareQuizHashIndex 题目内容哈希改为唯一索引前，将未计算的空哈希置为NULL，并清空重复题目中除最早一道外的哈希
+// 被清空的题目在下次导入时补齐哈希，与已有题目重复的会被跳过，仍保持为NULL
+func prepareQuizHashIndex() (err error) {
+	if !db.HasTable(&models.Quiz{}) || !db.Dialect().HasColumn("quizzes", "content_hash") ||
+		db.Dialect().HasIndex("quizzes", "uix_quizzes_content_hash") {
+		return nil
+	}
+	if err = db.Exec("UPDATE quizzes SET content_hash = NULL WHERE content_hash = ''").Error; err != nil {
+		return err
+	}
+	if err = db.Exec("UPDATE quizzes q JOIN (SELECT content_hash, MIN(id) AS id FROM quizzes " +
+		"WHERE content_hash IS NOT NULL GROUP BY content_hash HAVING COUNT(*) > 1) d " +
+		"ON q.content_hash = d.content_hash AND q.id <> d.id SET q.content_hash = NULL").Error; err != nil {
+		return err
+	}
+	if db.Dialect().HasIndex("quizzes", "idx_quizzes_content_hash") {
+		return db.Model(&models.Quiz{}).RemoveIndex("idx_quizzes_content_hash").Error
+	}
+	return nil
 }
 
 // 由于全局变量db是不对外暴露的，因此需要封装一个Close函数以便在main.go中调用db.Close()
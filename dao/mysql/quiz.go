@@ -2,6 +2,7 @@ package mysql
 
 import (
 	"errors"
+	mysqldriver "github.com/go-sql-driver/mysql"
 	"github.com/jinzhu/gorm"
 	"math/rand"
 	"scgptEval/models"
@@ -10,24 +11,45 @@ import (
 
 var (
 	ErrorQuizNotExist = errors.New("题目不存在")
+	ErrorQuizExist    = errors.New("题库中已有相同内容的题目")
 )
 
-// CheckQuizExist 检查提交的题目是否合法(存在)
+// isDuplicateKey 是否为违反唯一索引的错误
+func isDuplicateKey(err error) bool {
+	var me *mysqldriver.MySQLError
+	return errors.As(err, &me) && me.Number == 1062
+}
+
+// CheckQuizExist 检查提交的题目是否合法(存在且未下线)
 func CheckQuizExist(quizID int64) (err error) {
-	if res := db.Where("id = ?", quizID).First(&models.Quiz{}); res.RowsAffected == 0 {
+	if res := db.Where("id = ? AND status = ?", quizID, models.QuizStatusActive).
+		First(&models.Quiz{}); res.RowsAffected == 0 {
 		// 题目不存在
 		return ErrorQuizNotExist
 	}
 	return nil
 }
 
-// SubmitQuiz 提交题目
-func SubmitQuiz(userID, quizID int64, selectOptions string) (err error) {
+// GetQuiz 根据id获取未下线的题目
+func GetQuiz(quizID int64) (quiz *models.Quiz, err error) {
+	quiz = new(models.Quiz)
+	if res := db.Where("id = ? AND status = ?", quizID, models.QuizStatusActive).
+		First(quiz); res.RowsAffected == 0 {
+		if res.Error != nil && !errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return nil, res.Error
+		}
+		return nil, ErrorQuizNotExist
+	}
+	return quiz, nil
+}
+
+// SubmitQuiz 提交题目，goldCorrect为金标准题是否答对，非金标准题为nil
+func SubmitQuiz(userID, quizID int64, selectOptions string, goldCorrect *bool) (err error) {
 	if err = CheckQuizExist(quizID); err != nil {
 		// 检查题目是否真实存在，防止非法插入
 		return
 	}
-	// fixme 如果有重复提交的需求可改下面的逻辑
+	// 每道题只能提交一次，修改答案见ReviseRecord
 	if err = CheckRecordExist(userID, quizID); err != nil {
 		return
 	}
@@ -35,6 +57,7 @@ func SubmitQuiz(userID, quizID int64, selectOptions string) (err error) {
 		QuizID:       quizID,
 		UserID:       userID,
 		SelectOption: selectOptions,
+		GoldCorrect:  goldCorrect,
 		CreatedAt:    time.Now(),
 	}
 
@@ -69,25 +92,334 @@ func SubmitQuiz(userID, quizID int64, selectOptions string) (err error) {
 // GetQuizzes 根据id获取题目记录
 func GetQuizzes(ids []string) (quizzes []models.Quiz, err error) {
 	// 由于ids是quiz表主键切片，所以可直接这样查询
-	if err = db.Where(ids).Find(&quizzes).Error; err != nil {
+	if err = db.Where(ids).Where("status = ?", models.QuizStatusActive).
+		Find(&quizzes).Error; err != nil {
 		return nil, err
 	}
 	return quizzes, nil
 }
 
-// GetUntriedQuizzes 随机获取指定数量的未刷过题目记录
-func GetUntriedQuizzes(doneQuizIds []int64, num int) (quizzes []models.Quiz, err error) {
-	rand.Seed(time.Now().UnixNano())
+// targetExpr 题目实际的目标作答数，未单独设置时使用默认值
+const targetExpr = "IF(q.target_judgements > 0, q.target_judgements, ?)"
 
-	queryDB := db
+// untriedQuizQuery 评测项目中用户未刷过且仍可出题(未达到目标作答数)的非金标准题，j.judgements为题目已收到的作答数
+func untriedQuizQuery(campaignID int64, doneQuizIds []int64, defaultTarget int) *gorm.DB {
+	queryDB := db.Table("quizzes AS q").
+		Joins("LEFT JOIN (SELECT quiz_id, COUNT(*) AS judgements FROM records GROUP BY quiz_id) AS j ON j.quiz_id = q.id").
+		Where("q.status = ? AND q.is_gold = ? AND q.campaign_id = ?", models.QuizStatusActive, false, campaignID)
 	if len(doneQuizIds) > 0 {
-		queryDB = db.Where("id NOT IN (?)", doneQuizIds)
+		queryDB = queryDB.Where("q.id NOT IN (?)", doneQuizIds)
 	}
-	err = queryDB.Order(gorm.Expr("RAND()")).
+	return queryDB.Where(targetExpr+" = 0 OR IFNULL(j.judgements, 0) < "+targetExpr, defaultTarget, defaultTarget)
+}
+
+// GetUntriedQuizIDs 随机获取评测项目中指定数量的未刷过题目id
+func GetUntriedQuizIDs(campaignID int64, doneQuizIds []int64, num, defaultTarget int) (quizIds []int64, err error) {
+	rand.Seed(time.Now().UnixNano())
+
+	err = untriedQuizQuery(campaignID, doneQuizIds, defaultTarget).
+		Order(gorm.Expr("RAND()")).
 		Limit(num).
+		Pluck("q.id", &quizIds).Error
+	if err != nil {
+		return nil, err
+	}
+	return quizIds, nil
+}
+
+// GetNeediestUntriedQuizIDs 获取评测项目中指定数量的未刷过题目id，距目标作答数最远的题目优先
+// 不限作答数的题目按作答数从少到多排在有目标作答数的题目之后
+func GetNeediestUntriedQuizIDs(campaignID int64, doneQuizIds []int64, num, defaultTarget int) (quizIds []int64, err error) {
+	err = untriedQuizQuery(campaignID, doneQuizIds, defaultTarget).
+		Order(gorm.Expr(targetExpr+" - IFNULL(j.judgements, 0) DESC", defaultTarget)).
+		Order(gorm.Expr("RAND()")).
+		Limit(num).
+		Pluck("q.id", &quizIds).Error
+	if err != nil {
+		return nil, err
+	}
+	return quizIds, nil
+}
+
+// IterateUntriedCandidates 按id升序逐行读取评测项目中未刷过且仍可出题的题目信息，每pageSize道调用一次fn
+func IterateUntriedCandidates(campaignID int64, doneQuizIds []int64, defaultTarget, pageSize int,
+	fn func(page []*models.QuizCandidate) error) (err error) {
+	rows, err := untriedQuizQuery(campaignID, doneQuizIds, defaultTarget).
+		Select("q.id, q.type, q.priority, IFNULL(j.judgements, 0)").
+		Order("q.id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	page := make([]*models.QuizCandidate, 0, pageSize)
+	for rows.Next() {
+		c := new(models.QuizCandidate)
+		if err = rows.Scan(&c.ID, &c.Type, &c.Priority, &c.Judgements); err != nil {
+			return err
+		}
+		if page = append(page, c); len(page) >= pageSize {
+			if err = fn(page); err != nil {
+				return err
+			}
+			page = make([]*models.QuizCandidate, 0, pageSize)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	if len(page) > 0 {
+		return fn(page)
+	}
+	return nil
+}
+
+// GetQuizCandidates 获取指定题目的出题信息，不检查题目状态
+func GetQuizCandidates(quizIds []int64) (candidates []*models.QuizCandidate, err error) {
+	if len(quizIds) == 0 {
+		return nil, nil
+	}
+	rows, err := db.Table("quizzes").
+		Select("id, type, priority").
+		Where("id IN (?)", quizIds).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		c := new(models.QuizCandidate)
+		if err = rows.Scan(&c.ID, &c.Type, &c.Priority); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// CountUntriedQuizzes 统计评测项目中未刷过的题目数
+func CountUntriedQuizzes(campaignID int64, doneQuizIds []int64, defaultTarget int) (count int64, err error) {
+	if err = untriedQuizQuery(campaignID, doneQuizIds, defaultTarget).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetUntriedGoldQuizIDs 随机获取评测项目中指定数量的未刷过金标准题id
+func GetUntriedGoldQuizIDs(campaignID int64, doneQuizIds []int64, num int) (quizIds []int64, err error) {
+	queryDB := db.Model(&models.Quiz{}).
+		Where("status = ? AND is_gold = ? AND campaign_id = ?", models.QuizStatusActive, true, campaignID)
+	if len(doneQuizIds) > 0 {
+		queryDB = queryDB.Where("id NOT IN (?)", doneQuizIds)
+	}
+	if err = queryDB.Order(gorm.Expr("RAND()")).Limit(num).Pluck("id", &quizIds).Error; err != nil {
+		return nil, err
+	}
+	return quizIds, nil
+}
+
+// GetActiveGoldQuizIDs 获取全部未下线的金标准题id，按所属评测项目分组
+func GetActiveGoldQuizIDs() (quizIds map[int64][]int64, err error) {
+	rows, err := db.Model(&models.Quiz{}).
+		Select("id, campaign_id").
+		Where("status = ? AND is_gold = ?", models.QuizStatusActive, true).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	quizIds = make(map[int64][]int64)
+	for rows.Next() {
+		var id, campaignID int64
+		if err = rows.Scan(&id, &campaignID); err != nil {
+			return nil, err
+		}
+		quizIds[campaignID] = append(quizIds[campaignID], id)
+	}
+	return quizIds, rows.Err()
+}
+
+// CountJudgements 统计每道非金标准题已收到的作答数
+func CountJudgements() (counts map[int64]int64, err error) {
+	rows, err := db.Table("records AS r").
+		Select("r.quiz_id, COUNT(*)").
+		Joins("JOIN quizzes AS q ON q.id = r.quiz_id").
+		Where("q.is_gold = ?", false).
+		Group("r.quiz_id").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts = make(map[int64]int64)
+	for rows.Next() {
+		var quizID, count int64
+		if err = rows.Scan(&quizID, &count); err != nil {
+			return nil, err
+		}
+		counts[quizID] = count
+	}
+	return counts, rows.Err()
+}
+
+// CreateQuiz 新建题目，内容与已有题目相同时返回ErrorQuizExist
+func CreateQuiz(quiz *models.Quiz) (err error) {
+	quiz.Status = models.QuizStatusActive
+	quiz.CreatedAt = time.Now()
+	quiz.UpdatedAt = time.Now()
+	if err = db.Create(quiz).Error; isDuplicateKey(err) {
+		return ErrorQuizExist
+	}
+	return err
+}
+
+// UpdateQuiz 更新题目的类型、题干、选项、目标作答数、优先级、标准答案与所属评测项目，
+// 并将题目当前状态写回quiz.Status，返回题目原来所属的评测项目；内容与其他题目相同时返回ErrorQuizExist
+func UpdateQuiz(quiz *models.Quiz) (oldCampaignID int64, err error) {
+	var old models.Quiz
+	if res := db.Where("id = ?", quiz.ID).First(&old); res.RowsAffected == 0 {
+		return 0, ErrorQuizNotExist
+	}
+	quiz.Status = old.Status
+	err = db.Model(&models.Quiz{}).Where("id = ?", quiz.ID).Updates(map[string]interface{}{
+		"type":              quiz.Type,
+		"content":           quiz.Content,
+		"options":           quiz.Options,
+		"content_hash":      quiz.ContentHash,
+		"target_judgements": quiz.TargetJudgements,
+		"priority":          quiz.Priority,
+		"is_gold":           quiz.IsGold,
+		"gold_answer":       quiz.GoldAnswer,
+		"campaign_id":       quiz.CampaignID,
+	}).Error
+	if isDuplicateKey(err) {
+		return 0, ErrorQuizExist
+	}
+	return old.CampaignID, err
+}
+
+// RetireQuiz 下线题目：不再出题，但保留题目本身及其做题记录
+func RetireQuiz(quizID int64) (err error) {
+	res := db.Model(&models.Quiz{}).
+		Where("id = ? AND status = ?", quizID, models.QuizStatusActive).
+		Update("status", models.QuizStatusRetired)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrorQuizNotExist
+	}
+	return nil
+}
+
+// ListQuizzes 分页获取题目列表，quizType与status为空、campaignID为0时不作筛选
+func ListQuizzes(page, size int, quizType, status string, campaignID int64) (quizzes []models.Quiz, total int64, err error) {
+	queryDB := db.Model(&models.Quiz{})
+	if quizType != "" {
+		queryDB = queryDB.Where("type = ?", quizType)
+	}
+	if status != "" {
+		queryDB = queryDB.Where("status = ?", status)
+	}
+	if campaignID != 0 {
+		queryDB = queryDB.Where("campaign_id = ?", campaignID)
+	}
+	if err = queryDB.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = queryDB.Order("id").
+		Offset((page - 1) * size).
+		Limit(size).
 		Find(&quizzes).Error
 	if err != nil {
+		return nil, 0, err
+	}
+	return quizzes, total, nil
+}
+
+// GetActiveQuizTargets 获取全部未下线的非金标准题id及其单独设置的目标作答数(0表示使用默认值)与所属评测项目
+func GetActiveQuizTargets() (targets map[int64]int, campaigns map[int64]int64, err error) {
+	rows, err := db.Model(&models.Quiz{}).
+		Select("id, target_judgements, campaign_id").
+		Where("status = ? AND is_gold = ?", models.QuizStatusActive, false).Rows()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	targets = make(map[int64]int)
+	campaigns = make(map[int64]int64)
+	for rows.Next() {
+		var id, campaignID int64
+		var target int
+		if err = rows.Scan(&id, &target, &campaignID); err != nil {
+			return nil, nil, err
+		}
+		targets[id] = target
+		if campaignID != 0 {
+			campaigns[id] = campaignID
+		}
+	}
+	return targets, campaigns, rows.Err()
+}
+
+// BatchCreateQuizzes 在同一个事务中批量插入题目，任一题目与已有题目重复时整批回滚并返回ErrorQuizExist
+func BatchCreateQuizzes(quizzes []*models.Quiz) (err error) {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	now := time.Now()
+	for _, quiz := range quizzes {
+		quiz.Status = models.QuizStatusActive
+		quiz.CreatedAt = now
+		quiz.UpdatedAt = now
+		if err = tx.Create(quiz).Error; err != nil {
+			tx.Rollback()
+			if isDuplicateKey(err) {
+				err = ErrorQuizExist
+			}
+			return
+		}
+	}
+	if err = tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	return
+}
+
+// GetExistingContentHashes 返回给定哈希中已存在于题库的部分
+func GetExistingContentHashes(hashes []string) (exist map[string]bool, err error) {
+	exist = make(map[string]bool)
+	if len(hashes) == 0 {
+		return exist, nil
+	}
+	var found []string
+	if err = db.Model(&models.Quiz{}).
+		Where("content_hash IN (?)", hashes).
+		Pluck("content_hash", &found).Error; err != nil {
+		return nil, err
+	}
+	for _, h := range found {
+		exist[h] = true
+	}
+	return exist, nil
+}
+
+// GetQuizzesWithoutHash 获取尚未计算内容哈希的题目(历史数据)
+func GetQuizzesWithoutHash() (quizzes []models.Quiz, err error) {
+	if err = db.Where("content_hash = '' OR content_hash IS NULL").
+		Find(&quizzes).Error; err != nil {
 		return nil, err
 	}
 	return quizzes, nil
 }
+
+// UpdateQuizHash 更新题目的内容哈希，已有其他题目使用该哈希时返回ErrorQuizExist
+func UpdateQuizHash(quizID int64, hash string) (err error) {
+	err = db.Model(&models.Quiz{}).
+		Where("id = ?", quizID).
+		UpdateColumn("content_hash", hash).Error
+	if isDuplicateKey(err) {
+		return ErrorQuizExist
+	}
+	return err
+}
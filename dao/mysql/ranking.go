@@ -0,0 +1,75 @@
+package mysql
+
+import (
+	"errors"
+	"github.com/jinzhu/gorm"
+	"scgptEval/models"
+	"time"
+)
+
+// RankingArchived 检查评测项目在统计窗口的排行榜是否已存档
+func RankingArchived(period, periodKey string, campaignID int64) (archived bool, err error) {
+	var count int64
+	if err = db.Model(&models.RankingSnapshot{}).
+		Where("period = ? AND period_key = ? AND campaign_id = ?", period, periodKey, campaignID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// SaveRankingSnapshot 存档一个统计窗口的排行榜，已存档的名次会被覆盖
+func SaveRankingSnapshot(list []*models.RankingSnapshot) (err error) {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	now := time.Now()
+	for _, s := range list {
+		s.CreatedAt = now
+		if err = tx.Save(s).Error; err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+	if err = tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	return
+}
+
+// GetRankingSnapshot 获取评测项目在统计窗口的排行榜存档中第offset名之后的至多limit名，并返回存档的名次数
+func GetRankingSnapshot(period, periodKey string, campaignID int64, offset, limit int64) (list []*models.RankingSnapshot, total int64, err error) {
+	queryDB := db.Model(&models.RankingSnapshot{}).
+		Where("period = ? AND period_key = ? AND campaign_id = ?", period, periodKey, campaignID)
+	if err = queryDB.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	list = make([]*models.RankingSnapshot, 0, limit)
+	if err = queryDB.Order("`rank`").
+		Offset(offset).
+		Limit(limit).
+		Find(&list).Error; err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+// GetSnapshotRank 获取用户在统计窗口的排行榜存档中的名次
+// 存档包含窗口内有刷题量的全部用户，用户在窗口内没有刷题时返回0
+func GetSnapshotRank(period, periodKey string, campaignID, userID int64) (rank int64, err error) {
+	s := new(models.RankingSnapshot)
+	res := db.Select("`rank`").
+		Where("period = ? AND period_key = ? AND campaign_id = ? AND user_id = ?", period, periodKey, campaignID, userID).
+		First(s)
+	if res.RowsAffected == 0 {
+		if res.Error != nil && !errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return 0, res.Error
+		}
+		return 0, nil
+	}
+	return int64(s.Rank), nil
+}
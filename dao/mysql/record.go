@@ -1,14 +1,18 @@
 package mysql
 
 import (
+	"database/sql"
 	"errors"
 	"github.com/jinzhu/gorm"
 	"scgptEval/models"
+	"time"
 )
 
 var (
 	ErrorRecordExist    = errors.New("记录已存在")
 	ErrorRecordNotExist = errors.New("记录不存在")
+
+	ErrorRevisionExpired = errors.New("已超过可修改答案的时限")
 )
 
 // CheckRecordExist 检查是否已有做题记录
@@ -34,3 +38,242 @@ func GetDoneQuizID(userID int64) (quizIds []int64, err error) {
 	}
 	return quizIds, nil
 }
+
+// filterRecords 按筛选条件过滤联表查询 records AS r JOIN quizzes AS q
+func filterRecords(queryDB *gorm.DB, filter *models.RecordFilter) *gorm.DB {
+	if !filter.Start.IsZero() {
+		queryDB = queryDB.Where("r.created_at >= ?", filter.Start)
+	}
+	if !filter.End.IsZero() {
+		queryDB = queryDB.Where("r.created_at < ?", filter.End)
+	}
+	if filter.QuizType != "" {
+		queryDB = queryDB.Where("q.type = ?", filter.QuizType)
+	}
+	if filter.QuizID != 0 {
+		queryDB = queryDB.Where("r.quiz_id = ?", filter.QuizID)
+	}
+	if filter.CampaignID != 0 {
+		queryDB = queryDB.Where("q.campaign_id = ?", filter.CampaignID)
+	}
+	if filter.UserID != 0 {
+		queryDB = queryDB.Where("r.user_id = ?", filter.UserID)
+	}
+	return queryDB
+}
+
+// IterateRecordDetails 按记录id升序流式遍历符合条件的做题记录(联表题目内容)，fn返回错误时停止遍历
+func IterateRecordDetails(filter *models.RecordFilter, fn func(d *models.RecordDetail) error) (err error) {
+	rows, err := filterRecords(recordDetailQuery(), filter).Order("r.id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		d, err := scanRecordDetail(rows)
+		if err != nil {
+			return err
+		}
+		if err = fn(d); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ReviseRecord 修改用户在题目上的答案：被替换的答案写入修改记录，做题记录改为新的答案并累加修改次数
+// 只能修改notBefore及之后提交的做题记录；答案未变化时不做修改并返回false
+func ReviseRecord(userID, quizID int64, selectOptions string, goldCorrect *bool, notBefore time.Time) (revised bool, err error) {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// 锁定做题记录，避免并发修改时丢失修改记录
+	record := new(models.Record)
+	if res := tx.Set("gorm:query_option", "FOR UPDATE").
+		Where("user_id = ? AND quiz_id = ?", userID, quizID).First(record); res.RowsAffected == 0 {
+		tx.Rollback()
+		if res.Error != nil && !errors.Is(res.Error, gorm.ErrRecordNotFound) {
+			return false, res.Error
+		}
+		return false, ErrorRecordNotExist
+	}
+	if record.CreatedAt.Before(notBefore) {
+		tx.Rollback()
+		return false, ErrorRevisionExpired
+	}
+	if record.SelectOption == selectOptions {
+		tx.Rollback()
+		return false, nil
+	}
+
+	// 被替换的答案提交于上一次修改时，未修改过时即为做题记录的提交时间
+	answeredAt := record.CreatedAt
+	last := new(models.RecordRevision)
+	if res := tx.Where("record_id = ?", record.ID).Order("id DESC").First(last); res.RowsAffected > 0 {
+		answeredAt = last.CreatedAt
+	} else if res.Error != nil && !errors.Is(res.Error, gorm.ErrRecordNotFound) {
+		tx.Rollback()
+		return false, res.Error
+	}
+	now := time.Now()
+	revision := &models.RecordRevision{
+		RecordID:     record.ID,
+		SelectOption: record.SelectOption,
+		GoldCorrect:  record.GoldCorrect,
+		AnsweredAt:   answeredAt,
+		CreatedAt:    now,
+	}
+	if err = tx.Create(revision).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if err = tx.Model(&models.Record{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+		"selected_option": selectOptions,
+		"gold_correct":    goldCorrect,
+		"revisions":       gorm.Expr("revisions + 1"),
+	}).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if err = tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	return true, nil
+}
+
+// GetRecordRevisions 获取做题记录被修改前的各个答案，按修改时间排列
+func GetRecordRevisions(recordID int64) (list []*models.RecordRevision, err error) {
+	list = make([]*models.RecordRevision, 0)
+	if err = db.Where("record_id = ?", recordID).Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// recordDetailQuery 做题记录联表题目内容的查询
+func recordDetailQuery() *gorm.DB {
+	return db.Table("records AS r").
+		Select("r.id, r.quiz_id, q.type, q.content, q.options, r.user_id, r.selected_option, r.created_at").
+		Joins("JOIN quizzes AS q ON q.id = r.quiz_id")
+}
+
+// scanRecordDetail 读取recordDetailQuery查询到的一行
+func scanRecordDetail(rows *sql.Rows) (d *models.RecordDetail, err error) {
+	d = new(models.RecordDetail)
+	if err = rows.Scan(&d.RecordID, &d.QuizID, &d.QuizType, &d.Content, &d.Options,
+		&d.UserID, &d.SelectOption, &d.CreatedAt); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ListUserRecords 按提交时间从新到旧获取用户符合条件的至多limit条做题记录(联表题目内容)
+// beforeID非0时只获取排在(before, beforeID)之后的记录，即提交时间更早或同一时间id更小的记录
+func ListUserRecords(filter *models.RecordFilter, before time.Time, beforeID int64, limit int) (list []*models.RecordDetail, err error) {
+	queryDB := filterRecords(recordDetailQuery(), filter)
+	if beforeID != 0 {
+		queryDB = queryDB.Where("r.created_at < ? OR (r.created_at = ? AND r.id < ?)", before, before, beforeID)
+	}
+	rows, err := queryDB.Order("r.created_at DESC, r.id DESC").Limit(limit).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list = make([]*models.RecordDetail, 0, limit)
+	for rows.Next() {
+		d, err := scanRecordDetail(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
+
+// GetUserRecord 获取用户的一条做题记录(联表题目内容)，不属于该用户的记录视为不存在
+func GetUserRecord(userID, recordID int64) (d *models.RecordDetail, err error) {
+	rows, err := recordDetailQuery().Where("r.id = ? AND r.user_id = ?", recordID, userID).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrorRecordNotExist
+	}
+	return scanRecordDetail(rows)
+}
+
+// IterateAnswers 按题目id流式遍历符合条件的答案，同一道题的答案相邻，fn返回错误时停止遍历
+func IterateAnswers(filter *models.RecordFilter, fn func(quizID int64, quizType string, userID int64, answer string) error) (err error) {
+	queryDB := db.Table("records AS r").
+		Select("r.quiz_id, q.type, r.user_id, r.selected_option").
+		Joins("JOIN quizzes AS q ON q.id = r.quiz_id")
+	rows, err := filterRecords(queryDB, filter).Order("r.quiz_id, r.id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var quizID, userID int64
+		var quizType, answer string
+		if err = rows.Scan(&quizID, &quizType, &userID, &answer); err != nil {
+			return err
+		}
+		if err = fn(quizID, quizType, userID, answer); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// goldAccuracyQuery 按用户汇总金标准题的作答数与答对数，userID为0时不筛选用户
+func goldAccuracyQuery(userID, minAnswered int64) *gorm.DB {
+	queryDB := db.Table("records AS r").
+		Joins("JOIN users AS u ON u.id = r.user_id").
+		Where("r.gold_correct IS NOT NULL")
+	if userID != 0 {
+		queryDB = queryDB.Where("r.user_id = ?", userID)
+	}
+	return queryDB.Group("r.user_id, u.username").Having("COUNT(*) >= ?", minAnswered)
+}
+
+// ListGoldAccuracy 分页获取用户在金标准题上的正确率，正确率低的用户在前
+// 只统计金标准题作答数不少于minAnswered的用户
+func ListGoldAccuracy(page, size int, userID, minAnswered int64) (list []*models.GoldAccuracy, total int64, err error) {
+	counted := goldAccuracyQuery(userID, minAnswered).Select("r.user_id")
+	if err = db.Raw("SELECT COUNT(*) FROM (?) AS g", counted.QueryExpr()).Row().Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := goldAccuracyQuery(userID, minAnswered).
+		Select("r.user_id, u.username, COUNT(*), SUM(r.gold_correct)").
+		Order("SUM(r.gold_correct) / COUNT(*)").
+		Order("COUNT(*) DESC").
+		Offset((page - 1) * size).
+		Limit(size).Rows()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	list = make([]*models.GoldAccuracy, 0, size)
+	for rows.Next() {
+		a := new(models.GoldAccuracy)
+		if err = rows.Scan(&a.UserID, &a.Username, &a.Answered, &a.Correct); err != nil {
+			return nil, 0, err
+		}
+		if a.Answered > 0 {
+			a.Accuracy = float64(a.Correct) / float64(a.Answered)
+		}
+		list = append(list, a)
+	}
+	return list, total, rows.Err()
+}
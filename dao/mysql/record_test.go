@@ -0,0 +1,89 @@
+package mysql
+
+import (
+	"errors"
+	"scgptEval/models"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// openTestDB 使用内存中的sqlite代替mysql，只建出做题记录查询用到的字段
+func openTestDB(t *testing.T) {
+	var err error
+	if db, err = gorm.Open("sqlite3", ":memory:"); err != nil {
+		t.Fatalf("open sqlite failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, stmt := range []string{
+		"CREATE TABLE quizzes (id INTEGER PRIMARY KEY, type TEXT, content TEXT, options TEXT, campaign_id INTEGER)",
+		"CREATE TABLE records (id INTEGER PRIMARY KEY, quiz_id INTEGER, user_id INTEGER, selected_option TEXT, created_at DATETIME)",
+	} {
+		if err = db.Exec(stmt).Error; err != nil {
+			t.Fatalf("create table failed: %v", err)
+		}
+	}
+}
+
+func insertRecord(t *testing.T, id, userID int64, at time.Time) {
+	if err := db.Exec("INSERT INTO quizzes (id, type, content, options, campaign_id) VALUES (?, 'single', '', '[]', 0)", id).Error; err != nil {
+		t.Fatalf("insert quiz failed: %v", err)
+	}
+	if err := db.Exec("INSERT INTO records (id, quiz_id, user_id, selected_option, created_at) VALUES (?, ?, ?, '\"a\"', ?)",
+		id, id, userID, at.UTC()).Error; err != nil {
+		t.Fatalf("insert record failed: %v", err)
+	}
+}
+
+// TestListUserRecordsTie 提交时间相同的记录跨页时按id继续，不重复也不遗漏
+func TestListUserRecordsTie(t *testing.T) {
+	openTestDB(t)
+	at := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	insertRecord(t, 1, 1, at.Add(-time.Second))
+	insertRecord(t, 2, 1, at)
+	insertRecord(t, 3, 1, at)
+	insertRecord(t, 4, 1, at)
+	insertRecord(t, 5, 2, at) // 其他用户的记录
+
+	filter := &models.RecordFilter{UserID: 1}
+	var got []int64
+	var before time.Time
+	var beforeID int64
+	for page := 0; page < 5; page++ {
+		list, err := ListUserRecords(filter, before, beforeID, 2)
+		if err != nil {
+			t.Fatalf("ListUserRecords() failed: %v", err)
+		}
+		if len(list) == 0 {
+			break
+		}
+		for _, d := range list {
+			got = append(got, d.RecordID)
+		}
+		last := list[len(list)-1]
+		before, beforeID = last.CreatedAt.UTC(), last.RecordID
+	}
+	want := []int64{4, 3, 2, 1}
+	if len(got) != len(want) {
+		t.Fatalf("pages = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("pages = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestGetUserRecordOwnership(t *testing.T) {
+	openTestDB(t)
+	insertRecord(t, 1, 1, time.Now())
+	if d, err := GetUserRecord(1, 1); err != nil || d.RecordID != 1 {
+		t.Fatalf("GetUserRecord(owner) = %+v, %v", d, err)
+	}
+	// 其他用户的记录视为不存在
+	if _, err := GetUserRecord(2, 1); !errors.Is(err, ErrorRecordNotExist) {
+		t.Fatalf("GetUserRecord(other user) error = %v, want ErrorRecordNotExist", err)
+	}
+}
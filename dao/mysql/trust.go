@@ -0,0 +1,147 @@
+package mysql
+
+import (
+	"scgptEval/models"
+	"time"
+)
+
+// CountGoldAnswers 统计每个用户作答的金标准题数与答对数
+func CountGoldAnswers() (answered, correct map[int64]int64, err error) {
+	rows, err := db.Model(&models.Record{}).
+		Select("user_id, COUNT(*), SUM(gold_correct)").
+		Where("gold_correct IS NOT NULL").
+		Group("user_id").Rows()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	answered, correct = make(map[int64]int64), make(map[int64]int64)
+	for rows.Next() {
+		var userID, n, c int64
+		if err = rows.Scan(&userID, &n, &c); err != nil {
+			return nil, nil, err
+		}
+		answered[userID], correct[userID] = n, c
+	}
+	return answered, correct, rows.Err()
+}
+
+// IterateQuizAnswers 按题目id流式遍历非金标准题的答案，同一道题的答案相邻，fn返回错误时停止遍历
+// excludeTypes中的题型(如自由文本)不参与遍历
+func IterateQuizAnswers(excludeTypes []string, fn func(quizID int64, quizType string, userID int64, answer string) error) (err error) {
+	queryDB := db.Table("records AS r").
+		Select("r.quiz_id, q.type, r.user_id, r.selected_option").
+		Joins("JOIN quizzes AS q ON q.id = r.quiz_id").
+		Where("q.is_gold = ?", false)
+	if len(excludeTypes) > 0 {
+		queryDB = queryDB.Where("q.type NOT IN (?)", excludeTypes)
+	}
+	rows, err := queryDB.Order("r.quiz_id, r.id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var quizID, userID int64
+		var quizType, answer string
+		if err = rows.Scan(&quizID, &quizType, &userID, &answer); err != nil {
+			return err
+		}
+		if err = fn(quizID, quizType, userID, answer); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// IterateSubmissionTimes 按用户、提交时间流式遍历全部做题记录的提交时间，fn返回错误时停止遍历
+func IterateSubmissionTimes(fn func(userID int64, createdAt time.Time) error) (err error) {
+	rows, err := db.Model(&models.Record{}).
+		Select("user_id, created_at").
+		Order("user_id, created_at").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID int64
+		var createdAt time.Time
+		if err = rows.Scan(&userID, &createdAt); err != nil {
+			return err
+		}
+		if err = fn(userID, createdAt); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// SaveUserTrusts 在同一个事务中写入(或覆盖)用户的信任分
+func SaveUserTrusts(trusts []*models.UserTrust) (err error) {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+	now := time.Now()
+	for _, t := range trusts {
+		t.UpdatedAt = now
+		if err = tx.Save(t).Error; err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+	if err = tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	return
+}
+
+// GetTrustScores 获取全部已计算的用户信任分
+func GetTrustScores() (scores map[int64]float64, err error) {
+	rows, err := db.Model(&models.UserTrust{}).Select("user_id, score").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	scores = make(map[int64]float64)
+	for rows.Next() {
+		var userID int64
+		var score float64
+		if err = rows.Scan(&userID, &score); err != nil {
+			return nil, err
+		}
+		scores[userID] = score
+	}
+	return scores, rows.Err()
+}
+
+// ListUserTrusts 分页获取用户信任分，信任分低的用户在前
+func ListUserTrusts(page, size int) (list []*models.UserTrustDetail, total int64, err error) {
+	if err = db.Model(&models.UserTrust{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	rows, err := db.Table("user_trusts AS t").
+		Select("t.user_id, t.score, t.gold_answered, t.gold_correct, t.consensus_answered, t.consensus_agreed, " +
+			"t.submissions, t.fast_submissions, t.updated_at, u.username, u.status").
+		Joins("JOIN users AS u ON u.id = t.user_id").
+		Order("t.score, t.user_id").
+		Offset((page - 1) * size).
+		Limit(size).Rows()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	list = make([]*models.UserTrustDetail, 0, size)
+	for rows.Next() {
+		d := new(models.UserTrustDetail)
+		if err = rows.Scan(&d.UserID, &d.Score, &d.GoldAnswered, &d.GoldCorrect, &d.ConsensusAnswered,
+			&d.ConsensusAgreed, &d.Submissions, &d.FastSubmissions, &d.UpdatedAt, &d.Username, &d.Status); err != nil {
+			return nil, 0, err
+		}
+		list = append(list, d)
+	}
+	return list, total, rows.Err()
+}
@@ -1,32 +1,22 @@
 package mysql
 
 import (
-	"crypto/md5"
-	"encoding/hex"
 	"errors"
 	"github.com/jinzhu/gorm"
 	"go.uber.org/zap"
 	"scgptEval/models"
-	"scgptEval/pkg/jwt"
+	"scgptEval/pkg/password"
 	"scgptEval/pkg/snowflake"
 	"time"
 )
 
-const secret = "moker_reader"
-
 var (
 	ErrorUserExist       = errors.New("用户已存在")
 	ErrorUserNotExist    = errors.New("用户不存在")
 	ErrorInvalidPassword = errors.New("密码错误")
+	ErrorUserSuspended   = errors.New("用户已被暂停")
 )
 
-// encryptPassword 密码加密
-func encryptPassword(oPassword string) string {
-	h := md5.New()
-	h.Write([]byte(secret))
-	return hex.EncodeToString(h.Sum([]byte(oPassword)))
-}
-
 // CheckUserExist 检查指定用户名的用户是否已存在
 func CheckUserExist(username string) (err error) {
 	if res := db.Where("username = ?", username).First(&models.User{}); res.RowsAffected > 0 {
@@ -36,17 +26,24 @@ func CheckUserExist(username string) (err error) {
 	return nil
 }
 
-func SignUp(username, password string) (userID int64, err error) {
+func SignUp(username, pwd string) (userID int64, err error) {
 	if err = CheckUserExist(username); err != nil {
 		return
 	}
+	// 数据库不存储明文密码
+	encoded, err := password.Hash(pwd)
+	if err != nil {
+		return
+	}
 	// 雪花算法生成uid
 	userID = snowflake.GenID()
 	// 构造一个user实例并插入
 	user := &models.User{
 		ID:          userID,
 		Username:    username,
-		Password:    encryptPassword(password), // 数据库不存储明文密码
+		Password:    encoded,
+		Role:        models.RoleAnnotator,
+		Status:      models.UserStatusActive,
 		Amount:      0,
 		FinalQATime: time.Now(), // 最后刷题时间可以不从当前时间为起始
 		CreatedAt:   time.Now(),
@@ -58,31 +55,70 @@ func SignUp(username, password string) (userID int64, err error) {
 	return
 }
 
-// LogIn 用户登录
-func LogIn(user *models.User) (aToken, rToken string, err error) {
+// LogIn 用户登录：校验用户名与密码，成功后user被填充为数据库中的完整记录
+func LogIn(user *models.User) (err error) {
 	oPassword := user.Password
 	if err = db.Where("username = ?", user.Username).First(user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			err = ErrorUserNotExist
 		}
-		return "", "", err
+		return err
 	}
 	// 判断密码是否正确
-	password := encryptPassword(oPassword)
-	if password != user.Password {
-		return "", "", ErrorInvalidPassword
-	}
-	// 通过登录验证，生成JWT token
-	if aToken, rToken, err = jwt.GenToken(user.ID, user.Username); err != nil {
-		// 过了登录验证但token生成失败，打log
-		zap.L().Error("jwt.GenToken() failed",
-			zap.Int64("userID", user.ID),
-			zap.String("username", user.Username),
-			zap.Error(err))
-		return "", "", err
+	ok, rehash, err := password.Verify(oPassword, user.Password)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrorInvalidPassword
+	}
+	if user.Status == models.UserStatusSuspended {
+		return ErrorUserSuspended
+	}
+	// 旧算法(或旧参数)生成的密码哈希，登录成功后透明升级为当前算法
+	if rehash {
+		upgradePassword(user, oPassword)
+	}
+	return nil
+}
+
+// GetUserByID 根据id获取用户
+func GetUserByID(userID int64) (user *models.User, err error) {
+	user = new(models.User)
+	if err = db.Where("id = ?", userID).First(user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = ErrorUserNotExist
+		}
+		return nil, err
 	}
+	return user, nil
+}
 
-	return aToken, rToken, nil
+// GetUserByName 根据用户名获取用户
+func GetUserByName(username string) (user *models.User, err error) {
+	user = new(models.User)
+	if err = db.Where("username = ?", username).First(user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = ErrorUserNotExist
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// upgradePassword 使用当前算法重新哈希并保存用户密码，失败只打log不影响登录
+func upgradePassword(user *models.User, oPassword string) {
+	encoded, err := password.Hash(oPassword)
+	if err == nil {
+		err = db.Model(&models.User{}).
+			Where("id = ?", user.ID).
+			Update("password", encoded).Error
+	}
+	if err != nil {
+		zap.L().Error("upgradePassword() failed", zap.Int64("userID", user.ID), zap.Error(err))
+		return
+	}
+	user.Password = encoded
 }
 
 // UpdateUserName 更新用户名
@@ -97,3 +133,60 @@ func UpdateUserName(userID int64, newName string) (err error) {
 	}
 	return nil
 }
+
+// UpdateUserRole 更新用户角色，返回角色是否发生了变化
+func UpdateUserRole(userID int64, role string) (changed bool, err error) {
+	res := db.Model(&models.User{}).
+		Where("id = ? AND role <> ?", userID, role).
+		Update("role", role)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		// 用户不存在或角色未变化
+		if _, err = GetUserByID(userID); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// UpdateUserStatus 更新用户状态，返回状态是否发生了变化
+func UpdateUserStatus(userID int64, status string) (changed bool, err error) {
+	res := db.Model(&models.User{}).
+		Where("id = ? AND status <> ?", userID, status).
+		Update("status", status)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		// 用户不存在或状态未变化
+		if _, err = GetUserByID(userID); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// SuspendAnnotators 暂停给定用户中角色为标注员且未被暂停的用户，返回实际被暂停的用户id
+func SuspendAnnotators(userIds []int64) (suspended []int64, err error) {
+	if len(userIds) == 0 {
+		return nil, nil
+	}
+	if err = db.Model(&models.User{}).
+		Where("id IN (?) AND role = ? AND status = ?", userIds, models.RoleAnnotator, models.UserStatusActive).
+		Pluck("id", &suspended).Error; err != nil {
+		return nil, err
+	}
+	if len(suspended) == 0 {
+		return nil, nil
+	}
+	if err = db.Model(&models.User{}).
+		Where("id IN (?)", suspended).
+		Update("status", models.UserStatusSuspended).Error; err != nil {
+		return nil, err
+	}
+	return suspended, nil
+}
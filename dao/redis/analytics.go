@@ -0,0 +1,22 @@
+package redis
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// GetAgreement 获取缓存的一致性指标，未缓存时返回空串
+func GetAgreement(key string) (data string, err error) {
+	data, err = rdb.Get(KeyAgreementStringPrefix + key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return data, err
+}
+
+// SetAgreement 缓存一致性指标
+func SetAgreement(key, data string, expiration time.Duration) (err error) {
+	return rdb.Set(KeyAgreementStringPrefix+key, data, expiration).Err()
+}
@@ -0,0 +1,53 @@
+package redis
+
+import (
+	"errors"
+	"github.com/go-redis/redis"
+)
+
+// AddGoldQuizzes 将金标准题加入评测项目的金标准题集合
+func AddGoldQuizzes(campaignID int64, quizIds ...int64) (err error) {
+	if len(quizIds) == 0 {
+		return nil
+	}
+	members := make([]interface{}, 0, len(quizIds))
+	for _, id := range quizIds {
+		members = append(members, id)
+	}
+	return rdb.SAdd(campaignKey(KeyQuizGoldSet, campaignID), members...).Err()
+}
+
+// ResetGoldQuizzes 重建各评测项目的金标准题集合，quizIds为评测项目id -> 未下线的金标准题id
+func ResetGoldQuizzes(quizIds map[int64][]int64) (err error) {
+	pipeline := rdb.TxPipeline()
+	for campaignID, ids := range quizIds {
+		key := campaignKey(KeyQuizGoldSet, campaignID)
+		pipeline.Del(key)
+		if len(ids) > 0 {
+			members := make([]interface{}, 0, len(ids))
+			for _, id := range ids {
+				members = append(members, id)
+			}
+			pipeline.SAdd(key, members...)
+		}
+	}
+	_, err = pipeline.Exec()
+	return err
+}
+
+// SampleUntriedGold 随机获取用户在评测项目中未刷过的至多num个金标准题id
+func SampleUntriedGold(campaignID int64, userID string, num int64) (quizIds []string, err error) {
+	tmpKey := campaignKey(KeyUserUntriedTmpPrefix, campaignID) + userID
+	pipeline := rdb.TxPipeline()
+	exists := pipeline.Exists(campaignKey(KeyQuizBaseSyncedString, campaignID))
+	pipeline.SDiffStore(tmpKey, campaignKey(KeyQuizGoldSet, campaignID), KeyUserQuizSetPrefix+userID)
+	members := pipeline.SRandMemberN(tmpKey, num)
+	pipeline.Del(tmpKey)
+	if _, err = pipeline.Exec(); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	if exists.Val() == 0 {
+		return nil, ErrorQuizBaseNotExist
+	}
+	return members.Val(), nil
+}
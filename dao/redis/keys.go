@@ -1,11 +1,48 @@
 package redis
 
+import (
+	"strconv"
+	"strings"
+)
+
 // 存放redis key
 
 // redis key 尽量使用命名空间的方式(例如:分割)，方便查询和区分
 const (
-	KeyUserScoreZSet      = "scgpteval:user:score" // zset; 存储每个用户的刷题量
-	KeyUserQuizSetPrefix  = "scgpteval:user:quiz:" // set; 存储某个用户刷过的题，后接用户id
-	KeyUserInfoHashPrefix = "scgpteval:user:info:" // hash; 存储某个用户的信息，后接用户id
-	KeyQuizBaseSet        = "scgpteval:quiz:base"  // set; 记录指定社区下的帖子，后面的参数为社区name
+	KeyUserScoreZSet          = "scgpteval:user:score"        // zset; 存储每个用户的刷题量
+	KeyUserWeightedZSet       = "scgpteval:user:weighted"     // zset; 存储每个用户按信任分加权的刷题量
+	KeyUserTrustZSet          = "scgpteval:user:trust"        // zset; 存储每个用户的信任分，未计算过的用户视为1
+	KeyUserQuizSetPrefix      = "scgpteval:user:quiz:"        // set; 存储某个用户刷过的题及占位成员0，后接用户id
+	KeyUserInfoHashPrefix     = "scgpteval:user:info:"        // hash; 存储某个用户的信息，后接用户id
+	KeyUserSessionZSetPrefix  = "scgpteval:user:session:"     // zset; 存储某个用户的登录会话id及登录时间，后接用户id
+	KeyQuizBaseSet            = "scgpteval:quiz:base"         // set; 存储题库中可出题(未下线且未达到目标作答数)题目的id
+	KeyQuizBaseSyncedString   = "scgpteval:quiz:base:synced"  // string; 题库集合最近一次重建的时间戳，不存在时需重建题库集合
+	KeyUserUntriedTmpPrefix   = "scgpteval:tmp:untried:"      // set; 出题时临时存放某个用户未刷过的题目，用完即删，后接用户id
+	KeyQuizJudgementZSet      = "scgpteval:quiz:judgement"    // zset; 存储每道题已收到的作答数
+	KeyQuizTargetHash         = "scgpteval:quiz:target"       // hash; 存储单独设置了目标作答数的题目及其目标作答数
+	KeyQuizDeficitZSet        = "scgpteval:quiz:deficit"      // zset; 存储题库集合中每道题距目标作答数的差值，不限作答数的题目为作答数的相反数
+	KeyQuizLeaseZSetPrefix    = "scgpteval:quiz:lease:"       // zset; 存储某道题当前的租约，成员为用户id，分数为到期时间戳，后接题目id
+	KeyUserLeaseZSetPrefix    = "scgpteval:user:lease:"       // zset; 存储某个用户持有的租约，成员为题目id，分数为到期时间戳，后接用户id
+	KeyQuizGoldSet            = "scgpteval:quiz:gold"         // set; 存储未下线的金标准题id，金标准题不在题库集合中
+	KeyModelLeaderboardString = "scgpteval:model:leaderboard" // string; 缓存模型排行榜的JSON
+	KeyAgreementStringPrefix  = "scgpteval:agreement:"        // string; 缓存一致性指标的JSON，后接指标类别与筛选条件
+
+	KeyPeriodScoreZSetPrefix    = "scgpteval:ranking:score:"    // zset; 存储每个用户在统计窗口内的刷题量，后接统计周期:窗口编号
+	KeyPeriodWeightedZSetPrefix = "scgpteval:ranking:weighted:" // zset; 存储每个用户在统计窗口内按信任分加权的刷题量，后接统计周期:窗口编号
+
+	KeyTokenFamilyHashPrefix = "scgpteval:token:family:" // hash; 存储refresh token family(即登录会话)的用户id、当前有效的jti及设备信息，后接family id
+	KeyJobLockStringPrefix   = "scgpteval:lock:job:"     // string; 后台定时任务的分布式锁，后接任务名
 )
+
+// campaignKeyPrefix 评测项目内的key前缀，后接评测项目id
+const campaignKeyPrefix = "scgpteval:campaign:"
+
+// campaignKey 将key限定到评测项目内，campaignID为0时即为全局key
+// 题库集合、金标准题集合、差值zset、刷题量zset(含各统计窗口)与用户租约按评测项目分别存储；
+// 题目作答数、目标作答数与题目租约以题目id区分，用户已刷题集合与用户信息为全局
+func campaignKey(key string, campaignID int64) string {
+	if campaignID == 0 {
+		return key
+	}
+	return campaignKeyPrefix + strconv.FormatInt(campaignID, 10) + ":" + strings.TrimPrefix(key, "scgpteval:")
+}
@@ -0,0 +1,22 @@
+package redis
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// GetModelLeaderboard 获取缓存的模型排行榜，未缓存时返回空串
+func GetModelLeaderboard() (data string, err error) {
+	data, err = rdb.Get(KeyModelLeaderboardString).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return data, err
+}
+
+// SetModelLeaderboard 缓存模型排行榜
+func SetModelLeaderboard(data string, expiration time.Duration) (err error) {
+	return rdb.Set(KeyModelLeaderboardString, data, expiration).Err()
+}
@@ -0,0 +1,174 @@
+package redis
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// 题目租约：出题时为用户预留题目，到期未提交的题目自动回到题池
+// 一道题同时有效的租约数与已收到的作答数之和不超过目标作答数
+// 目标作答数为0(不限)的题目不做名额限制，租约只记录用户已领取的题目，不具排他性
+
+var ErrorQuizSaturated = errors.New("题目已收到足够的作答")
+
+// leaseScript 依次尝试为用户预留候选题目，直到预留够need道
+// KEYS[1]:用户租约zset KEYS[2]:作答数zset KEYS[3]:目标作答数hash
+// ARGV[1]:用户id ARGV[2]:当前时间戳 ARGV[3]:到期时间戳 ARGV[4]:有效期(秒) ARGV[5]:默认目标作答数(0不限)
+// ARGV[6]:need ARGV[7]:题目租约key前缀 ARGV[8...]:候选题目id
+// 返回成功预留的题目id
+var leaseScript = redis.NewScript(`
+local need = tonumber(ARGV[6])
+local leased = {}
+for i = 8, #ARGV do
+	if #leased >= need then
+		break
+	end
+	local qid = ARGV[i]
+	local key = ARGV[7] .. qid
+	redis.call('ZREMRANGEBYSCORE', key, '-inf', ARGV[2])
+	local ok = true
+	local target = tonumber(redis.call('HGET', KEYS[3], qid) or ARGV[5])
+	if target > 0 and not redis.call('ZSCORE', key, ARGV[1]) then
+		local done = tonumber(redis.call('ZSCORE', KEYS[2], qid) or '0')
+		ok = done + redis.call('ZCARD', key) < target
+	end
+	if ok then
+		redis.call('ZADD', key, ARGV[3], ARGV[1])
+		redis.call('EXPIRE', key, ARGV[4])
+		redis.call('ZADD', KEYS[1], ARGV[3], qid)
+		leased[#leased + 1] = qid
+	end
+end
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+return leased
+`)
+
+// checkLeaseScript 提交前检查用户能否提交，不消费租约
+// 用户持有有效租约时允许提交；未持有(或租约已过期)时，只有题目仍有空余名额才允许提交，
+// 并为其预留一个短时的租约占住名额，并发提交的用户不会同时通过检查而超过目标作答数
+// KEYS[1]:题目租约zset KEYS[2]:作答数zset KEYS[3]:目标作答数hash
+// ARGV[1]:用户id ARGV[2]:题目id ARGV[3]:当前时间戳 ARGV[4]:默认目标作答数(0不限)
+// ARGV[5]:预留租约的到期时间戳 ARGV[6]:预留租约的有效期(秒)
+// 返回 1:持有租约或题目不限作答数 2:已预留名额 0:题目已饱和
+var checkLeaseScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[3])
+if redis.call('ZSCORE', KEYS[1], ARGV[1]) then
+	return 1
+end
+local target = tonumber(redis.call('HGET', KEYS[3], ARGV[2]) or ARGV[4])
+if target <= 0 then
+	return 1
+end
+local done = tonumber(redis.call('ZSCORE', KEYS[2], ARGV[2]) or '0')
+if done + redis.call('ZCARD', KEYS[1]) >= target then
+	return 0
+end
+redis.call('ZADD', KEYS[1], ARGV[5], ARGV[1])
+if redis.call('TTL', KEYS[1]) < tonumber(ARGV[6]) then
+	redis.call('EXPIRE', KEYS[1], ARGV[6])
+end
+return 2
+`)
+
+// submitReserveTTL 未持有租约的用户提交时预留名额的有效期，足够完成一次写入；
+// 提交进程异常退出未能释放时，名额在到期后自动回收
+const submitReserveTTL = time.Minute
+
+// GetUserLeases 获取用户在评测项目中仍然有效的租约(即已领取但尚未提交的题目)，至多num个
+func GetUserLeases(campaignID int64, userID string, num int64) (quizIds []string, err error) {
+	key := campaignKey(KeyUserLeaseZSetPrefix, campaignID) + userID
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	pipeline := rdb.TxPipeline()
+	pipeline.ZRemRangeByScore(key, "-inf", now)
+	leases := pipeline.ZRangeByScore(key, redis.ZRangeBy{Min: "(" + now, Max: "+inf", Count: num})
+	if _, err = pipeline.Exec(); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	return leases.Val(), nil
+}
+
+// LeaseQuizzes 从评测项目的候选题目中为用户预留至多need道，defaultTarget为未单独设置目标作答数的题目的目标作答数(0不限)
+func LeaseQuizzes(campaignID int64, userID string, candidates []string, need int, ttl time.Duration, defaultTarget int) (quizIds []string, err error) {
+	if need <= 0 || len(candidates) == 0 {
+		return nil, nil
+	}
+	now := time.Now()
+	args := make([]interface{}, 0, 7+len(candidates))
+	args = append(args, userID, now.Unix(), now.Add(ttl).Unix(), int64(ttl/time.Second),
+		defaultTarget, need, KeyQuizLeaseZSetPrefix)
+	for _, id := range candidates {
+		args = append(args, id)
+	}
+	keys := []string{campaignKey(KeyUserLeaseZSetPrefix, campaignID) + userID, KeyQuizJudgementZSet, KeyQuizTargetHash}
+	res, err := leaseScript.Run(rdb, keys, args...).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	return scriptStrings(res), nil
+}
+
+// scriptStrings 将lua脚本返回的数组转换为字符串切片
+func scriptStrings(res interface{}) []string {
+	items, _ := res.([]interface{})
+	strs := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			strs = append(strs, s)
+		}
+	}
+	return strs
+}
+
+// CheckLease 提交评测项目中的题目前检查用户的租约，题目已饱和时返回ErrorQuizSaturated
+// 检查不消费租约，作答写入成功后再调用ReleaseLease，以免重复提交或写入失败时占用的名额丢失；
+// reserved为true时为未持有租约的用户新预留了名额，写入失败时也需调用ReleaseLease归还
+func CheckLease(userID, quizID string, defaultTarget int) (reserved bool, err error) {
+	keys := []string{KeyQuizLeaseZSetPrefix + quizID, KeyQuizJudgementZSet, KeyQuizTargetHash}
+	now := time.Now()
+	res, err := checkLeaseScript.Run(rdb, keys, userID, quizID, now.Unix(), defaultTarget,
+		now.Add(submitReserveTTL).Unix(), int64(submitReserveTTL/time.Second)).Int64()
+	if err != nil {
+		return false, err
+	}
+	if res == 0 {
+		return false, ErrorQuizSaturated
+	}
+	return res == 2, nil
+}
+
+// ReleaseLease 作答已计入题目作答数后释放用户在评测项目中对该题的租约
+func ReleaseLease(campaignID int64, userID, quizID string) (err error) {
+	pipeline := rdb.TxPipeline()
+	pipeline.ZRem(KeyQuizLeaseZSetPrefix+quizID, userID)
+	pipeline.ZRem(campaignKey(KeyUserLeaseZSetPrefix, campaignID)+userID, quizID)
+	_, err = pipeline.Exec()
+	return err
+}
+
+// ResetQuizJudgements 以给定的作答数重建作答数zset
+func ResetQuizJudgements(counts map[int64]int64) (err error) {
+	tmpKey := KeyQuizJudgementZSet + ":rebuilding"
+	pipeline := rdb.TxPipeline()
+	pipeline.Del(tmpKey)
+	members := make([]redis.Z, 0, 1000)
+	for id, count := range counts {
+		members = append(members, redis.Z{Score: float64(count), Member: id})
+		if len(members) == cap(members) {
+			pipeline.ZAdd(tmpKey, members...)
+			members = make([]redis.Z, 0, 1000)
+		}
+	}
+	if len(members) > 0 {
+		pipeline.ZAdd(tmpKey, members...)
+	}
+	if len(counts) > 0 {
+		pipeline.Rename(tmpKey, KeyQuizJudgementZSet)
+	} else {
+		pipeline.Del(KeyQuizJudgementZSet)
+	}
+	_, err = pipeline.Exec()
+	return err
+}
@@ -0,0 +1,17 @@
+package redis
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// JobLocker 基于SET NX的后台定时任务分布式锁，实现jobs.Locker
+type JobLocker struct{}
+
+// TryLock 尝试取得任务锁，锁在ttl后自动释放
+func (JobLocker) TryLock(name string, ttl time.Duration) (ok bool, err error) {
+	host, _ := os.Hostname()
+	owner := host + ":" + strconv.Itoa(os.Getpid())
+	return rdb.SetNX(KeyJobLockStringPrefix+name, owner, ttl).Result()
+}
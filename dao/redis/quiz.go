@@ -2,15 +2,17 @@ package redis
 
 import (
 	"errors"
-	"fmt"
 	"github.com/go-redis/redis"
+	"scgptEval/models"
 	"strconv"
 	"time"
 )
 
-// GetUserAmount 获取指定id用户的刷题数量
-func GetUserAmount(userID string) (int64, error) {
-	score, err := rdb.ZScore(KeyUserScoreZSet, userID).Result()
+var ErrorQuizBaseNotExist = errors.New("题库集合不存在")
+
+// GetUserAmount 获取指定id用户在评测项目中的刷题数量，campaignID为0时为全局题库
+func GetUserAmount(campaignID int64, userID string) (int64, error) {
+	score, err := rdb.ZScore(campaignKey(KeyUserScoreZSet, campaignID), userID).Result()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			// member不存在说明用户未刷过题
@@ -22,55 +24,330 @@ func GetUserAmount(userID string) (int64, error) {
 	return int64(score), nil
 }
 
-// GetRanking 获取刷题量排行榜：前size个，降序
-func GetRanking(size int64) (rank []map[string]string, err error) {
-	users, err := rdb.ZRevRangeWithScores(KeyUserScoreZSet, 0, size-1).Result()
-	if err != nil {
-		return nil, err
+// submitScript 提交题目后更新用户刷题量(及按信任分加权的刷题量)、用户刷过的题、用户最后刷题时间与题目作答数
+// 题目达到目标作答数后移出题库集合，否则更新其距目标作答数的差值；同时累加各统计窗口的刷题量并设置其过期时间
+// 金标准题只记录用户刷过的题与最后刷题时间，不计入刷题量、排行榜与题目作答数
+// KEYS[1]:用户刷题量zset KEYS[2]:用户刷过的题set KEYS[3]:用户信息hash KEYS[4]:作答数zset
+// KEYS[5]:目标作答数hash KEYS[6]:题库集合 KEYS[7]:差值zset KEYS[8]:信任分zset KEYS[9]:加权刷题量zset
+// KEYS[10...]:依次为每个统计窗口的刷题量zset与加权刷题量zset
+// ARGV[1]:用户id ARGV[2]:题目id ARGV[3]:当前时间戳 ARGV[4]:默认目标作答数 ARGV[5]:是否为金标准题(1/0)
+// ARGV[6...]:每个统计窗口的过期时间戳
+// 返回题目当前的作答数，金标准题返回0
+var submitScript = redis.NewScript(`
+redis.call('SADD', KEYS[2], ARGV[2])
+redis.call('HSET', KEYS[3], 'final_qa_time', ARGV[3])
+if ARGV[5] == '1' then
+	return 0
+end
+local weight = tonumber(redis.call('ZSCORE', KEYS[8], ARGV[1]) or '1')
+redis.call('ZINCRBY', KEYS[1], 1, ARGV[1])
+redis.call('ZINCRBY', KEYS[9], weight, ARGV[1])
+for i = 6, #ARGV do
+	local score, weighted = KEYS[2 * i - 2], KEYS[2 * i - 1]
+	redis.call('ZINCRBY', score, 1, ARGV[1])
+	redis.call('ZINCRBY', weighted, weight, ARGV[1])
+	redis.call('EXPIREAT', score, ARGV[i])
+	redis.call('EXPIREAT', weighted, ARGV[i])
+end
+local done = tonumber(redis.call('ZINCRBY', KEYS[4], 1, ARGV[2]))
+local target = tonumber(redis.call('HGET', KEYS[5], ARGV[2]) or ARGV[4])
+if target > 0 and done >= target then
+	redis.call('SREM', KEYS[6], ARGV[2])
+	redis.call('ZREM', KEYS[7], ARGV[2])
+elseif redis.call('SISMEMBER', KEYS[6], ARGV[2]) == 1 then
+	redis.call('ZADD', KEYS[7], target > 0 and target - done or -done, ARGV[2])
+end
+return done
+`)
+
+// SubmitQuiz 提交评测项目中的题目，返回该题目前已收到的作答数
+// windows为提交时所在的各统计窗口，窗口的刷题量在窗口结束retention后过期；gold为true时不计入刷题量与作答数
+func SubmitQuiz(campaignID int64, userID, quizID string, gold bool, defaultTarget int,
+	windows []*models.PeriodWindow, retention time.Duration) (judgements int64, err error) {
+	keys := []string{
+		campaignKey(KeyUserScoreZSet, campaignID), KeyUserQuizSetPrefix + userID, KeyUserInfoHashPrefix + userID,
+		KeyQuizJudgementZSet, KeyQuizTargetHash, campaignKey(KeyQuizBaseSet, campaignID),
+		campaignKey(KeyQuizDeficitZSet, campaignID), KeyUserTrustZSet, campaignKey(KeyUserWeightedZSet, campaignID),
 	}
+	goldFlag := 0
+	if gold {
+		goldFlag = 1
+	}
+	args := []interface{}{userID, quizID, time.Now().Unix(), defaultTarget, goldFlag}
+	for _, w := range windows {
+		keys = append(keys, periodKey(KeyPeriodScoreZSetPrefix, campaignID, w), periodKey(KeyPeriodWeightedZSetPrefix, campaignID, w))
+		args = append(args, w.EndAt.Add(retention).Unix())
+	}
+	return submitScript.Run(rdb, keys, args...).Int64()
+}
 
-	rank = make([]map[string]string, 0, len(users))
-	pipeline := rdb.Pipeline()
-	for _, user := range users {
-		pipeline.HGetAll(KeyUserInfoHashPrefix + fmt.Sprint(user.Member))
+// SampleUntriedQuiz 随机获取用户在评测项目中未刷过的至多num个题目id，并返回未刷过的题目总数
+func SampleUntriedQuiz(campaignID int64, userID string, num int64) (quizIds []string, untried int64, err error) {
+	// 执行SDIFFSTORE进行差集运算得到用户未刷过的题目id集合，再用SRANDMEMBER随机抽取
+	tmpKey := campaignKey(KeyUserUntriedTmpPrefix, campaignID) + userID
+	pipeline := rdb.TxPipeline()
+	exists := pipeline.Exists(campaignKey(KeyQuizBaseSyncedString, campaignID))
+	diff := pipeline.SDiffStore(tmpKey, campaignKey(KeyQuizBaseSet, campaignID), KeyUserQuizSetPrefix+userID)
+	members := pipeline.SRandMemberN(tmpKey, num)
+	pipeline.Del(tmpKey)
+	if _, err = pipeline.Exec(); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, 0, err
 	}
-	cmds, err := pipeline.Exec()
-	if err != nil {
+	if exists.Val() == 0 {
+		return nil, 0, ErrorQuizBaseNotExist
+	}
+	return members.Val(), diff.Val(), nil
+}
+
+// ScanUntriedQuiz 分批遍历用户在评测项目中未刷过的题目id，每批至多约count个，返回未刷过的题目总数
+func ScanUntriedQuiz(campaignID int64, userID string, count int64, fn func(quizIds []string) error) (untried int64, err error) {
+	// 与SampleUntriedQuiz的临时key区分，避免同一用户并发出题时互相删除
+	tmpKey := campaignKey(KeyUserUntriedTmpPrefix, campaignID) + userID + ":scan"
+	pipeline := rdb.TxPipeline()
+	exists := pipeline.Exists(campaignKey(KeyQuizBaseSyncedString, campaignID))
+	diff := pipeline.SDiffStore(tmpKey, campaignKey(KeyQuizBaseSet, campaignID), KeyUserQuizSetPrefix+userID)
+	pipeline.Expire(tmpKey, time.Minute)
+	if _, err = pipeline.Exec(); err != nil && !errors.Is(err, redis.Nil) {
+		return 0, err
+	}
+	defer rdb.Del(tmpKey)
+	if exists.Val() == 0 {
+		return 0, ErrorQuizBaseNotExist
+	}
+	var cursor uint64
+	for {
+		var ids []string
+		if ids, cursor, err = rdb.SScan(tmpKey, cursor, "", count).Result(); err != nil {
+			return 0, err
+		}
+		if len(ids) > 0 {
+			if err = fn(ids); err != nil {
+				return 0, err
+			}
+		}
+		if cursor == 0 {
+			return diff.Val(), nil
+		}
+	}
+}
+
+// neediestPageSize 与 neediestMaxPages 按差值遍历题库时每页的题目数与最多遍历的页数，
+// 用户刷过差值最大的大部分题目时限制单次脚本的执行时间，找不够的题目由调用方补足
+const (
+	neediestPageSize = 200
+	neediestMaxPages = 25
+)
+
+// neediestScript 按距目标作答数的差值从大到小遍历题库，返回用户未刷过的至多num个题目id，至多遍历maxPages页
+// KEYS[1]:差值zset KEYS[2]:用户刷过的题set ARGV[1]:num ARGV[2]:每页题目数 ARGV[3]:最多遍历的页数
+var neediestScript = redis.NewScript(`
+local num = tonumber(ARGV[1])
+local size = tonumber(ARGV[2])
+local maxPages = tonumber(ARGV[3])
+local res = {}
+local start = 0
+for page = 1, maxPages do
+	if #res >= num then
+		break
+	end
+	local ids = redis.call('ZREVRANGE', KEYS[1], start, start + size - 1)
+	if #ids == 0 then
+		break
+	end
+	for _, id in ipairs(ids) do
+		if redis.call('SISMEMBER', KEYS[2], id) == 0 then
+			res[#res + 1] = id
+			if #res >= num then
+				break
+			end
+		end
+	end
+	start = start + size
+end
+return res
+`)
+
+// GetNeediestUntriedQuiz 获取用户在评测项目中未刷过的题目中距目标作答数最远的至多num个题目id
+// 只在差值最大的若干题目中查找，用户刷过其中的大部分题目时返回的题目可能少于num
+func GetNeediestUntriedQuiz(campaignID int64, userID string, num int64) (quizIds []string, err error) {
+	if num <= 0 {
+		return nil, nil
+	}
+	keys := []string{campaignKey(KeyQuizDeficitZSet, campaignID), KeyUserQuizSetPrefix + userID}
+	res, err := neediestScript.Run(rdb, keys, num, neediestPageSize, neediestMaxPages).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
 		return nil, err
 	}
-	for i, cmd := range cmds {
-		r := cmd.(*redis.StringStringMapCmd).Val()
-		r["id"] = users[i].Member.(string)
-		r["score"] = strconv.FormatFloat(users[i].Score, 'f', 0, 64)
-		// 时间戳 --> 正常时间
+	return scriptStrings(res), nil
+}
+
+// UserQuizSetExists 判断用户已刷题集合是否存在
+func UserQuizSetExists(userID string) (bool, error) {
+	n, err := rdb.Exists(KeyUserQuizSetPrefix + userID).Result()
+	return n > 0, err
+}
+
+// userQuizSetSentinel 用户已刷题集合中的占位成员，不对应任何题目，
+// 使没有做题记录的用户的集合也存在，避免每次出题都从mysql恢复
+const userQuizSetSentinel = 0
 
-		rank = append(rank, r)
+// InitUserQuizSet 用做题记录初始化用户已刷题集合，集合中始终带有占位成员
+func InitUserQuizSet(userID string, quizIds ...int64) (err error) {
+	members := make([]interface{}, 0, len(quizIds)+1)
+	members = append(members, userQuizSetSentinel)
+	for _, id := range quizIds {
+		members = append(members, id)
 	}
-	return rank, nil
+	return rdb.SAdd(KeyUserQuizSetPrefix+userID, members...).Err()
 }
 
-// SubmitQuiz 提交题目
-func SubmitQuiz(userID, quizID string) (err error) {
-	// 更新存储用户的刷题总量zset、用户刷过的题set、用户的刷题信息hash（更新最后刷题时间戳）
-	now := time.Now().Unix()
-	// redis事务操作
-	pipeline := rdb.TxPipeline()
-	pipeline.ZIncrBy(KeyUserScoreZSet, 1, userID) // 用户刷题量增1
-	pipeline.SAdd(KeyUserQuizSetPrefix+userID, quizID)
-	pipeline.HSet(KeyUserInfoHashPrefix+userID, "final_qa_time", now)
+// quizDeficit 题目距目标作答数的差值，不限作答数时为作答数的相反数，差值越大越优先出题
+func quizDeficit(target int, judgements int64) float64 {
+	if target > 0 {
+		return float64(int64(target) - judgements)
+	}
+	return float64(-judgements)
+}
 
-	if _, err = pipeline.Exec(); err != nil {
-		return err
+// AddQuizBase 将新题目加入所属评测项目的题库集合，并记录其目标作答数
+func AddQuizBase(defaultTarget int, quizzes ...*models.Quiz) (err error) {
+	if len(quizzes) == 0 {
+		return nil
+	}
+	targets := make(map[string]interface{})
+	pipeline := rdb.TxPipeline()
+	for _, quiz := range quizzes {
+		pipeline.SAdd(campaignKey(KeyQuizBaseSet, quiz.CampaignID), quiz.ID)
+		pipeline.ZAdd(campaignKey(KeyQuizDeficitZSet, quiz.CampaignID),
+			redis.Z{Score: quizDeficit(quiz.Target(defaultTarget), 0), Member: quiz.ID})
+		if quiz.TargetJudgements > 0 {
+			targets[strconv.FormatInt(quiz.ID, 10)] = quiz.TargetJudgements
+		}
 	}
-	return nil
+	if len(targets) > 0 {
+		pipeline.HMSet(KeyQuizTargetHash, targets)
+	}
+	_, err = pipeline.Exec()
+	return err
 }
 
-// GetUntriedQuiz 获取用户未刷过的题目ids
-func GetUntriedQuiz(userID string) (quizIds []string, err error) {
-	// 执行SDIFF进行差集运算，获得用户为刷过的题目id集合
-	res, err := rdb.SDiff(KeyQuizBaseSet, KeyUserQuizSetPrefix+userID).Result()
-	if err != nil {
-		return nil, err
+// RemoveQuizBase 将题目移出评测项目的题库集合与金标准题集合
+func RemoveQuizBase(campaignID, quizID int64) (err error) {
+	pipeline := rdb.TxPipeline()
+	pipeline.SRem(campaignKey(KeyQuizBaseSet, campaignID), quizID)
+	pipeline.SRem(campaignKey(KeyQuizGoldSet, campaignID), quizID)
+	pipeline.ZRem(campaignKey(KeyQuizDeficitZSet, campaignID), quizID)
+	_, err = pipeline.Exec()
+	return err
+}
+
+// retargetScript 修改题目的目标作答数，并据此将题目加入或移出题库集合
+// KEYS[1]:目标作答数hash KEYS[2]:作答数zset KEYS[3]:题库集合 KEYS[4]:差值zset
+// ARGV[1]:题目id ARGV[2]:单独设置的目标作答数(0表示使用默认值) ARGV[3]:默认目标作答数
+var retargetScript = redis.NewScript(`
+local target = tonumber(ARGV[2])
+if target > 0 then
+	redis.call('HSET', KEYS[1], ARGV[1], target)
+else
+	redis.call('HDEL', KEYS[1], ARGV[1])
+	target = tonumber(ARGV[3])
+end
+local done = tonumber(redis.call('ZSCORE', KEYS[2], ARGV[1]) or '0')
+if target > 0 and done >= target then
+	redis.call('SREM', KEYS[3], ARGV[1])
+	redis.call('ZREM', KEYS[4], ARGV[1])
+else
+	redis.call('SADD', KEYS[3], ARGV[1])
+	redis.call('ZADD', KEYS[4], target > 0 and target - done or -done, ARGV[1])
+end
+return done
+`)
+
+// RetargetQuiz 修改评测项目中未下线题目的目标作答数
+func RetargetQuiz(campaignID, quizID int64, targetJudgements, defaultTarget int) (err error) {
+	keys := []string{KeyQuizTargetHash, KeyQuizJudgementZSet,
+		campaignKey(KeyQuizBaseSet, campaignID), campaignKey(KeyQuizDeficitZSet, campaignID)}
+	return retargetScript.Run(rdb, keys, quizID, targetJudgements, defaultTarget).Err()
+}
+
+// MarkQuizBaseSynced 标记评测项目的题库集合为已重建，用于题库为空的新评测项目
+func MarkQuizBaseSynced(campaignID int64) (err error) {
+	return rdb.Set(campaignKey(KeyQuizBaseSyncedString, campaignID), time.Now().Unix(), 0).Err()
+}
+
+// ResetQuizBase 重建各评测项目的题库集合、差值zset与目标作答数hash：先写入临时key再RENAME，重建过程中不影响出题
+// quizTargets为全部未下线题目单独设置的目标作答数(0表示使用默认值)，quizCampaigns为题目所属的评测项目，
+// campaignIDs为全部评测项目(没有题目的评测项目同样需要标记为已重建)，judgements为各题已收到的作答数
+// 已达到目标作答数的题目不加入题库集合
+func ResetQuizBase(quizTargets map[int64]int, quizCampaigns map[int64]int64, campaignIDs []int64,
+	judgements map[int64]int64, defaultTarget int) (err error) {
+	deficits := make(map[int64]map[int64]float64, len(campaignIDs)+1) // 评测项目id -> 题目id -> 差值
+	deficits[0] = make(map[int64]float64)
+	for _, id := range campaignIDs {
+		deficits[id] = make(map[int64]float64)
+	}
+	targets := make(map[int64]int)
+	for id, target := range quizTargets {
+		if target > 0 {
+			targets[id] = target
+		} else {
+			target = defaultTarget
+		}
+		if target > 0 && judgements[id] >= int64(target) {
+			continue
+		}
+		campaignID := quizCampaigns[id]
+		if deficits[campaignID] == nil {
+			deficits[campaignID] = make(map[int64]float64)
+		}
+		deficits[campaignID][id] = quizDeficit(target, judgements[id])
+	}
+
+	const batch = 1000
+	tmpTarget := KeyQuizTargetHash + ":rebuilding"
+	pipeline := rdb.TxPipeline()
+	for campaignID, campaignDeficits := range deficits {
+		baseKey := campaignKey(KeyQuizBaseSet, campaignID)
+		deficitKey := campaignKey(KeyQuizDeficitZSet, campaignID)
+		tmpBase := baseKey + ":rebuilding"
+		tmpDeficit := deficitKey + ":rebuilding"
+		pipeline.Del(tmpBase, tmpDeficit)
+		members := make([]interface{}, 0, batch)
+		scores := make([]redis.Z, 0, batch)
+		for id, deficit := range campaignDeficits {
+			members = append(members, id)
+			scores = append(scores, redis.Z{Score: deficit, Member: id})
+			if len(members) == batch {
+				pipeline.SAdd(tmpBase, members...)
+				pipeline.ZAdd(tmpDeficit, scores...)
+				members = make([]interface{}, 0, batch)
+				scores = make([]redis.Z, 0, batch)
+			}
+		}
+		if len(members) > 0 {
+			pipeline.SAdd(tmpBase, members...)
+			pipeline.ZAdd(tmpDeficit, scores...)
+		}
+		// 题库为空时不存在临时key，RENAME会报错，直接删除
+		if len(campaignDeficits) > 0 {
+			pipeline.Rename(tmpBase, baseKey)
+			pipeline.Rename(tmpDeficit, deficitKey)
+		} else {
+			pipeline.Del(baseKey, deficitKey)
+		}
+		pipeline.Set(campaignKey(KeyQuizBaseSyncedString, campaignID), time.Now().Unix(), 0)
+	}
+	pipeline.Del(tmpTarget)
+	fields := make(map[string]interface{}, len(targets))
+	for id, target := range targets {
+		fields[strconv.FormatInt(id, 10)] = target
+	}
+	if len(fields) > 0 {
+		pipeline.HMSet(tmpTarget, fields)
+		pipeline.Rename(tmpTarget, KeyQuizTargetHash)
+	} else {
+		pipeline.Del(KeyQuizTargetHash)
 	}
-	return res, nil
+	_, err = pipeline.Exec()
+	return err
 }
@@ -0,0 +1,150 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"scgptEval/models"
+	"strconv"
+
+	"github.com/go-redis/redis"
+)
+
+// periodKey 评测项目中某个统计窗口的刷题量zset
+func periodKey(prefix string, campaignID int64, w *models.PeriodWindow) string {
+	return campaignKey(prefix+w.Period+":"+w.Key, campaignID)
+}
+
+// rankingKeys 评测项目的刷题量zset与加权刷题量zset，w为nil时为累计刷题量
+func rankingKeys(campaignID int64, w *models.PeriodWindow) (scoreKey, weightedKey string) {
+	if w == nil {
+		return campaignKey(KeyUserScoreZSet, campaignID), campaignKey(KeyUserWeightedZSet, campaignID)
+	}
+	return periodKey(KeyPeriodScoreZSetPrefix, campaignID, w), periodKey(KeyPeriodWeightedZSetPrefix, campaignID, w)
+}
+
+// rankEntry 排行榜中的一名用户
+type rankEntry struct {
+	member   string
+	score    float64 // 刷题量
+	weighted float64 // 按信任分加权的刷题量
+	info     map[string]string
+}
+
+// loadRanking 按刷题量(byTrust为true时按加权刷题量)降序获取第offset名之后的至多limit名用户及其信息，并返回上榜用户总数
+func loadRanking(scoreKey, weightedKey string, offset, limit int64, byTrust bool) (entries []*rankEntry, total int64, err error) {
+	key, other := scoreKey, weightedKey
+	if byTrust {
+		key, other = weightedKey, scoreKey
+	}
+	tx := rdb.TxPipeline()
+	rangeCmd := tx.ZRevRangeWithScores(key, offset, offset+limit-1)
+	cardCmd := tx.ZCard(key)
+	if _, err = tx.Exec(); err != nil {
+		return nil, 0, err
+	}
+	users, total := rangeCmd.Val(), cardCmd.Val()
+
+	pipeline := rdb.Pipeline()
+	infos := make([]*redis.StringStringMapCmd, 0, len(users))
+	others := make([]*redis.FloatCmd, 0, len(users))
+	for _, user := range users {
+		member := fmt.Sprint(user.Member)
+		infos = append(infos, pipeline.HGetAll(KeyUserInfoHashPrefix+member))
+		others = append(others, pipeline.ZScore(other, member))
+	}
+	if len(users) > 0 {
+		if _, err = pipeline.Exec(); err != nil && !errors.Is(err, redis.Nil) {
+			return nil, 0, err
+		}
+	}
+	entries = make([]*rankEntry, 0, len(users))
+	for i, user := range users {
+		e := &rankEntry{member: fmt.Sprint(user.Member), info: infos[i].Val()}
+		if byTrust {
+			e.score, e.weighted = others[i].Val(), user.Score
+		} else {
+			e.score, e.weighted = user.Score, others[i].Val()
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, nil
+}
+
+// formatRanking 排行榜的响应数据，rank为从1开始的名次，byTrust为true时在weighted_score中返回加权刷题量
+func formatRanking(entries []*rankEntry, offset int64, byTrust bool) (rank []map[string]string) {
+	rank = make([]map[string]string, 0, len(entries))
+	for i, e := range entries {
+		r := e.info
+		r["id"] = e.member
+		r["rank"] = strconv.FormatInt(offset+int64(i)+1, 10)
+		r["score"] = strconv.FormatFloat(e.score, 'f', 0, 64)
+		if byTrust {
+			r["weighted_score"] = strconv.FormatFloat(e.weighted, 'f', 2, 64)
+		}
+		rank = append(rank, r)
+	}
+	return rank
+}
+
+// GetRanking 获取评测项目的刷题量排行榜中第offset名之后的至多limit名，降序，并返回上榜用户总数
+// campaignID为0时为全局题库；w为nil时为累计刷题量，否则为统计窗口内的刷题量
+// byTrust为true时按信任分加权的刷题量排名，并在weighted_score中返回加权刷题量
+func GetRanking(campaignID int64, w *models.PeriodWindow, offset, limit int64, byTrust bool) (rank []map[string]string, total int64, err error) {
+	scoreKey, weightedKey := rankingKeys(campaignID, w)
+	entries, total, err := loadRanking(scoreKey, weightedKey, offset, limit, byTrust)
+	if err != nil {
+		return nil, 0, err
+	}
+	return formatRanking(entries, offset, byTrust), total, nil
+}
+
+// GetUserRank 获取用户在评测项目的刷题量排行榜中从1开始的名次，未上榜时返回0
+func GetUserRank(campaignID int64, w *models.PeriodWindow, userID string, byTrust bool) (rank int64, err error) {
+	key, weightedKey := rankingKeys(campaignID, w)
+	if byTrust {
+		key = weightedKey
+	}
+	rank, err = rdb.ZRevRank(key, userID).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return rank + 1, nil
+}
+
+// PeriodRankingExists 统计窗口的排行榜是否仍在redis中(窗口内无人刷题或已过期时不存在)
+func PeriodRankingExists(campaignID int64, w *models.PeriodWindow) (bool, error) {
+	n, err := rdb.Exists(periodKey(KeyPeriodScoreZSetPrefix, campaignID, w)).Result()
+	return n > 0, err
+}
+
+// GetPeriodSnapshot 获取统计窗口排行榜中第offset名之后的至多limit名的存档数据，并返回排行榜的总人数
+func GetPeriodSnapshot(campaignID int64, w *models.PeriodWindow, offset, limit int64, byTrust bool) (list []*models.RankingSnapshot, total int64, err error) {
+	scoreKey, weightedKey := rankingKeys(campaignID, w)
+	entries, total, err := loadRanking(scoreKey, weightedKey, offset, limit, byTrust)
+	if err != nil {
+		return nil, 0, err
+	}
+	list = make([]*models.RankingSnapshot, 0, len(entries))
+	for i, e := range entries {
+		userID, err := strconv.ParseInt(e.member, 10, 64)
+		if err != nil {
+			return nil, 0, err
+		}
+		list = append(list, &models.RankingSnapshot{
+			Period:        w.Period,
+			PeriodKey:     w.Key,
+			CampaignID:    campaignID,
+			UserID:        userID,
+			Rank:          int(offset) + i + 1,
+			Username:      e.info["username"],
+			Score:         int64(e.score),
+			WeightedScore: e.weighted,
+			StartAt:       w.StartAt,
+			EndAt:         w.EndAt,
+		})
+	}
+	return list, total, nil
+}
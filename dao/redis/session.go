@@ -0,0 +1,158 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"scgptEval/models"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+var ErrorSessionNotExist = errors.New("会话不存在")
+
+// createSessionScript 写入新会话并从用户会话zset中淘汰超出上限的旧会话，在脚本中执行以免并发登录时会话数超过上限
+// 被淘汰会话的hash不在KEYS中，由调用方删除，以免脚本访问未声明的key(集群下可能不在同一节点)
+// KEYS[1]:会话hash KEYS[2]:用户会话zset
+// ARGV[1]:会话id ARGV[2]:登录时间戳 ARGV[3]:有效期(秒) ARGV[4]:会话数上限(0不限)
+// ARGV[5...]:会话hash的字段与值
+// 返回被淘汰的会话id
+var createSessionScript = redis.NewScript(`
+redis.call('HMSET', KEYS[1], unpack(ARGV, 5))
+redis.call('EXPIRE', KEYS[1], ARGV[3])
+redis.call('ZADD', KEYS[2], ARGV[2], ARGV[1])
+redis.call('EXPIRE', KEYS[2], ARGV[3])
+local max = tonumber(ARGV[4])
+local evicted = {}
+if max <= 0 then
+	return evicted
+end
+-- 新会话一定保留，其余会话按登录时间从新到旧保留max-1个
+local kept = 1
+for _, sid in ipairs(redis.call('ZREVRANGE', KEYS[2], 0, -1)) do
+	if sid ~= ARGV[1] then
+		if kept < max then
+			kept = kept + 1
+		else
+			redis.call('ZREM', KEYS[2], sid)
+			evicted[#evicted + 1] = sid
+		end
+	end
+end
+return evicted
+`)
+
+// CreateSession 登录时新建会话(refresh token family)
+// maxSessions > 0 时只保留该用户最近登录的maxSessions个会话，其余会话被吊销
+func CreateSession(s *models.Session, jti string, expiration time.Duration, maxSessions int) (err error) {
+	uid := fmt.Sprint(s.UserID)
+	keys := []string{KeyTokenFamilyHashPrefix + s.SessionID, KeyUserSessionZSetPrefix + uid}
+	args := []interface{}{s.SessionID, s.CreatedAt, int64(expiration / time.Second), maxSessions,
+		"user_id", s.UserID,
+		"jti", jti,
+		"ip", s.IP,
+		"user_agent", s.UserAgent,
+		"created_at", s.CreatedAt,
+	}
+	res, err := createSessionScript.Run(rdb, keys, args...).Result()
+	if err != nil {
+		return err
+	}
+	evicted := scriptStrings(res)
+	if len(evicted) == 0 {
+		return nil
+	}
+	pipeline := rdb.Pipeline()
+	for _, sid := range evicted {
+		pipeline.Del(KeyTokenFamilyHashPrefix + sid)
+	}
+	_, err = pipeline.Exec()
+	return err
+}
+
+// CheckSession 检查会话是否仍然有效且属于该用户
+func CheckSession(userID int64, sessionID string) (err error) {
+	uid, err := rdb.HGet(KeyTokenFamilyHashPrefix+sessionID, "user_id").Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return ErrorTokenRevoked
+		}
+		return err
+	}
+	if uid != fmt.Sprint(userID) {
+		return ErrorTokenRevoked
+	}
+	return nil
+}
+
+// GetUserSessions 获取用户当前有效的会话，顺带清理已过期或被吊销的会话id
+func GetUserSessions(userID int64) (sessions []*models.Session, err error) {
+	sessionKey := KeyUserSessionZSetPrefix + fmt.Sprint(userID)
+	ids, err := rdb.ZRevRange(sessionKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := rdb.Pipeline()
+	for _, sid := range ids {
+		pipeline.HGetAll(KeyTokenFamilyHashPrefix + sid)
+	}
+	cmds, err := pipeline.Exec()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	sessions = make([]*models.Session, 0, len(ids))
+	var stale []interface{}
+	for i, cmd := range cmds {
+		info := cmd.(*redis.StringStringMapCmd).Val()
+		if len(info) == 0 || info["user_id"] != fmt.Sprint(userID) {
+			stale = append(stale, ids[i])
+			continue
+		}
+		createdAt, _ := strconv.ParseInt(info["created_at"], 10, 64)
+		sessions = append(sessions, &models.Session{
+			SessionID: ids[i],
+			UserID:    userID,
+			IP:        info["ip"],
+			UserAgent: info["user_agent"],
+			CreatedAt: createdAt,
+		})
+	}
+	if len(stale) > 0 {
+		rdb.ZRem(sessionKey, stale...)
+	}
+	return sessions, nil
+}
+
+// RevokeSession 吊销用户的指定会话
+func RevokeSession(userID int64, sessionID string) (err error) {
+	if err = CheckSession(userID, sessionID); err != nil {
+		if errors.Is(err, ErrorTokenRevoked) {
+			return ErrorSessionNotExist
+		}
+		return err
+	}
+	pipeline := rdb.TxPipeline()
+	pipeline.Del(KeyTokenFamilyHashPrefix + sessionID)
+	pipeline.ZRem(KeyUserSessionZSetPrefix+fmt.Sprint(userID), sessionID)
+	_, err = pipeline.Exec()
+	return err
+}
+
+// RevokeUserSessions 吊销用户的全部会话
+func RevokeUserSessions(userID int64) (err error) {
+	sessionKey := KeyUserSessionZSetPrefix + fmt.Sprint(userID)
+	ids, err := rdb.ZRange(sessionKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	pipeline := rdb.TxPipeline()
+	for _, sid := range ids {
+		pipeline.Del(KeyTokenFamilyHashPrefix + sid)
+	}
+	pipeline.Del(sessionKey)
+	_, err = pipeline.Exec()
+	return err
+}
@@ -0,0 +1,48 @@
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+var (
+	ErrorTokenRevoked = errors.New("token已失效")
+	ErrorTokenReused  = errors.New("refresh token被重复使用")
+)
+
+// rotateScript 原子地校验并轮换family中的jti，同时续期family及用户会话列表
+// 返回 1:轮换成功 0:family不存在或不属于该用户 -1:jti不匹配(旧token被重放)，此时整个family被吊销
+var rotateScript = redis.NewScript(`
+local uid = redis.call('HGET', KEYS[1], 'user_id')
+if not uid or uid ~= ARGV[1] then
+	return 0
+end
+if redis.call('HGET', KEYS[1], 'jti') ~= ARGV[2] then
+	redis.call('DEL', KEYS[1])
+	return -1
+end
+redis.call('HSET', KEYS[1], 'jti', ARGV[3])
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+redis.call('EXPIRE', KEYS[2], ARGV[4])
+return 1
+`)
+
+// RotateRefreshToken 将family当前有效的jti由oldJTI轮换为newJTI
+func RotateRefreshToken(familyID string, userID int64, oldJTI, newJTI string, expiration time.Duration) (err error) {
+	keys := []string{KeyTokenFamilyHashPrefix + familyID, KeyUserSessionZSetPrefix + fmt.Sprint(userID)}
+	res, err := rotateScript.Run(rdb, keys, userID, oldJTI, newJTI, int64(expiration/time.Second)).Int64()
+	if err != nil {
+		return err
+	}
+	switch res {
+	case 1:
+		return nil
+	case -1:
+		return ErrorTokenReused
+	default:
+		return ErrorTokenRevoked
+	}
+}
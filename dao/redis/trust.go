@@ -0,0 +1,80 @@
+package redis
+
+import (
+	"scgptEval/models"
+
+	"github.com/go-redis/redis"
+)
+
+// 加权刷题量 = 刷题量 × 用户当前的信任分(未计算过的用户按1计)
+// 提交时按当时的信任分累加，信任分重新计算后由ResetUserTrusts按新的信任分重建，两者在重建后一致
+
+// rebuildWeightedScript 按信任分重建一个加权刷题量zset，在脚本中执行以免重建期间的提交丢失
+// KEYS[1]:刷题量zset KEYS[2]:加权刷题量zset KEYS[3]:信任分zset
+// 加权刷题量zset的过期时间与刷题量zset一致；返回重建的用户数
+var rebuildWeightedScript = redis.NewScript(`
+local amounts = redis.call('ZRANGE', KEYS[1], 0, -1, 'WITHSCORES')
+redis.call('DEL', KEYS[2])
+local batch = {}
+for i = 1, #amounts, 2 do
+	local weight = tonumber(redis.call('ZSCORE', KEYS[3], amounts[i]) or '1')
+	batch[#batch + 1] = tonumber(amounts[i + 1]) * weight
+	batch[#batch + 1] = amounts[i]
+	if #batch >= 1000 then
+		redis.call('ZADD', KEYS[2], unpack(batch))
+		batch = {}
+	end
+end
+if #batch > 0 then
+	redis.call('ZADD', KEYS[2], unpack(batch))
+end
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[2], ttl)
+end
+return #amounts / 2
+`)
+
+// ResetUserTrusts 重建信任分zset，并按新的信任分重建全局及各评测项目的累计与各统计窗口的加权刷题量zset
+// windows为redis中仍保留的统计窗口
+func ResetUserTrusts(scores map[int64]float64, campaignIDs []int64, windows []*models.PeriodWindow) (err error) {
+	trusts := make([]redis.Z, 0, len(scores))
+	for userID, score := range scores {
+		trusts = append(trusts, redis.Z{Score: score, Member: userID})
+	}
+	const batch = 1000
+	tmpTrust := KeyUserTrustZSet + ":rebuilding"
+	pipeline := rdb.TxPipeline()
+	pipeline.Del(tmpTrust)
+	for i := 0; i < len(trusts); i += batch {
+		pipeline.ZAdd(tmpTrust, trusts[i:minInt(i+batch, len(trusts))]...)
+	}
+	// 集合为空时不存在临时key，RENAME会报错，直接删除
+	if len(trusts) > 0 {
+		pipeline.Rename(tmpTrust, KeyUserTrustZSet)
+	} else {
+		pipeline.Del(KeyUserTrustZSet)
+	}
+	if _, err = pipeline.Exec(); err != nil {
+		return err
+	}
+	for _, campaignID := range append([]int64{0}, campaignIDs...) {
+		keys := [][2]string{{campaignKey(KeyUserScoreZSet, campaignID), campaignKey(KeyUserWeightedZSet, campaignID)}}
+		for _, w := range windows {
+			keys = append(keys, [2]string{periodKey(KeyPeriodScoreZSetPrefix, campaignID, w), periodKey(KeyPeriodWeightedZSetPrefix, campaignID, w)})
+		}
+		for _, k := range keys {
+			if err = rebuildWeightedScript.Run(rdb, []string{k[0], k[1], KeyUserTrustZSet}).Err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
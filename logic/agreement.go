@@ -0,0 +1,189 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"scgptEval/config"
+	"scgptEval/dao/mysql"
+	"scgptEval/dao/redis"
+	"scgptEval/models"
+	"scgptEval/pkg/analytics"
+	"scgptEval/pkg/quizkind"
+	"sort"
+	"strconv"
+)
+
+// 标注一致性：由做题记录按题型计算Fleiss'/Cohen's kappa与Krippendorff's alpha，以及逐题的一致率
+// 不同题型的答案无法相互比较，指标总是按题型分别计算；自由文本题不参与计算
+
+var ErrorAgreementUnsupported = errors.New("该题型不支持计算一致性")
+
+// agreementLevels 各题型答案的测量尺度
+var agreementLevels = map[string]analytics.Level{
+	quizkind.CodeSingleChoice: analytics.Nominal,
+	quizkind.CodeMultiChoice:  analytics.Set,
+	quizkind.CodeRanking:      analytics.Ranking,
+	quizkind.CodeLikert:       analytics.Interval,
+	quizkind.CodePairwise:     analytics.Nominal,
+	quizkind.CodeComparison:   analytics.Nominal,
+}
+
+var levelNames = map[analytics.Level]string{
+	analytics.Nominal:  "nominal",
+	analytics.Interval: "interval",
+	analytics.Set:      "set",
+	analytics.Ranking:  "ranking",
+}
+
+// TypeAgreement 某一题型的一致性指标
+type TypeAgreement struct {
+	QuizType string `json:"quiz_type"`
+	Kind     string `json:"kind"`
+	Level    string `json:"level"` // 计算Krippendorff's alpha使用的测量尺度
+	*analytics.Agreement
+}
+
+// answerValues 将规范化后的答案转换为标注值：JSON数组的各元素、JSON字符串或比对键本身
+func answerValues(kind quizkind.Kind, answer string) []string {
+	key := quizkind.AnswerKey(kind, answer)
+	var items []string
+	if json.Unmarshal([]byte(key), &items) == nil {
+		return items
+	}
+	var str string
+	if json.Unmarshal([]byte(key), &str) == nil {
+		return []string{str}
+	}
+	return []string{key}
+}
+
+// loadLabels 读取符合条件的答案，按题型分组为标注
+func loadLabels(filter *models.RecordFilter) (labels map[string][]analytics.Label, err error) {
+	labels = make(map[string][]analytics.Label)
+	err = mysql.IterateAnswers(filter, func(quizID int64, quizType string, userID int64, answer string) error {
+		if _, ok := agreementLevels[quizType]; !ok {
+			return nil
+		}
+		kind, err := quizkind.Get(quizType)
+		if err != nil {
+			return nil
+		}
+		labels[quizType] = append(labels[quizType], analytics.Label{
+			Unit:   strconv.FormatInt(quizID, 10),
+			Coder:  userID,
+			Values: answerValues(kind, answer),
+		})
+		return nil
+	})
+	return labels, err
+}
+
+// agreementCacheKey 缓存key中的筛选条件
+func agreementCacheKey(category string, filter *models.RecordFilter) string {
+	var start, end int64
+	if !filter.Start.IsZero() {
+		start = filter.Start.Unix()
+	}
+	if !filter.End.IsZero() {
+		end = filter.End.Unix()
+	}
+	return fmt.Sprintf("%s:type=%s:quiz=%d:campaign=%d:start=%d:end=%d",
+		category, filter.QuizType, filter.QuizID, filter.CampaignID, start, end)
+}
+
+// cachedAgreement 读取缓存的指标，未缓存或refresh为true时调用compute计算并写入缓存
+func cachedAgreement(key string, refresh bool, v interface{}, compute func() (interface{}, error)) (err error) {
+	if !refresh {
+		data, err := redis.GetAgreement(key)
+		if err != nil {
+			zap.L().Error("redis.GetAgreement() failed", zap.String("key", key), zap.Error(err))
+		} else if data != "" && json.Unmarshal([]byte(data), v) == nil {
+			return nil
+		}
+	}
+	res, err := compute()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	cacheTTL, _ := config.Conf.AnalyticsConfig.AnalyticsParams()
+	if err = redis.SetAgreement(key, string(data), cacheTTL); err != nil {
+		zap.L().Error("redis.SetAgreement() failed", zap.String("key", key), zap.Error(err))
+	}
+	return json.Unmarshal(data, v)
+}
+
+// GetAgreementSummary 按题型计算一致性指标，filter.QuizType为空时计算全部支持的题型
+func GetAgreementSummary(filter *models.RecordFilter, refresh bool) (res []*TypeAgreement, err error) {
+	if _, ok := agreementLevels[filter.QuizType]; filter.QuizType != "" && !ok {
+		return nil, ErrorAgreementUnsupported
+	}
+	err = cachedAgreement(agreementCacheKey("summary", filter), refresh, &res, func() (interface{}, error) {
+		_, minPairItems := config.Conf.AnalyticsConfig.AnalyticsParams()
+		labels, err := loadLabels(filter)
+		if err != nil {
+			return nil, err
+		}
+		list := make([]*TypeAgreement, 0, len(labels))
+		for _, code := range quizkind.Codes() {
+			ls, ok := labels[code]
+			if !ok {
+				continue
+			}
+			kind, _ := quizkind.Get(code)
+			level := agreementLevels[code]
+			list = append(list, &TypeAgreement{
+				QuizType:  code,
+				Kind:      kind.Name(),
+				Level:     levelNames[level],
+				Agreement: analytics.Compute(ls, level, minPairItems),
+			})
+		}
+		return list, nil
+	})
+	return res, err
+}
+
+// ListQuizAgreement 分页获取某一题型逐题的标注分布与一致率，一致率低的题目在前
+func ListQuizAgreement(filter *models.RecordFilter, page, size int, refresh bool) (data map[string]interface{}, err error) {
+	if _, ok := agreementLevels[filter.QuizType]; !ok {
+		return nil, ErrorAgreementUnsupported
+	}
+	var stats []*analytics.UnitStat
+	err = cachedAgreement(agreementCacheKey("quizzes", filter), refresh, &stats, func() (interface{}, error) {
+		labels, err := loadLabels(filter)
+		if err != nil {
+			return nil, err
+		}
+		units := analytics.Units(labels[filter.QuizType])
+		// 少于两人作答的题目没有一致率，排在最后
+		sort.SliceStable(units, func(i, j int) bool {
+			a, b := units[i].PercentAgreement, units[j].PercentAgreement
+			if a == nil || b == nil {
+				return a != nil && b == nil
+			}
+			return *a < *b
+		})
+		return units, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	total := len(stats)
+	from, to := (page-1)*size, page*size
+	if from > total {
+		from = total
+	}
+	if to > total {
+		to = total
+	}
+	return map[string]interface{}{
+		"total":   total,
+		"quizzes": stats[from:to],
+	}, nil
+}
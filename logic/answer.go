@@ -0,0 +1,19 @@
+package logic
+
+import (
+	"scgptEval/models"
+	"scgptEval/pkg/quizkind"
+)
+
+// 答案校验：按题目类型检查提交的答案，并转换为该题型的规范形式存储
+
+var ErrorInvalidAnswer = quizkind.ErrorInvalidAnswer
+
+// NormalizeAnswer 校验用户的答案并转换为规范形式
+func NormalizeAnswer(quiz *models.Quiz, userID int64, answer string) (normalized string, err error) {
+	kind, err := quizkind.Get(quiz.Type)
+	if err != nil {
+		return "", err
+	}
+	return quizkind.NormalizeAnswerFor(kind, quiz.Options, answer, userID, quiz.ID)
+}
@@ -0,0 +1,200 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"scgptEval/dao/mysql"
+	"scgptEval/dao/redis"
+	"scgptEval/models"
+	"strings"
+	"time"
+)
+
+// 评测项目：拥有一部分题目、起止时间与参与的用户，出题、提交、刷题量与刷题排行按评测项目隔离
+// 评测项目id为0即全局题库，不限参与用户与时间，与引入评测项目之前的行为一致
+
+var (
+	ErrorEmptyCampaignName   = errors.New("评测项目名称不能为空")
+	ErrorInvalidCampaignTime = errors.New("结束时间需晚于开始时间")
+	ErrorNotEnrolled         = errors.New("未参与该评测项目")
+	ErrorCampaignClosed      = errors.New("评测项目未开始或已结束")
+)
+
+// CampaignInput 新建或更新评测项目时提交的字段
+type CampaignInput struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Strategy    string     `json:"strategy"` // 可选，出题策略，为空时使用配置的默认策略
+	StartAt     time.Time  `json:"start_at"` // 可选，缺省时为当前时间，即立即开始
+	EndAt       *time.Time `json:"end_at"`   // 可选，缺省时不设结束时间
+}
+
+// buildCampaign 校验评测项目字段并构造评测项目
+func buildCampaign(in *CampaignInput) (*models.Campaign, error) {
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return nil, ErrorEmptyCampaignName
+	}
+	if in.Strategy != "" {
+		if _, err := GetQuizStrategy(in.Strategy); err != nil {
+			return nil, err
+		}
+	}
+	// mysql的严格模式不接受零值时间，开始时间缺省时取当前时间，结束时间缺省时为NULL
+	startAt, endAt := in.StartAt, in.EndAt
+	if startAt.IsZero() {
+		startAt = time.Now()
+	}
+	if endAt != nil && endAt.IsZero() {
+		endAt = nil
+	}
+	if endAt != nil && !endAt.After(startAt) {
+		return nil, ErrorInvalidCampaignTime
+	}
+	return &models.Campaign{
+		Name:        name,
+		Description: in.Description,
+		Strategy:    in.Strategy,
+		StartAt:     startAt,
+		EndAt:       endAt,
+	}, nil
+}
+
+// CreateCampaign 新建评测项目
+func CreateCampaign(in *CampaignInput) (c *models.Campaign, err error) {
+	if c, err = buildCampaign(in); err != nil {
+		return nil, err
+	}
+	if err = mysql.CreateCampaign(c); err != nil {
+		return nil, err
+	}
+	// 新评测项目的题库为空，标记为已重建，避免首次出题时误判为题库集合丢失
+	if err = redis.MarkQuizBaseSynced(c.ID); err != nil {
+		zap.L().Error("redis.MarkQuizBaseSynced() failed", zap.Int64("campaign_id", c.ID), zap.Error(err))
+	}
+	return c, nil
+}
+
+// UpdateCampaign 更新评测项目
+func UpdateCampaign(campaignID int64, in *CampaignInput) (err error) {
+	c, err := buildCampaign(in)
+	if err != nil {
+		return err
+	}
+	c.ID = campaignID
+	return mysql.UpdateCampaign(c)
+}
+
+// ListCampaigns 分页获取评测项目
+func ListCampaigns(page, size int) (data map[string]interface{}, err error) {
+	list, total, err := mysql.ListCampaigns(page, size)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"total":     total,
+		"campaigns": list,
+	}, nil
+}
+
+// ListUserCampaigns 获取用户参与的评测项目
+func ListUserCampaigns(userID int64) (list []*models.Campaign, err error) {
+	return mysql.ListUserCampaigns(userID)
+}
+
+// EnrollUsers 将用户加入评测项目
+func EnrollUsers(campaignID int64, userIds []int64) (err error) {
+	if _, err = mysql.GetCampaign(campaignID); err != nil {
+		return err
+	}
+	seen := make(map[int64]bool, len(userIds))
+	unique := make([]int64, 0, len(userIds))
+	for _, id := range userIds {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return mysql.EnrollUsers(campaignID, unique)
+}
+
+// UnenrollUser 将用户移出评测项目
+func UnenrollUser(campaignID, userID int64) (err error) {
+	if _, err = mysql.GetCampaign(campaignID); err != nil {
+		return err
+	}
+	return mysql.UnenrollUser(campaignID, userID)
+}
+
+// checkCampaignExist campaignID非0时检查评测项目是否存在
+func checkCampaignExist(campaignID int64) (err error) {
+	if campaignID == 0 {
+		return nil
+	}
+	_, err = mysql.GetCampaign(campaignID)
+	return err
+}
+
+// checkCampaignEnrolled 检查用户能否查看评测项目的数据：用户需已参与，评测项目结束后仍可查看
+// campaignID为0(全局题库)时返回nil
+func checkCampaignEnrolled(campaignID, userID int64) (c *models.Campaign, err error) {
+	if campaignID == 0 {
+		return nil, nil
+	}
+	if c, err = mysql.GetCampaign(campaignID); err != nil {
+		return nil, err
+	}
+	enrolled, err := mysql.CheckEnrolled(campaignID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !enrolled {
+		return nil, ErrorNotEnrolled
+	}
+	return c, nil
+}
+
+// checkCampaignAccess 检查用户能否在评测项目中出题与提交：用户需已参与且评测项目正在进行中
+// campaignID为0(全局题库)时返回nil
+func checkCampaignAccess(campaignID, userID int64) (c *models.Campaign, err error) {
+	if c, err = checkCampaignEnrolled(campaignID, userID); err != nil || c == nil {
+		return nil, err
+	}
+	if !c.Open(time.Now()) {
+		return nil, ErrorCampaignClosed
+	}
+	return c, nil
+}
+
+// AssignQuizzes 将题目划入评测项目(campaignID为0时划回全局题库)，未下线的题目随之移到新的题库集合
+func AssignQuizzes(campaignID int64, quizIds []int64) (err error) {
+	if err = checkCampaignExist(campaignID); err != nil {
+		return err
+	}
+	old, err := mysql.AssignQuizzes(campaignID, quizIds)
+	if err != nil {
+		return err
+	}
+	for i := range old {
+		quiz := &old[i]
+		if quiz.Status != models.QuizStatusActive || quiz.CampaignID == campaignID {
+			continue
+		}
+		oldCampaignID := quiz.CampaignID
+		quiz.CampaignID = campaignID
+		// mysql为准，redis写入失败只打log，可通过SyncQuizBase修复
+		if err = moveQuizBase(quiz, oldCampaignID); err != nil {
+			zap.L().Error("moveQuizBase() failed", zap.Int64("quiz_id", quiz.ID), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// GetUserAmount 获取用户在评测项目中的刷题量，campaignID为0时为全局题库，评测项目需用户已参与
+func GetUserAmount(campaignID, userID int64) (amount int64, err error) {
+	if _, err = checkCampaignEnrolled(campaignID, userID); err != nil {
+		return 0, err
+	}
+	return redis.GetUserAmount(campaignID, fmt.Sprint(userID))
+}
@@ -0,0 +1,99 @@
+package logic
+
+import (
+	"scgptEval/config"
+	"scgptEval/dao/mysql"
+	"scgptEval/models"
+	"scgptEval/pkg/analytics"
+	"scgptEval/pkg/quizkind"
+	"strconv"
+	"time"
+)
+
+// 共识标签：把同一道题多名用户的答案聚合为最终标签与置信度，分别用多数投票、信任分加权投票与Dawid–Skene EM计算
+// 答案按比对键聚合(模型对比题为胜出的模型)，不同题型分别计算；自由文本题不参与聚合
+
+// ConsensusReport 一次共识标签聚合的结果
+type ConsensusReport struct {
+	Quizzes    int       `json:"quizzes"` // 得到共识标签的题目数
+	Labels     int       `json:"labels"`  // 写入的共识标签数(每道题每种方法一条)
+	ComputedAt time.Time `json:"computed_at"`
+}
+
+// loadConsensusLabels 读取全部答案，按题型分组为标注，标注值为答案的比对键
+func loadConsensusLabels() (labels map[string][]analytics.Label, err error) {
+	labels = make(map[string][]analytics.Label)
+	err = mysql.IterateAnswers(&models.RecordFilter{}, func(quizID int64, quizType string, userID int64, answer string) error {
+		if _, ok := agreementLevels[quizType]; !ok {
+			return nil
+		}
+		kind, err := quizkind.Get(quizType)
+		if err != nil {
+			return nil
+		}
+		labels[quizType] = append(labels[quizType], analytics.Label{
+			Unit:   strconv.FormatInt(quizID, 10),
+			Coder:  userID,
+			Values: []string{quizkind.AnswerKey(kind, answer)},
+		})
+		return nil
+	})
+	return labels, err
+}
+
+// RecomputeConsensus 由做题记录重新聚合全部题目的共识标签并写入mysql
+func RecomputeConsensus() (report *ConsensusReport, err error) {
+	minLabels, iterations := config.Conf.ConsensusConfig.ConsensusParams()
+	labels, err := loadConsensusLabels()
+	if err != nil {
+		return nil, err
+	}
+	weights, err := trustWeights()
+	if err != nil {
+		return nil, err
+	}
+	weight := func(userID int64) float64 { return trustWeight(weights, userID) }
+
+	var results []*models.ConsensusLabel
+	quizzes := make(map[int64]bool)
+	for _, typeLabels := range labels {
+		// Dawid–Skene需要用同题型的全部答案估计标注员的混淆矩阵，答案少的题目在结果中再过滤
+		methods := map[string][]*analytics.Consensus{
+			models.ConsensusMajority:   analytics.Vote(typeLabels, nil),
+			models.ConsensusWeighted:   analytics.Vote(typeLabels, weight),
+			models.ConsensusDawidSkene: analytics.DawidSkene(typeLabels, iterations),
+		}
+		for method, cs := range methods {
+			for _, c := range cs {
+				if c.Labels < minLabels {
+					continue
+				}
+				quizID, _ := strconv.ParseInt(c.Unit, 10, 64)
+				quizzes[quizID] = true
+				results = append(results, &models.ConsensusLabel{
+					QuizID:     quizID,
+					Method:     method,
+					Label:      c.Values[0],
+					Confidence: c.Confidence,
+					Labels:     c.Labels,
+				})
+			}
+		}
+	}
+	if err = mysql.ReplaceConsensusLabels(results); err != nil {
+		return nil, err
+	}
+	return &ConsensusReport{Quizzes: len(quizzes), Labels: len(results), ComputedAt: time.Now()}, nil
+}
+
+// ListConsensusLabels 分页获取共识标签，置信度低的在前
+func ListConsensusLabels(page, size int, quizID int64, method string) (data map[string]interface{}, err error) {
+	list, total, err := mysql.ListConsensusLabels(page, size, quizID, method)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"total":  total,
+		"labels": list,
+	}, nil
+}
@@ -0,0 +1,105 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"scgptEval/config"
+	"scgptEval/dao/mysql"
+	"scgptEval/dao/redis"
+	"scgptEval/models"
+	"scgptEval/pkg/quizkind"
+	"strconv"
+)
+
+// 金标准题：带有标准答案的题目，按配置的比例混入出题中，不计入剩余题目数，
+// 标注员无法从出题结果中区分；提交时与标准答案比对，用于统计标注员的作答正确率
+
+var ErrorInvalidGoldAnswer = errors.New("标准答案不合法")
+
+// normalizeGoldAnswer 按题目类型校验标准答案并转换为规范形式
+// 标准答案的写法与标注员提交的答案相同，模型对比题按选项中回答的原始顺序作答
+func normalizeGoldAnswer(kind quizkind.Kind, options string, raw json.RawMessage) (string, error) {
+	if kind.Code() == quizkind.CodeFreeText {
+		return "", fmt.Errorf("%w: 自由文本题不能设为金标准题", ErrorInvalidGoldAnswer)
+	}
+	if len(raw) == 0 {
+		return "", fmt.Errorf("%w: 金标准题需提供标准答案", ErrorInvalidGoldAnswer)
+	}
+	answer := string(raw)
+	var str string
+	if json.Unmarshal(raw, &str) == nil {
+		answer = str
+	}
+	gold, err := kind.NormalizeAnswer(options, answer)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrorInvalidGoldAnswer, err)
+	}
+	return gold, nil
+}
+
+// checkGold 金标准题返回规范化后的答案是否与标准答案一致，非金标准题返回nil
+func checkGold(quiz *models.Quiz, answer string) *bool {
+	if !quiz.IsGold {
+		return nil
+	}
+	kind, err := quizkind.Get(quiz.Type)
+	if err != nil {
+		return nil
+	}
+	correct := quizkind.MatchGold(kind, answer, quiz.GoldAnswer)
+	return &correct
+}
+
+// goldCount 本次出题中混入的金标准题数，按比例计算，小数部分按概率取整
+func goldCount(need int) int {
+	x := float64(need) * config.Conf.QuizConfig.GoldShare()
+	n := int(x)
+	if rand.Float64() < x-float64(n) {
+		n++
+	}
+	return n
+}
+
+// sampleGoldQuizIDs 随机获取用户在评测项目中未刷过的至多num个金标准题id
+func sampleGoldQuizIDs(campaignID, userID int64, num int) (quizIds []string, err error) {
+	if num <= 0 {
+		return nil, nil
+	}
+	if config.Conf.QuizConfig.QuizSource() == QuizSourceRedis {
+		if err = ensureUserQuizSet(userID); err != nil {
+			return nil, err
+		}
+		quizIds, err = redis.SampleUntriedGold(campaignID, fmt.Sprint(userID), int64(num))
+		if !errors.Is(err, redis.ErrorQuizBaseNotExist) {
+			return quizIds, err
+		}
+		// 题库集合丢失时由普通题目的出题流程触发重建，此处直接回退到mysql
+	}
+	doneQuizIds, err := mysql.GetDoneQuizID(userID)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := mysql.GetUntriedGoldQuizIDs(campaignID, doneQuizIds, num)
+	if err != nil {
+		return nil, err
+	}
+	quizIds = make([]string, 0, len(ids))
+	for _, id := range ids {
+		quizIds = append(quizIds, strconv.FormatInt(id, 10))
+	}
+	return quizIds, nil
+}
+
+// ListGoldAccuracy 分页获取用户在金标准题上的正确率，正确率低的用户在前
+func ListGoldAccuracy(page, size int, userID, minAnswered int64) (data map[string]interface{}, err error) {
+	list, total, err := mysql.ListGoldAccuracy(page, size, userID, minAnswered)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"total": total,
+		"users": list,
+	}, nil
+}
@@ -0,0 +1,115 @@
+package logic
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"scgptEval/dao/mysql"
+	"scgptEval/models"
+	"scgptEval/pkg/quizkind"
+	"time"
+)
+
+// 做题记录历史：标注员按提交时间从新到旧翻看自己的做题记录，以游标分页
+
+var ErrorInvalidCursor = errors.New("分页游标不合法")
+
+// HistoryItem 一条做题记录及其题目内容
+type HistoryItem struct {
+	RecordID       int64       `json:"record_id"`
+	QuizID         int64       `json:"quiz_id"`
+	QuizType       string      `json:"quiz_type"`
+	Kind           string      `json:"kind,omitempty"`
+	Content        string      `json:"content"`
+	Options        interface{} `json:"options,omitempty"` // 按用户渲染的选项，只在记录详情中返回
+	SelectedOption string      `json:"selected_option"`
+	CreatedAt      time.Time   `json:"created_at"`
+
+	Revisions []*models.RecordRevision `json:"revisions,omitempty"` // 被替换的历史答案，只在记录详情中返回
+}
+
+// UserHistory 一页做题记录
+type UserHistory struct {
+	Records    []*HistoryItem `json:"records"`
+	NextCursor string         `json:"next_cursor"` // 下一页的游标，为空时没有更多记录
+}
+
+// encodeCursor 游标为最后一条记录的提交时间(UTC纳秒时间戳)与id
+func encodeCursor(d *models.RecordDetail) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d.%d", d.CreatedAt.UTC().UnixNano(), d.RecordID)))
+}
+
+// decodeCursor 解析游标，返回的时间为UTC，与数据库连接的时区配置无关
+func decodeCursor(cursor string) (before time.Time, beforeID int64, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, ErrorInvalidCursor
+	}
+	var nano int64
+	if n, err := fmt.Sscanf(string(data), "%d.%d", &nano, &beforeID); err != nil || n != 2 || beforeID <= 0 {
+		return time.Time{}, 0, ErrorInvalidCursor
+	}
+	return time.Unix(0, nano).UTC(), beforeID, nil
+}
+
+// GetUserHistory 按提交时间从新到旧获取用户符合条件的至多limit条做题记录，cursor为上一页返回的游标，为空时从最新的记录开始
+func GetUserHistory(userID int64, filter *models.RecordFilter, cursor string, limit int) (res *UserHistory, err error) {
+	var before time.Time
+	var beforeID int64
+	if cursor != "" {
+		if before, beforeID, err = decodeCursor(cursor); err != nil {
+			return nil, err
+		}
+	}
+	filter.UserID = userID
+	// 多取一条用于判断是否还有下一页
+	list, err := mysql.ListUserRecords(filter, before, beforeID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	res = &UserHistory{Records: make([]*HistoryItem, 0, len(list))}
+	if len(list) > limit {
+		list = list[:limit]
+		res.NextCursor = encodeCursor(list[limit-1])
+	}
+	for _, d := range list {
+		res.Records = append(res.Records, newHistoryItem(d))
+	}
+	return res, nil
+}
+
+// GetUserRecord 获取用户的一条做题记录详情，含按用户渲染的题目选项与修改前的答案
+func GetUserRecord(userID, recordID int64) (item *HistoryItem, err error) {
+	d, err := mysql.GetUserRecord(userID, recordID)
+	if err != nil {
+		return nil, err
+	}
+	item = newHistoryItem(d)
+	if item.Revisions, err = mysql.GetRecordRevisions(recordID); err != nil {
+		return nil, err
+	}
+	kind, err := quizkind.Get(d.QuizType)
+	if err != nil {
+		// 题型无法识别时仍返回题干与答案
+		zap.L().Error("unknown quiz type", zap.Int64("quiz_id", d.QuizID), zap.String("type", d.QuizType))
+		return item, nil
+	}
+	item.Kind = kind.Name()
+	if item.Options, err = quizkind.RenderFor(kind, d.Options, userID, d.QuizID); err != nil {
+		zap.L().Error("invalid quiz options", zap.Int64("quiz_id", d.QuizID), zap.Error(err))
+		item.Options = nil
+	}
+	return item, nil
+}
+
+func newHistoryItem(d *models.RecordDetail) *HistoryItem {
+	return &HistoryItem{
+		RecordID:       d.RecordID,
+		QuizID:         d.QuizID,
+		QuizType:       d.QuizType,
+		Content:        d.Content,
+		SelectedOption: d.SelectOption,
+		CreatedAt:      d.CreatedAt,
+	}
+}
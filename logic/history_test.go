@@ -0,0 +1,39 @@
+package logic
+
+import (
+	"errors"
+	"scgptEval/models"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	at := time.Date(2024, 5, 1, 8, 0, 0, 0, shanghai)
+	before, beforeID, err := decodeCursor(encodeCursor(&models.RecordDetail{RecordID: 42, CreatedAt: at}))
+	if err != nil {
+		t.Fatalf("decodeCursor() failed: %v", err)
+	}
+	// 无论记录的时间读出时带哪个时区，游标都解析为同一时刻的UTC时间
+	if !before.Equal(at) || before.Location() != time.UTC || beforeID != 42 {
+		t.Fatalf("decodeCursor = %v %d, want %v UTC 42", before, beforeID, at.UTC())
+	}
+}
+
+// TestCursorTie 提交时间相同的记录以id区分，游标保留两者
+func TestCursorTie(t *testing.T) {
+	at := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	a, aID, _ := decodeCursor(encodeCursor(&models.RecordDetail{RecordID: 7, CreatedAt: at}))
+	b, bID, _ := decodeCursor(encodeCursor(&models.RecordDetail{RecordID: 6, CreatedAt: at.In(time.Local)}))
+	if !a.Equal(b) || aID != 7 || bID != 6 {
+		t.Fatalf("cursors = (%v, %d) (%v, %d), want the same time with ids 7 and 6", a, aID, b, bID)
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	for _, cursor := range []string{"!", "MTIz", "MTIzLjA", "YWJj"} { // 非base64、缺少id、id为0、非数字
+		if _, _, err := decodeCursor(cursor); !errors.Is(err, ErrorInvalidCursor) {
+			t.Errorf("decodeCursor(%q) error = %v, want ErrorInvalidCursor", cursor, err)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package logic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"scgptEval/config"
+	"scgptEval/dao/mysql"
+	"scgptEval/dao/redis"
+	"scgptEval/models"
+	"scgptEval/pkg/quizkind"
+	"scgptEval/pkg/rating"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 被评测模型的登记与排行榜：由模型对比题的做题记录计算胜率、Elo与Bradley–Terry分数
+
+var (
+	ErrorEmptyModelID       = errors.New("模型id不能为空")
+	ErrorModelNotRegistered = errors.New("模型未登记")
+)
+
+const (
+	btIterations     = 200
+	bootstrapSeed    = 1
+	ratingConfidence = 0.95
+)
+
+// ModelRating 排行榜中一个模型的成绩
+type ModelRating struct {
+	ModelID  string `json:"model_id"`
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+	*rating.WinStat
+	Elo   float64         `json:"elo"`
+	EloCI rating.Interval `json:"elo_ci"`
+	BT    float64         `json:"bradley_terry"`
+	BTCI  rating.Interval `json:"bradley_terry_ci"`
+}
+
+// ModelLeaderboard 模型排行榜，按Bradley–Terry分数降序
+type ModelLeaderboard struct {
+	Models      []*ModelRating `json:"models"`
+	Comparisons int            `json:"comparisons"` // 参与计算的两两比较次数
+	ComputedAt  time.Time      `json:"computed_at"`
+}
+
+// CreateEvalModel 登记被评测的模型
+func CreateEvalModel(m *models.EvalModel) (err error) {
+	m.ModelID = strings.TrimSpace(m.ModelID)
+	if m.ModelID == "" {
+		return ErrorEmptyModelID
+	}
+	if m.Name == "" {
+		m.Name = m.ModelID
+	}
+	return mysql.CreateEvalModel(m)
+}
+
+// ListEvalModels 获取全部已登记的模型
+func ListEvalModels() ([]models.EvalModel, error) {
+	ms, err := mysql.ListEvalModels()
+	if err != nil {
+		return nil, err
+	}
+	if ms == nil {
+		ms = make([]models.EvalModel, 0)
+	}
+	return ms, nil
+}
+
+// registeredModels 已登记的模型id集合
+func registeredModels() (registered map[string]bool, err error) {
+	ms, err := mysql.ListEvalModels()
+	if err != nil {
+		return nil, err
+	}
+	registered = make(map[string]bool, len(ms))
+	for _, m := range ms {
+		registered[m.ModelID] = true
+	}
+	return registered, nil
+}
+
+// checkQuizModels 模型对比题中的模型均需已登记
+func checkQuizModels(quiz *models.Quiz, registered map[string]bool) error {
+	if quiz.Type != quizkind.CodeComparison {
+		return nil
+	}
+	var o quizkind.ComparisonOptions
+	if err := json.Unmarshal([]byte(quiz.Options), &o); err != nil {
+		return err
+	}
+	for _, r := range o.Responses {
+		if !registered[r.ModelID] {
+			return fmt.Errorf("%w: %s", ErrorModelNotRegistered, r.ModelID)
+		}
+	}
+	return nil
+}
+
+// loadComparisons 读取全部模型对比题的答案，每条答案展开为一组两两比较：选中的模型胜过其余每个模型，平局时两两平局
+// 启用信任分时每次比较以作答标注员的信任分为权重
+func loadComparisons() (groups [][]rating.Comparison, err error) {
+	weights, err := trustWeights()
+	if err != nil {
+		return nil, err
+	}
+	err = mysql.IterateComparisonAnswers(quizkind.CodeComparison, func(recordID, userID int64, answer string) error {
+		var a quizkind.ComparisonAnswer
+		if err := json.Unmarshal([]byte(answer), &a); err != nil || len(a.Order) < 2 {
+			zap.L().Warn("invalid comparison answer", zap.Int64("record_id", recordID))
+			return nil
+		}
+		w := trustWeight(weights, userID)
+		var comps []rating.Comparison
+		if a.Choice == quizkind.PairwiseTie {
+			for i := 0; i < len(a.Order); i++ {
+				for j := i + 1; j < len(a.Order); j++ {
+					comps = append(comps, rating.Comparison{A: a.Order[i], B: a.Order[j], Score: 0.5, Weight: w})
+				}
+			}
+		} else {
+			for _, model := range a.Order {
+				if model != a.ModelID {
+					comps = append(comps, rating.Comparison{A: a.ModelID, B: model, Score: 1, Weight: w})
+				}
+			}
+		}
+		groups = append(groups, comps)
+		return nil
+	})
+	return groups, err
+}
+
+// ComputeModelLeaderboard 由做题记录重新计算模型排行榜并写入缓存
+// 计算需读取全部模型对比题的答案并做bootstrap，只由定时任务与管理员接口调用，缓存不过期，由下一次计算覆盖
+func ComputeModelLeaderboard() (board *ModelLeaderboard, err error) {
+	eloK, rounds := config.Conf.RatingConfig.Params()
+	groups, err := loadComparisons()
+	if err != nil {
+		return nil, err
+	}
+	comps := rating.Flatten(groups)
+	ms, err := mysql.ListEvalModels()
+	if err != nil {
+		return nil, err
+	}
+
+	wins := rating.WinRates(comps)
+	elo := rating.Elo(comps, eloK)
+	bt := rating.BradleyTerry(comps, btIterations)
+	eloCI := rating.Bootstrap(groups, rounds, ratingConfidence, bootstrapSeed, func(c []rating.Comparison) map[string]float64 {
+		return rating.Elo(c, eloK)
+	})
+	btCI := rating.Bootstrap(groups, rounds, ratingConfidence, bootstrapSeed, func(c []rating.Comparison) map[string]float64 {
+		return rating.BradleyTerry(c, btIterations)
+	})
+
+	entries := make(map[string]*ModelRating)
+	newEntry := func(modelID string) *ModelRating {
+		e := &ModelRating{ModelID: modelID, Name: modelID, WinStat: new(rating.WinStat),
+			Elo: rating.InitialRating, BT: rating.InitialRating}
+		entries[modelID] = e
+		return e
+	}
+	for _, m := range ms {
+		e := newEntry(m.ModelID)
+		e.Name, e.Provider = m.Name, m.Provider
+	}
+	for modelID, stat := range wins {
+		e, ok := entries[modelID]
+		if !ok {
+			// 记录中出现但已不在登记表中的模型
+			e = newEntry(modelID)
+		}
+		e.WinStat = stat
+		e.Elo, e.EloCI = elo[modelID], eloCI[modelID]
+		e.BT, e.BTCI = bt[modelID], btCI[modelID]
+	}
+
+	board = &ModelLeaderboard{Models: make([]*ModelRating, 0, len(entries)), Comparisons: len(comps), ComputedAt: time.Now()}
+	for _, e := range entries {
+		board.Models = append(board.Models, e)
+	}
+	sort.Slice(board.Models, func(i, j int) bool {
+		a, b := board.Models[i], board.Models[j]
+		if a.BT != b.BT {
+			return a.BT > b.BT
+		}
+		return a.ModelID < b.ModelID
+	})
+
+	if data, err := json.Marshal(board); err == nil {
+		if err = redis.SetModelLeaderboard(string(data), 0); err != nil {
+			zap.L().Error("redis.SetModelLeaderboard() failed", zap.Error(err))
+		}
+	}
+	return board, nil
+}
+
+// GetModelLeaderboard 获取缓存的模型排行榜，computed_at为计算时间
+// 不在请求中计算，尚未计算过时返回空的排行榜
+func GetModelLeaderboard() (board *ModelLeaderboard, err error) {
+	data, err := redis.GetModelLeaderboard()
+	if err != nil {
+		return nil, err
+	}
+	board = &ModelLeaderboard{Models: make([]*ModelRating, 0)}
+	if data == "" {
+		return board, nil
+	}
+	if err = json.Unmarshal([]byte(data), board); err != nil {
+		return nil, err
+	}
+	return board, nil
+}
+
+// WarmModelLeaderboard 尚未缓存模型排行榜时计算一次，用于服务启动时
+func WarmModelLeaderboard() (err error) {
+	data, err := redis.GetModelLeaderboard()
+	if err != nil || data != "" {
+		return err
+	}
+	_, err = ComputeModelLeaderboard()
+	return err
+}
@@ -1,21 +1,74 @@
 package logic
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"go.uber.org/zap"
 	"math/rand"
+	"scgptEval/config"
 	"scgptEval/dao/mysql"
 	"scgptEval/dao/redis"
+	"scgptEval/models"
+	"scgptEval/pkg/quizkind"
+	"strconv"
 	"time"
 )
 
-// SubmitQuiz 提交题目的logic层逻辑
-// 1.数据库：插入刷题记录record, 并更新用户最后刷题时间user —— 事务操作
-// 2.缓存：更新三个Key
-func SubmitQuiz(userID, quizID int64, selectOptions string) (err error) {
-	if err = mysql.SubmitQuiz(userID, quizID, selectOptions); err != nil {
+// SubmitQuiz 提交题目的logic层逻辑，campaignID为题目所属的评测项目，0表示全局题库
+// 0.校验：题目需属于该评测项目且用户可在其中作答；答案需符合题目的类型与选项，并转换为规范形式，金标准题与标准答案比对
+// 1.租约：消费用户持有的租约，题目已达到目标作答数时拒绝提交；金标准题不限作答数，无需租约
+// 2.数据库：插入刷题记录record(含金标准题是否答对), 并更新用户最后刷题时间user —— 事务操作
+// 3.缓存：更新用户刷题相关的三个Key、各统计窗口的刷题量及题目作答数，题目达到目标作答数后移出题库集合
+func SubmitQuiz(userID, campaignID, quizID int64, selectOptions string) (err error) {
+	quiz, err := mysql.GetQuiz(quizID)
+	if err != nil {
+		if !errors.Is(err, mysql.ErrorQuizNotExist) {
+			zap.L().Error("mysql.GetQuiz() failed", zap.Int64("quiz_id", quizID), zap.Error(err))
+		}
+		return
+	}
+	if quiz.CampaignID != campaignID {
+		// 题目不属于该评测项目
+		return mysql.ErrorQuizNotExist
+	}
+	if _, err = checkCampaignAccess(campaignID, userID); err != nil {
+		return
+	}
+	if selectOptions, err = NormalizeAnswer(quiz, userID, selectOptions); err != nil {
+		return
+	}
+	goldCorrect := checkGold(quiz, selectOptions)
+
+	uid, qid := fmt.Sprint(userID), fmt.Sprint(quizID)
+	target := config.Conf.QuizConfig.Target()
+	// 先检查租约，作答写入并计数后再释放，重复提交或写入失败时租约仍然有效；
+	// 未持有租约的用户在检查时预留名额，写入失败时立即归还
+	leased := config.Conf.QuizConfig.LeaseTTL() > 0 && !quiz.IsGold
+	if leased {
+		var reserved bool
+		if reserved, err = redis.CheckLease(uid, qid, target); err != nil {
+			if !errors.Is(err, redis.ErrorQuizSaturated) {
+				zap.L().Error("redis.CheckLease() failed",
+					zap.Int64("user_id", userID),
+					zap.Int64("quiz_id", quizID), zap.Error(err))
+			}
+			return
+		}
+		if reserved {
+			defer func() {
+				if err == nil {
+					return
+				}
+				if err := redis.ReleaseLease(campaignID, uid, qid); err != nil {
+					zap.L().Error("redis.ReleaseLease() failed",
+						zap.Int64("user_id", userID),
+						zap.Int64("quiz_id", quizID), zap.Error(err))
+				}
+			}()
+		}
+	}
+
+	if err = mysql.SubmitQuiz(userID, quizID, selectOptions, goldCorrect); err != nil {
 		if errors.Is(err, mysql.ErrorQuizNotExist) {
 			return
 		} else if errors.Is(err, mysql.ErrorRecordExist) {
@@ -27,81 +80,237 @@ func SubmitQuiz(userID, quizID int64, selectOptions string) (err error) {
 		return
 	}
 
-	if err = redis.SubmitQuiz(fmt.Sprint(userID), fmt.Sprint(quizID)); err != nil {
+	windows := currentWindows(time.Now())
+	// 金标准题用于评估标注员，不计入刷题量与排行榜
+	if _, err = redis.SubmitQuiz(campaignID, uid, qid, quiz.IsGold, target, windows, config.Conf.RankingConfig.RankingRetention()); err != nil {
 		zap.L().Error("redis.SubmitQuiz() failed",
 			zap.Int64("user_id", userID),
 			zap.Int64("quiz_id", quizID), zap.Error(err))
 		return
 	}
+	if leased {
+		// 释放失败时租约到期后自动回收，期间该名额被多占用，不影响本次提交
+		if err := redis.ReleaseLease(campaignID, uid, qid); err != nil {
+			zap.L().Error("redis.ReleaseLease() failed",
+				zap.Int64("user_id", userID),
+				zap.Int64("quiz_id", quizID), zap.Error(err))
+		}
+	}
 	return
 }
 
-// GetUntriedQuizzes 获取未刷过的题目
-func GetUntriedQuizzes(quizNum int, userID int64) (quizList []map[string]interface{}, err error) {
-	// 1.先从record中找出用户刷过的题目id
-	doneQuizIds, err := mysql.GetDoneQuizID(userID)
+// ReviseAnswer 修改用户已提交的答案，只能在提交后配置的时限内修改，时限为0时不允许修改
+// 被替换的答案保留在修改记录中；修改不改变用户刷题量与题目作答数，redis中的刷题量与题库无需更新
+// 由答案计算的共识标签、一致性指标缓存、模型排行榜缓存与信任分不会立即更新，
+// 在各自的定时任务下次运行或缓存过期后才反映修改后的答案
+func ReviseAnswer(userID, campaignID, quizID int64, selectOptions string) (err error) {
+	timeout := config.Conf.QuizConfig.RevisionTimeout()
+	if timeout <= 0 {
+		return mysql.ErrorRevisionExpired
+	}
+	quiz, err := mysql.GetQuiz(quizID)
 	if err != nil {
-		return nil, err
+		if !errors.Is(err, mysql.ErrorQuizNotExist) {
+			zap.L().Error("mysql.GetQuiz() failed", zap.Int64("quiz_id", quizID), zap.Error(err))
+		}
+		return
+	}
+	if quiz.CampaignID != campaignID {
+		return mysql.ErrorQuizNotExist
+	}
+	if _, err = checkCampaignAccess(campaignID, userID); err != nil {
+		return
+	}
+	if selectOptions, err = NormalizeAnswer(quiz, userID, selectOptions); err != nil {
+		return
 	}
+	_, err = mysql.ReviseRecord(userID, quizID, selectOptions, checkGold(quiz, selectOptions), time.Now().Add(-timeout))
+	if err != nil && !errors.Is(err, mysql.ErrorRecordNotExist) && !errors.Is(err, mysql.ErrorRevisionExpired) {
+		zap.L().Error("mysql.ReviseRecord() failed",
+			zap.Int64("user_id", userID),
+			zap.Int64("quiz_id", quizID), zap.Error(err))
+	}
+	return
+}
+
+// UntriedQuizzes 获取未刷过题目的结果
+type UntriedQuizzes struct {
+	Quizzes   []map[string]interface{} `json:"quizzes"`
+	Remaining int64                    `json:"remaining"` // 除本次返回的题目外，仍未刷过的题目数
+	Exhausted bool                     `json:"exhausted"` // 本次返回后题库已无未刷过的题目
+}
+
+func newUntriedQuizzes(quizList []map[string]interface{}, untried int64) *UntriedQuizzes {
+	if quizList == nil {
+		quizList = make([]map[string]interface{}, 0)
+	}
+	remaining := untried - int64(len(quizList))
+	if remaining < 0 {
+		remaining = 0
+	}
+	return &UntriedQuizzes{
+		Quizzes:   quizList,
+		Remaining: remaining,
+		Exhausted: remaining == 0,
+	}
+}
+
+// 出题数据源
+const (
+	QuizSourceRedis = "redis" // 默认：在redis中对题库集合与用户已刷题集合做差集并随机抽取
+	QuizSourceMySQL = "mysql" // NOT IN + ORDER BY RAND()，题量大时较慢，作为兜底
+)
+
+// leaseOverSample 启用租约时按需要题数的倍数抽取候选题目，弥补其中已被他人预留满的题目
+const leaseOverSample = 3
 
-	// 2.从题库中找出未刷过的指定数量题目
-	// fixme 暂未设计{剩余题目数}小于{quizNum}的逻辑
-	quizzes, err := mysql.GetUntriedQuizzes(doneQuizIds, quizNum)
+// GetUntriedQuizzes 按出题策略获取评测项目中未刷过的题目，剩余题目数不足quizNum时返回全部剩余题目
+// campaignID为0时为全局题库；strategyName为空时使用评测项目或配置的出题策略，seed为0时使用配置的种子
+// 启用租约时优先返回用户已领取但尚未提交的题目，新抽取的题目会为用户预留至租约到期
+// 按配置的比例混入用户未刷过的金标准题，金标准题不占用租约、不计入剩余题目数
+func GetUntriedQuizzes(quizNum int, userID, campaignID int64, strategyName string, seed int64) (res *UntriedQuizzes, err error) {
+	campaign, err := checkCampaignAccess(campaignID, userID)
 	if err != nil {
 		return nil, err
 	}
+	if strategyName == "" && campaign != nil {
+		strategyName = campaign.Strategy
+	}
+	if strategyName == "" {
+		strategyName = config.Conf.QuizConfig.QuizStrategy()
+	}
+	strategy, err := GetQuizStrategy(strategyName)
+	if err != nil {
+		return nil, err
+	}
+	if seed == 0 {
+		seed = config.Conf.QuizConfig.Seed
+	}
 
-	for _, quiz := range quizzes {
-		quizData := make(map[string]interface{})
-		options := make(map[string]string)
-		quizData["quiz_id"] = quiz.ID
-		quizData["type"] = quiz.Type
-		quizData["content"] = quiz.Content
-		// 对选项内容作反序列化
-		err := json.Unmarshal([]byte(quiz.Options), &options)
-		if err != nil {
-			continue
+	uid := fmt.Sprint(userID)
+	ttl := config.Conf.QuizConfig.LeaseTTL()
+	var leased []string
+	if ttl > 0 {
+		if leased, err = redis.GetUserLeases(campaignID, uid, int64(quizNum)); err != nil {
+			return nil, err
 		}
-		quizData["options"] = options
-
-		quizList = append(quizList, quizData)
 	}
-	return quizList, nil
-}
 
-// GetUntriedQuizzes2 获取未刷过的题目 (利用redis交集运算快速获取未刷过的题目id)
-func GetUntriedQuizzes2(quizNum int, userID int64) (quizList []map[string]interface{}, err error) {
-	// 1.先从redis取出用户未刷过的题目id列表
-	quizIds, err := redis.GetUntriedQuiz(fmt.Sprint(userID))
+	need := quizNum - len(leased)
+	gold, err := sampleGoldQuizIDs(campaignID, userID, goldCount(need))
+	if err != nil {
+		// 金标准题只用于考察作答质量，获取失败时本次不混入
+		zap.L().Error("sampleGoldQuizIDs() failed", zap.Int64("user_id", userID), zap.Error(err))
+		gold = nil
+	}
+	need -= len(gold)
+	sampleNum := need
+	if ttl > 0 {
+		sampleNum = need * leaseOverSample
+	}
+	candidates, untried, err := sampleUntriedQuizIDs(strategy, campaignID, &SelectParams{UserID: userID, Num: sampleNum, Seed: seed})
+	if err != nil {
+		return nil, err
+	}
 
-	// 2.从中随机挑选指定个id
-	// fixme 暂未设计{剩余题目数}小于{quizNum}的逻辑
-	ShuffleKnuth(quizIds)
-	selectedIds := quizIds[:quizNum]
+	quizIds := leased
+	if ttl > 0 {
+		held := make(map[string]bool, len(leased))
+		for _, id := range leased {
+			held[id] = true
+		}
+		fresh := make([]string, 0, len(candidates))
+		for _, id := range candidates {
+			if !held[id] {
+				fresh = append(fresh, id)
+			}
+		}
+		if candidates, err = redis.LeaseQuizzes(campaignID, uid, fresh, need, ttl, config.Conf.QuizConfig.Target()); err != nil {
+			return nil, err
+		}
+	} else if len(candidates) > need {
+		candidates = candidates[:need]
+	}
+	quizIds = append(quizIds, candidates...)
+	if len(quizIds) == 0 {
+		// 普通题目已刷完时不再单独下发金标准题
+		return newUntriedQuizzes(nil, untried), nil
+	}
+	quizIds = append(quizIds, gold...)
 
-	// 3.查询对应的记录
-	quizzes, err := mysql.GetQuizzes(selectedIds)
+	// 查询对应的记录
+	quizzes, err := mysql.GetQuizzes(quizIds)
 	if err != nil {
 		return nil, err
 	}
-	var quizData map[string]interface{}
-	for _, quiz := range quizzes {
-		quizData["quiz_id"] = quiz.ID
-		quizData["type"] = quiz.Type
-		quizData["content"] = quiz.Content
-		quizData["options"] = quiz.Options
+	if len(gold) > 0 {
+		// 打乱顺序，使金标准题混在普通题目中
+		rand.Shuffle(len(quizzes), func(i, j int) { quizzes[i], quizzes[j] = quizzes[j], quizzes[i] })
+	}
+	// 金标准题不计入剩余题目数
+	return newUntriedQuizzes(formatQuizzes(quizzes, userID), untried+int64(len(gold))), nil
+}
+
+// sampleUntriedQuizIDs 按出题策略从评测项目配置的出题数据源中挑选至多num个未刷过的题目id，并返回未刷过的题目总数
+func sampleUntriedQuizIDs(strategy QuizStrategy, campaignID int64, p *SelectParams) (quizIds []string, untried int64, err error) {
+	ids, untried, err := strategy.Select(getQuizStore(campaignID), p)
+	if errors.Is(err, redis.ErrorQuizBaseNotExist) {
+		// 题库集合丢失(例如redis被清空)，本次回退到mysql并在后台重建
+		zap.L().Warn("quiz base set not exist, fallback to mysql",
+			zap.Int64("user_id", p.UserID), zap.Int64("campaign_id", campaignID))
+		go func() {
+			if err := SyncQuizBase(); err != nil {
+				zap.L().Error("SyncQuizBase() failed", zap.Error(err))
+			}
+		}()
+		ids, untried, err = strategy.Select(mysqlQuizStore{campaignID: campaignID}, p)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	quizIds = make([]string, 0, len(ids))
+	for _, id := range ids {
+		quizIds = append(quizIds, strconv.FormatInt(id, 10))
+	}
+	return quizIds, untried, nil
+}
 
-		quizList = append(quizList, quizData)
+// ensureUserQuizSet 用户已刷题集合不存在时从mysql的做题记录中恢复
+// 恢复后的集合带有占位成员，没有做题记录的用户也只恢复一次
+func ensureUserQuizSet(userID int64) (err error) {
+	uid := fmt.Sprint(userID)
+	exist, err := redis.UserQuizSetExists(uid)
+	if err != nil || exist {
+		return err
 	}
-	return quizList, nil
+	doneQuizIds, err := mysql.GetDoneQuizID(userID)
+	if err != nil {
+		return err
+	}
+	return redis.InitUserQuizSet(uid, doneQuizIds...)
 }
 
-// ShuffleKnuth Fisher-Yates-Knuth快速洗牌算法
-func ShuffleKnuth(slice []string) {
-	random := rand.New(rand.NewSource(time.Now().UnixNano()))
-	n := len(slice)
-	for i := n - 1; i > 0; i-- {
-		j := random.Intn(i + 1)
-		slice[i], slice[j] = slice[j], slice[i]
+// formatQuizzes 将题目转换为响应数据，选项按题目类型转换为对应的结构，模型对比题按用户打乱回答顺序
+// 选项无法解析的题目(只可能是绕过校验写入的历史数据)不下发，并记录错误日志以便修复
+func formatQuizzes(quizzes []models.Quiz, userID int64) (quizList []map[string]interface{}) {
+	for _, quiz := range quizzes {
+		kind, err := quizkind.Get(quiz.Type)
+		if err != nil {
+			zap.L().Error("unknown quiz type", zap.Int64("quiz_id", quiz.ID), zap.String("type", quiz.Type))
+			continue
+		}
+		options, err := quizkind.RenderFor(kind, quiz.Options, userID, quiz.ID)
+		if err != nil {
+			zap.L().Error("invalid quiz options", zap.Int64("quiz_id", quiz.ID), zap.Error(err))
+			continue
+		}
+		quizList = append(quizList, map[string]interface{}{
+			"quiz_id": quiz.ID,
+			"type":    quiz.Type,
+			"kind":    kind.Name(),
+			"content": quiz.Content,
+			"options": options,
+		})
 	}
+	return quizList
 }
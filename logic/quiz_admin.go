@@ -0,0 +1,245 @@
+package logic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"go.uber.org/zap"
+	"scgptEval/config"
+	"scgptEval/dao/mysql"
+	"scgptEval/dao/redis"
+	"scgptEval/models"
+	"scgptEval/pkg/quizkind"
+	"strings"
+)
+
+var (
+	ErrorInvalidOptions  = quizkind.ErrorInvalidOptions
+	ErrorInvalidQuizType = quizkind.ErrorUnknownKind
+	ErrorEmptyContent    = errors.New("题干不能为空")
+	ErrorInvalidTarget   = errors.New("目标作答数需为非负整数")
+	ErrorInvalidPriority = errors.New("优先级需为整数")
+)
+
+// quizContentHash 计算题目内容哈希，选项中的对象按key排序后参与计算，与JSON书写格式无关
+func quizContentHash(quizType, content, options string) string {
+	var v interface{}
+	_ = json.Unmarshal([]byte(options), &v)
+	canonical, _ := json.Marshal(v)
+	sum := sha256.Sum256([]byte(quizType + "\x00" + strings.TrimSpace(content) + "\x00" + string(canonical)))
+	return hex.EncodeToString(sum[:])
+}
+
+// QuizInput 新建、更新或导入题目时提交的题目字段
+type QuizInput struct {
+	Type     string          `json:"quiz_type"`
+	Content  string          `json:"content"`
+	Options  json.RawMessage `json:"options"`
+	Target   int             `json:"target_judgements"` // 可选，0表示使用默认目标作答数
+	Priority int             `json:"priority"`          // 可选，按优先级出题时数值越大越先出
+
+	IsGold     bool            `json:"is_gold"`     // 可选，是否为金标准题
+	GoldAnswer json.RawMessage `json:"gold_answer"` // 金标准题的标准答案，写法与提交答案相同
+
+	CampaignID int64 `json:"campaign_id"` // 可选，所属评测项目，0表示全局题库
+}
+
+// buildQuiz 校验题目字段并构造题目
+func buildQuiz(in *QuizInput) (*models.Quiz, error) {
+	kind, err := quizkind.Get(in.Type)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(in.Content) == "" {
+		return nil, ErrorEmptyContent
+	}
+	if in.Target < 0 {
+		return nil, ErrorInvalidTarget
+	}
+	opts, err := kind.NormalizeOptions(in.Options)
+	if err != nil {
+		return nil, err
+	}
+	var gold string
+	if in.IsGold {
+		if gold, err = normalizeGoldAnswer(kind, opts, in.GoldAnswer); err != nil {
+			return nil, err
+		}
+	}
+	return &models.Quiz{
+		Type:             in.Type,
+		Content:          in.Content,
+		Options:          opts,
+		ContentHash:      quizContentHash(in.Type, in.Content, opts),
+		TargetJudgements: in.Target,
+		Priority:         in.Priority,
+		IsGold:           in.IsGold,
+		GoldAnswer:       gold,
+		CampaignID:       in.CampaignID,
+	}, nil
+}
+
+// addQuizBase 将新题目加入所属评测项目的redis题库集合，金标准题加入金标准题集合
+func addQuizBase(quizzes ...*models.Quiz) (err error) {
+	regular := make([]*models.Quiz, 0, len(quizzes))
+	gold := make(map[int64][]int64)
+	for _, quiz := range quizzes {
+		if quiz.IsGold {
+			gold[quiz.CampaignID] = append(gold[quiz.CampaignID], quiz.ID)
+		} else {
+			regular = append(regular, quiz)
+		}
+	}
+	if err = redis.AddQuizBase(config.Conf.QuizConfig.Target(), regular...); err != nil {
+		return err
+	}
+	for campaignID, ids := range gold {
+		if err = redis.AddGoldQuizzes(campaignID, ids...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveQuizBase 将未下线的题目移出原评测项目的题库集合与金标准题集合，再按是否为金标准题加入所属评测项目的集合
+func moveQuizBase(quiz *models.Quiz, oldCampaignID int64) (err error) {
+	if err = redis.RemoveQuizBase(oldCampaignID, quiz.ID); err != nil {
+		return err
+	}
+	if quiz.IsGold {
+		return redis.AddGoldQuizzes(quiz.CampaignID, quiz.ID)
+	}
+	return redis.RetargetQuiz(quiz.CampaignID, quiz.ID, quiz.TargetJudgements, config.Conf.QuizConfig.Target())
+}
+
+// checkModelsRegistered 模型对比题中的模型均需已登记
+func checkModelsRegistered(quiz *models.Quiz) error {
+	if quiz.Type != quizkind.CodeComparison {
+		return nil
+	}
+	registered, err := registeredModels()
+	if err != nil {
+		return err
+	}
+	return checkQuizModels(quiz, registered)
+}
+
+// CreateQuiz 新建题目：写入mysql并加入redis题库集合
+func CreateQuiz(in *QuizInput) (quiz *models.Quiz, err error) {
+	if quiz, err = buildQuiz(in); err != nil {
+		return nil, err
+	}
+	if err = checkModelsRegistered(quiz); err != nil {
+		return nil, err
+	}
+	if err = checkCampaignExist(quiz.CampaignID); err != nil {
+		return nil, err
+	}
+	if err = mysql.CreateQuiz(quiz); err != nil {
+		return nil, err
+	}
+	// mysql为准，redis写入失败只打log，可通过SyncQuizBase修复
+	if err = addQuizBase(quiz); err != nil {
+		zap.L().Error("addQuizBase() failed", zap.Int64("quiz_id", quiz.ID), zap.Error(err))
+	}
+	return quiz, nil
+}
+
+// UpdateQuiz 更新题目，未下线的题目按新的目标作答数加入或移出所属评测项目的redis题库集合，金标准题改为加入金标准题集合
+func UpdateQuiz(quizID int64, in *QuizInput, campaignID *int64) (err error) {
+	if campaignID != nil {
+		in.CampaignID = *campaignID
+	} else {
+		// 未指定评测项目时保留题目原所属的评测项目
+		cur, err := mysql.GetQuiz(quizID)
+		if err != nil {
+			return err
+		}
+		in.CampaignID = cur.CampaignID
+	}
+	quiz, err := buildQuiz(in)
+	if err != nil {
+		return err
+	}
+	if err = checkModelsRegistered(quiz); err != nil {
+		return err
+	}
+	if err = checkCampaignExist(quiz.CampaignID); err != nil {
+		return err
+	}
+	quiz.ID = quizID
+	oldCampaignID, err := mysql.UpdateQuiz(quiz)
+	if err != nil {
+		return err
+	}
+	if quiz.Status != models.QuizStatusActive {
+		return nil
+	}
+	// 先移出原评测项目的两个集合，再按是否为金标准题重新加入
+	if err = moveQuizBase(quiz, oldCampaignID); err != nil {
+		zap.L().Error("sync quiz base failed", zap.Int64("quiz_id", quizID), zap.Error(err))
+	}
+	return nil
+}
+
+// RetireQuiz 下线题目并移出所属评测项目的redis题库集合
+func RetireQuiz(quizID int64) (err error) {
+	quiz, err := mysql.GetQuiz(quizID)
+	if err != nil {
+		return err
+	}
+	if err = mysql.RetireQuiz(quizID); err != nil {
+		return err
+	}
+	if err = redis.RemoveQuizBase(quiz.CampaignID, quizID); err != nil {
+		zap.L().Error("redis.RemoveQuizBase() failed", zap.Int64("quiz_id", quizID), zap.Error(err))
+	}
+	return nil
+}
+
+// ListQuizzes 分页获取题目列表
+func ListQuizzes(page, size int, quizType, status string, campaignID int64) (data map[string]interface{}, err error) {
+	quizzes, total, err := mysql.ListQuizzes(page, size, quizType, status, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"total":   total,
+		"quizzes": quizzes,
+	}, nil
+}
+
+// SyncQuizBase 以mysql为准重建redis题目作答数，以及全局与各评测项目的金标准题集合与题库集合，
+// 已达到目标作答数的题目不再加入题库集合
+func SyncQuizBase() (err error) {
+	counts, err := mysql.CountJudgements()
+	if err != nil {
+		return err
+	}
+	if err = redis.ResetQuizJudgements(counts); err != nil {
+		return err
+	}
+	campaignIDs, err := mysql.GetCampaignIDs()
+	if err != nil {
+		return err
+	}
+	goldIds, err := mysql.GetActiveGoldQuizIDs()
+	if err != nil {
+		return err
+	}
+	// 没有金标准题的评测项目同样需要清空其金标准题集合
+	for _, id := range append([]int64{0}, campaignIDs...) {
+		if _, ok := goldIds[id]; !ok {
+			goldIds[id] = nil
+		}
+	}
+	if err = redis.ResetGoldQuizzes(goldIds); err != nil {
+		return err
+	}
+	targets, quizCampaigns, err := mysql.GetActiveQuizTargets()
+	if err != nil {
+		return err
+	}
+	return redis.ResetQuizBase(targets, quizCampaigns, campaignIDs, counts, config.Conf.QuizConfig.Target())
+}
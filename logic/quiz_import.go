@@ -0,0 +1,408 @@
+package logic
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"path/filepath"
+	"scgptEval/dao/mysql"
+	"scgptEval/models"
+	"strconv"
+	"strings"
+)
+
+// 题目批量导入：流式读取JSONL或CSV文件，逐行校验后分批写入mysql并同步redis题库集合
+
+const (
+	FormatJSONL = "jsonl"
+	FormatCSV   = "csv"
+
+	defaultImportBatchSize = 500
+	maxImportErrors        = 1000    // 报告中最多保留的错误行数，超出部分只计数
+	maxImportLineSize      = 4 << 20 // JSONL单行最大字节数
+)
+
+var (
+	ErrorUnknownFormat   = errors.New("不支持的文件格式，仅支持jsonl与csv")
+	ErrorInvalidHeader   = errors.New("CSV表头需包含quiz_type、content、options三列")
+	errorDuplicateQuiz   = errors.New("与题库中已有题目重复")
+	errorInvalidCampaign = errors.New("评测项目id需为非负整数")
+)
+
+// ImportError 导入失败的行及原因
+type ImportError struct {
+	Line int    `json:"line"`
+	Msg  string `json:"msg"`
+}
+
+// ImportReport 导入结果
+type ImportReport struct {
+	DryRun   bool           `json:"dry_run"`
+	Total    int            `json:"total"`    // 读取到的数据行数
+	Inserted int            `json:"inserted"` // 成功导入的行数，dry-run时为通过校验的行数
+	Failed   int            `json:"failed"`
+	Errors   []*ImportError `json:"errors"`
+}
+
+func (r *ImportReport) addError(line int, err error) {
+	r.Failed++
+	if len(r.Errors) < maxImportErrors {
+		r.Errors = append(r.Errors, &ImportError{Line: line, Msg: err.Error()})
+	}
+}
+
+// rowReader 逐行读取导入文件；rowErr为该行自身的格式错误，err非nil(含io.EOF)时结束读取
+type rowReader interface {
+	Next() (line int, row *QuizInput, rowErr, err error)
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *jsonlReader) Next() (line int, row *QuizInput, rowErr, err error) {
+	for r.scanner.Scan() {
+		r.line++
+		text := bytes.TrimSpace(r.scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		row = new(QuizInput)
+		if rowErr = json.Unmarshal(text, row); rowErr != nil {
+			return r.line, nil, fmt.Errorf("JSON格式有误: %v", rowErr), nil
+		}
+		return r.line, row, nil, nil
+	}
+	if err = r.scanner.Err(); err != nil {
+		return r.line, nil, nil, err
+	}
+	return r.line, nil, nil, io.EOF
+}
+
+type csvReader struct {
+	reader *csv.Reader
+	cols   map[string]int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrorInvalidHeader
+		}
+		return nil, err
+	}
+	cols := make(map[string]int, len(header))
+	for i, name := range header {
+		cols[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	for _, name := range []string{"quiz_type", "content", "options"} {
+		if _, ok := cols[name]; !ok {
+			return nil, ErrorInvalidHeader
+		}
+	}
+	return &csvReader{reader: reader, cols: cols}, nil
+}
+
+func (r *csvReader) Next() (line int, row *QuizInput, rowErr, err error) {
+	record, err := r.reader.Read()
+	if err != nil {
+		var pe *csv.ParseError
+		if errors.As(err, &pe) {
+			return pe.StartLine, nil, fmt.Errorf("CSV格式有误: %v", pe.Err), nil
+		}
+		return 0, nil, nil, err
+	}
+	line, _ = r.reader.FieldPos(0)
+	field := func(name string) string {
+		if i, ok := r.cols[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+	row = &QuizInput{
+		Type:    strings.TrimSpace(field("quiz_type")),
+		Content: field("content"),
+		Options: json.RawMessage(field("options")),
+	}
+	// target_judgements、priority、is_gold、gold_answer与campaign_id列可选，留空时为零值
+	if target := strings.TrimSpace(field("target_judgements")); target != "" {
+		if row.Target, err = strconv.Atoi(target); err != nil {
+			return line, nil, ErrorInvalidTarget, nil
+		}
+	}
+	if priority := strings.TrimSpace(field("priority")); priority != "" {
+		if row.Priority, err = strconv.Atoi(priority); err != nil {
+			return line, nil, ErrorInvalidPriority, nil
+		}
+	}
+	if isGold := strings.TrimSpace(field("is_gold")); isGold != "" {
+		if row.IsGold, err = strconv.ParseBool(isGold); err != nil {
+			return line, nil, fmt.Errorf("%w: is_gold需为true或false", ErrorInvalidGoldAnswer), nil
+		}
+	}
+	if gold := field("gold_answer"); gold != "" {
+		row.GoldAnswer = json.RawMessage(gold)
+	}
+	if campaign := strings.TrimSpace(field("campaign_id")); campaign != "" {
+		if row.CampaignID, err = strconv.ParseInt(campaign, 10, 64); err != nil || row.CampaignID < 0 {
+			return line, nil, errorInvalidCampaign, nil
+		}
+	}
+	return line, row, nil, nil
+}
+
+// FormatFromFilename 根据文件扩展名推断导入导出格式
+func FormatFromFilename(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	case ".csv":
+		return FormatCSV
+	}
+	return ""
+}
+
+// pendingQuiz 通过逐行校验、等待批量写入的题目
+type pendingQuiz struct {
+	line int
+	quiz *models.Quiz
+}
+
+// importStore 导入题目读写题库的接口
+type importStore interface {
+	// QuizzesWithoutHash 获取尚未计算内容哈希的历史题目
+	QuizzesWithoutHash() ([]models.Quiz, error)
+	// UpdateQuizHash 补齐题目的内容哈希，与其他题目重复时返回mysql.ErrorQuizExist
+	UpdateQuizHash(quizID int64, hash string) error
+	// RegisteredModels 已登记的模型id
+	RegisteredModels() (map[string]bool, error)
+	// CampaignIDs 全部评测项目id
+	CampaignIDs() ([]int64, error)
+	// ExistingContentHashes 给定哈希中已存在于题库的部分
+	ExistingContentHashes(hashes []string) (map[string]bool, error)
+	// CreateQuizzes 在同一个事务中写入题目，任一题目重复时整批不写入并返回mysql.ErrorQuizExist
+	CreateQuizzes(quizzes []*models.Quiz) error
+	// CreateQuiz 写入一道题目，重复时返回mysql.ErrorQuizExist
+	CreateQuiz(quiz *models.Quiz) error
+	// AddQuizBase 将新写入的题目加入redis题库集合
+	AddQuizBase(quizzes []*models.Quiz) error
+}
+
+type mysqlImportStore struct{}
+
+func (mysqlImportStore) QuizzesWithoutHash() ([]models.Quiz, error) {
+	return mysql.GetQuizzesWithoutHash()
+}
+
+func (mysqlImportStore) UpdateQuizHash(quizID int64, hash string) error {
+	return mysql.UpdateQuizHash(quizID, hash)
+}
+
+func (mysqlImportStore) RegisteredModels() (map[string]bool, error) {
+	return registeredModels()
+}
+
+func (mysqlImportStore) CampaignIDs() ([]int64, error) {
+	return mysql.GetCampaignIDs()
+}
+
+func (mysqlImportStore) ExistingContentHashes(hashes []string) (map[string]bool, error) {
+	return mysql.GetExistingContentHashes(hashes)
+}
+
+func (mysqlImportStore) CreateQuizzes(quizzes []*models.Quiz) error {
+	return mysql.BatchCreateQuizzes(quizzes)
+}
+
+func (mysqlImportStore) CreateQuiz(quiz *models.Quiz) error {
+	return mysql.CreateQuiz(quiz)
+}
+
+func (mysqlImportStore) AddQuizBase(quizzes []*models.Quiz) error {
+	return addQuizBase(quizzes...)
+}
+
+// newRowReader 按格式创建逐行读取器
+func newRowReader(r io.Reader, format string) (rowReader, error) {
+	switch format {
+	case FormatJSONL:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxImportLineSize)
+		return &jsonlReader{scanner: scanner}, nil
+	case FormatCSV:
+		return newCSVReader(r)
+	}
+	return nil, ErrorUnknownFormat
+}
+
+// ImportQuizzes 批量导入题目，dryRun为true时只校验不写入
+// 遇到mysql/读取等非数据行错误时中止导入，并返回已处理部分的报告
+func ImportQuizzes(r io.Reader, format string, dryRun bool, batchSize int) (report *ImportReport, err error) {
+	return importQuizzes(mysqlImportStore{}, r, format, dryRun, batchSize)
+}
+
+func importQuizzes(store importStore, r io.Reader, format string, dryRun bool, batchSize int) (report *ImportReport, err error) {
+	if batchSize <= 0 {
+		batchSize = defaultImportBatchSize
+	}
+	rows, err := newRowReader(r, format)
+	if err != nil {
+		return nil, err
+	}
+	// 历史题目可能还没有内容哈希，先补齐以便查重；试导入时不写库，只在内存中计算
+	unhashed, err := backfillQuizHash(store, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	// 模型对比题中的模型需已登记
+	registered, err := store.RegisteredModels()
+	if err != nil {
+		return nil, err
+	}
+	// 题目所属的评测项目需已存在
+	campaignIDs, err := store.CampaignIDs()
+	if err != nil {
+		return nil, err
+	}
+	campaigns := make(map[int64]bool, len(campaignIDs)+1)
+	campaigns[0] = true
+	for _, id := range campaignIDs {
+		campaigns[id] = true
+	}
+
+	report = &ImportReport{DryRun: dryRun, Errors: make([]*ImportError, 0)}
+	seen := make(map[string]int) // 文件内已出现的内容哈希 -> 行号
+	batch := make([]*pendingQuiz, 0, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		defer func() { batch = batch[:0] }()
+		hashes := make([]string, 0, len(batch))
+		for _, p := range batch {
+			hashes = append(hashes, p.quiz.ContentHash)
+		}
+		exist, err := store.ExistingContentHashes(hashes)
+		if err != nil {
+			return err
+		}
+		pending := make([]*pendingQuiz, 0, len(batch))
+		for _, p := range batch {
+			if exist[p.quiz.ContentHash] || unhashed[p.quiz.ContentHash] {
+				report.addError(p.line, errorDuplicateQuiz)
+				continue
+			}
+			pending = append(pending, p)
+		}
+		if dryRun || len(pending) == 0 {
+			report.Inserted += len(pending)
+			return nil
+		}
+		quizzes := make([]*models.Quiz, 0, len(pending))
+		for _, p := range pending {
+			quizzes = append(quizzes, p.quiz)
+		}
+		err = store.CreateQuizzes(quizzes)
+		if errors.Is(err, mysql.ErrorQuizExist) {
+			// 查重之后其他导入写入了相同的题目，整批已回滚，逐条写入以找出重复的行
+			quizzes, err = createQuizzesOneByOne(store, pending, report)
+		}
+		if err != nil {
+			return err
+		}
+		report.Inserted += len(quizzes)
+		if err = store.AddQuizBase(quizzes); err != nil {
+			zap.L().Error("addQuizBase() failed", zap.Int("count", len(quizzes)), zap.Error(err))
+		}
+		return nil
+	}
+
+	for {
+		line, row, rowErr, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return report, err
+		}
+		report.Total++
+		if rowErr != nil {
+			report.addError(line, rowErr)
+			continue
+		}
+		quiz, err := buildQuiz(row)
+		if err == nil {
+			err = checkQuizModels(quiz, registered)
+		}
+		if err == nil && !campaigns[quiz.CampaignID] {
+			err = mysql.ErrorCampaignNotExist
+		}
+		if err != nil {
+			report.addError(line, err)
+			continue
+		}
+		if first, ok := seen[quiz.ContentHash]; ok {
+			report.addError(line, fmt.Errorf("与第%d行重复", first))
+			continue
+		}
+		seen[quiz.ContentHash] = line
+		batch = append(batch, &pendingQuiz{line: line, quiz: quiz})
+		if len(batch) >= batchSize {
+			if err = flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+	if err = flush(); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// createQuizzesOneByOne 逐条写入题目，重复的题目记为所在行的错误，返回写入成功的题目
+func createQuizzesOneByOne(store importStore, pending []*pendingQuiz, report *ImportReport) (created []*models.Quiz, err error) {
+	created = make([]*models.Quiz, 0, len(pending))
+	for _, p := range pending {
+		// 回滚前已分配的id不再有效
+		p.quiz.ID = 0
+		if err = store.CreateQuiz(p.quiz); errors.Is(err, mysql.ErrorQuizExist) {
+			report.addError(p.line, errorDuplicateQuiz)
+			continue
+		} else if err != nil {
+			return created, err
+		}
+		created = append(created, p.quiz)
+	}
+	return created, nil
+}
+
+// backfillQuizHash 为历史题目补齐内容哈希
+// dryRun为true时不写入mysql，返回这些题目的内容哈希供查重；否则写入后返回空集合
+// 与已有题目内容相同的历史题目无法写入唯一的哈希，保持为空，查重时由已有的题目代替
+func backfillQuizHash(store importStore, dryRun bool) (unhashed map[string]bool, err error) {
+	quizzes, err := store.QuizzesWithoutHash()
+	if err != nil {
+		return nil, err
+	}
+	unhashed = make(map[string]bool)
+	for _, quiz := range quizzes {
+		hash := quizContentHash(quiz.Type, quiz.Content, quiz.Options)
+		if dryRun {
+			unhashed[hash] = true
+			continue
+		}
+		if err = store.UpdateQuizHash(quiz.ID, hash); err != nil && !errors.Is(err, mysql.ErrorQuizExist) {
+			return nil, err
+		}
+	}
+	return unhashed, nil
+}
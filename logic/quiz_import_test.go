@@ -0,0 +1,174 @@
+package logic
+
+import (
+	"errors"
+	"reflect"
+	"scgptEval/dao/mysql"
+	"scgptEval/models"
+	"strings"
+	"testing"
+)
+
+// fakeImportStore 内存中的题库，raced中的内容哈希模拟查重之后被其他导入写入的题目
+type fakeImportStore struct {
+	hashes  map[string]bool
+	raced   map[string]bool
+	created []*models.Quiz
+	based   int // 加入redis题库集合的题目数
+}
+
+func newFakeImportStore() *fakeImportStore {
+	return &fakeImportStore{hashes: make(map[string]bool), raced: make(map[string]bool)}
+}
+
+func (s *fakeImportStore) QuizzesWithoutHash() ([]models.Quiz, error) { return nil, nil }
+
+func (s *fakeImportStore) UpdateQuizHash(int64, string) error { return nil }
+
+func (s *fakeImportStore) RegisteredModels() (map[string]bool, error) {
+	return map[string]bool{"m1": true, "m2": true}, nil
+}
+
+func (s *fakeImportStore) CampaignIDs() ([]int64, error) { return []int64{3}, nil }
+
+func (s *fakeImportStore) ExistingContentHashes(hashes []string) (map[string]bool, error) {
+	exist := make(map[string]bool)
+	for _, h := range hashes {
+		if s.hashes[h] {
+			exist[h] = true
+		}
+	}
+	return exist, nil
+}
+
+func (s *fakeImportStore) CreateQuizzes(quizzes []*models.Quiz) error {
+	for _, q := range quizzes {
+		if s.raced[q.ContentHash] {
+			return mysql.ErrorQuizExist
+		}
+	}
+	for _, q := range quizzes {
+		s.create(q)
+	}
+	return nil
+}
+
+func (s *fakeImportStore) CreateQuiz(quiz *models.Quiz) error {
+	if s.raced[quiz.ContentHash] {
+		return mysql.ErrorQuizExist
+	}
+	s.create(quiz)
+	return nil
+}
+
+func (s *fakeImportStore) create(quiz *models.Quiz) {
+	quiz.ID = int64(len(s.created) + 1)
+	s.hashes[quiz.ContentHash] = true
+	s.created = append(s.created, quiz)
+}
+
+func (s *fakeImportStore) AddQuizBase(quizzes []*models.Quiz) error {
+	s.based += len(quizzes)
+	return nil
+}
+
+// errorLines 报告中出错的行号
+func errorLines(r *ImportReport) []int {
+	lines := make([]int, 0, len(r.Errors))
+	for _, e := range r.Errors {
+		lines = append(lines, e.Line)
+	}
+	return lines
+}
+
+func TestImportQuizzes(t *testing.T) {
+	const single = `{"quiz_type":"1","content":"q%s","options":{"a":"x","b":"y"}}`
+	row := func(n string) string { return strings.Replace(single, "%s", n, 1) }
+	tests := []struct {
+		name     string
+		format   string
+		input    string
+		dryRun   bool
+		inserted int
+		lines    []int // 出错的行号
+	}{
+		{"jsonl", FormatJSONL, row("1") + "\n\n" + row("2") + "\n", false, 2, []int{}},
+		{"malformed json", FormatJSONL, row("1") + "\n{\"quiz_type\":\n" + row("2"), false, 2, []int{2}},
+		{"bad options", FormatJSONL, `{"quiz_type":"1","content":"q","options":{"a":1}}` + "\n" +
+			`{"quiz_type":"3","content":"q","options":{"a":"x"}}` + "\n" + row("1"), false, 1, []int{1, 2}},
+		{"unknown type and empty content", FormatJSONL, `{"quiz_type":"9","content":"q","options":{}}` + "\n" +
+			`{"quiz_type":"1","content":" ","options":{"a":"x"}}`, false, 0, []int{1, 2}},
+		{"unregistered model", FormatJSONL,
+			`{"quiz_type":"7","content":"q","options":{"responses":[{"model_id":"m1","content":"x"},{"model_id":"m3","content":"y"}]}}`,
+			false, 0, []int{1}},
+		{"unknown campaign", FormatJSONL, `{"quiz_type":"1","content":"q","options":{"a":"x"},"campaign_id":4}` + "\n" +
+			`{"quiz_type":"1","content":"q","options":{"a":"x"},"campaign_id":3}`, false, 1, []int{1}},
+		{"duplicate in file", FormatJSONL, row("1") + "\n" + `{"quiz_type":"1","content":"q1","options":{"b":"y","a":"x"}}`,
+			false, 1, []int{2}},
+		{"dry run", FormatJSONL, row("1") + "\n" + row("2") + "\n{", true, 2, []int{3}},
+		{"csv", FormatCSV, "quiz_type,content,options,priority,is_gold\n" +
+			"1,q1,\"{\"\"a\"\":\"\"x\"\"}\",2,\n" +
+			"1,q2,\"{\"\"a\"\":\"\"x\"\"}\",high,\n" +
+			"1,q3,\"{\"\"a\"\":\"\"x\"\"}\",,maybe\n" +
+			"1,q4,not json,,\n" +
+			"1,\"q5,\"{}\n", false, 1, []int{3, 4, 5, 6}},
+	}
+	for _, tt := range tests {
+		store := newFakeImportStore()
+		report, err := importQuizzes(store, strings.NewReader(tt.input), tt.format, tt.dryRun, 2)
+		if err != nil {
+			t.Errorf("%s: importQuizzes() failed: %v", tt.name, err)
+			continue
+		}
+		if report.Inserted != tt.inserted || report.Failed != len(tt.lines) || report.Total != tt.inserted+len(tt.lines) ||
+			!reflect.DeepEqual(errorLines(report), tt.lines) {
+			t.Errorf("%s: report = %+v errors on %v, want %d inserted, errors on %v",
+				tt.name, report, errorLines(report), tt.inserted, tt.lines)
+		}
+		if tt.dryRun && (len(store.created) != 0 || store.based != 0) {
+			t.Errorf("%s: dry run wrote %d quizzes", tt.name, len(store.created))
+		} else if !tt.dryRun && (len(store.created) != tt.inserted || store.based != tt.inserted) {
+			t.Errorf("%s: created %d quizzes, %d added to the base, want %d", tt.name, len(store.created), store.based, tt.inserted)
+		}
+	}
+}
+
+func TestImportQuizzesInvalidInput(t *testing.T) {
+	if _, err := importQuizzes(newFakeImportStore(), strings.NewReader(""), "xml", false, 0); !errors.Is(err, ErrorUnknownFormat) {
+		t.Errorf("unknown format error = %v", err)
+	}
+	for _, header := range []string{"", "quiz_type,content\n"} {
+		if _, err := importQuizzes(newFakeImportStore(), strings.NewReader(header), FormatCSV, false, 0); !errors.Is(err, ErrorInvalidHeader) {
+			t.Errorf("header %q error = %v, want ErrorInvalidHeader", header, err)
+		}
+	}
+}
+
+// TestImportQuizzesDuplicates 题库中已有的题目报告为重复；查重之后被并发写入的题目逐条重试，只有重复的行失败
+func TestImportQuizzesDuplicates(t *testing.T) {
+	store := newFakeImportStore()
+	first, _ := buildQuiz(&QuizInput{Type: "1", Content: "q1", Options: []byte(`{"a":"x"}`)})
+	raced, _ := buildQuiz(&QuizInput{Type: "1", Content: "q3", Options: []byte(`{"a":"x"}`)})
+	store.hashes[first.ContentHash] = true
+	store.raced[raced.ContentHash] = true
+
+	input := `{"quiz_type":"1","content":"q1","options":{"a":"x"}}
+{"quiz_type":"1","content":"q2","options":{"a":"x"}}
+{"quiz_type":"1","content":"q3","options":{"a":"x"}}
+{"quiz_type":"1","content":"q4","options":{"a":"x"}}`
+	report, err := importQuizzes(store, strings.NewReader(input), FormatJSONL, false, 10)
+	if err != nil {
+		t.Fatalf("importQuizzes() failed: %v", err)
+	}
+	if report.Inserted != 2 || !reflect.DeepEqual(errorLines(report), []int{1, 3}) {
+		t.Fatalf("report = %+v errors on %v, want 2 inserted, errors on [1 3]", report, errorLines(report))
+	}
+	for _, e := range report.Errors {
+		if e.Msg != errorDuplicateQuiz.Error() {
+			t.Fatalf("line %d: %s, want duplicate", e.Line, e.Msg)
+		}
+	}
+	if len(store.created) != 2 || store.created[0].Content != "q2" || store.created[1].Content != "q4" || store.based != 2 {
+		t.Fatalf("created %d quizzes, %d added to the base", len(store.created), store.based)
+	}
+}
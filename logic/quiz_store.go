@@ -0,0 +1,153 @@
+package logic
+
+import (
+	"fmt"
+	"scgptEval/config"
+	"scgptEval/dao/mysql"
+	"scgptEval/dao/redis"
+	"scgptEval/models"
+	"strconv"
+)
+
+// 出题数据源：redis在题库集合与用户已刷题集合上做差集，mysql为兜底
+// 数据源限定在一个评测项目内，campaignID为0时为全局题库
+
+// getQuizStore 按配置获取评测项目的出题数据源
+func getQuizStore(campaignID int64) QuizStore {
+	if config.Conf.QuizConfig.QuizSource() == QuizSourceMySQL {
+		return mysqlQuizStore{campaignID: campaignID}
+	}
+	return redisQuizStore{campaignID: campaignID}
+}
+
+type mysqlQuizStore struct {
+	campaignID int64
+}
+
+func (s mysqlQuizStore) SampleUntried(userID int64, num int) ([]int64, int64, error) {
+	return s.untried(userID, num, mysql.GetUntriedQuizIDs)
+}
+
+func (s mysqlQuizStore) NeediestUntried(userID int64, num int) ([]int64, int64, error) {
+	return s.untried(userID, num, mysql.GetNeediestUntriedQuizIDs)
+}
+
+// candidatePageSize 分页遍历未刷过的题目时每页的题目数
+const candidatePageSize = 500
+
+func (s mysqlQuizStore) UntriedCandidates(userID int64, fn func(page []*models.QuizCandidate) error) (untried int64, err error) {
+	doneQuizIds, err := mysql.GetDoneQuizID(userID)
+	if err != nil {
+		return 0, err
+	}
+	err = mysql.IterateUntriedCandidates(s.campaignID, doneQuizIds, config.Conf.QuizConfig.Target(), candidatePageSize,
+		func(page []*models.QuizCandidate) error {
+			untried += int64(len(page))
+			return fn(page)
+		})
+	return untried, err
+}
+
+// untried 从mysql中获取未刷过的题目id及未刷过的题目总数
+func (s mysqlQuizStore) untried(userID int64, num int,
+	getIDs func(campaignID int64, doneQuizIds []int64, num, defaultTarget int) ([]int64, error)) (quizIds []int64, untried int64, err error) {
+	target := config.Conf.QuizConfig.Target()
+	// 1.先从record中找出用户刷过的题目id
+	doneQuizIds, err := mysql.GetDoneQuizID(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// 2.从题库中找出未刷过的指定数量题目
+	if num > 0 {
+		if quizIds, err = getIDs(s.campaignID, doneQuizIds, num, target); err != nil {
+			return nil, 0, err
+		}
+	}
+	if untried, err = mysql.CountUntriedQuizzes(s.campaignID, doneQuizIds, target); err != nil {
+		return nil, 0, err
+	}
+	return quizIds, untried, nil
+}
+
+type redisQuizStore struct {
+	campaignID int64
+}
+
+func (s redisQuizStore) SampleUntried(userID int64, num int) ([]int64, int64, error) {
+	uid := fmt.Sprint(userID)
+	// 1.用户已刷题集合缺失时(例如redis被清空)先从mysql恢复，避免重复出题
+	if err := ensureUserQuizSet(userID); err != nil {
+		return nil, 0, err
+	}
+	// 2.在redis中求差集并随机挑选指定个id，剩余题目数不足num时全部返回
+	ids, untried, err := redis.SampleUntriedQuiz(s.campaignID, uid, int64(num))
+	if err != nil {
+		return nil, 0, err
+	}
+	return parseQuizIDs(ids), untried, nil
+}
+
+func (s redisQuizStore) NeediestUntried(userID int64, num int) ([]int64, int64, error) {
+	uid := fmt.Sprint(userID)
+	if err := ensureUserQuizSet(userID); err != nil {
+		return nil, 0, err
+	}
+	// 差集只用于统计剩余题目数，再按距目标作答数的差值挑选
+	_, untried, err := redis.SampleUntriedQuiz(s.campaignID, uid, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	ids, err := redis.GetNeediestUntriedQuiz(s.campaignID, uid, int64(num))
+	if err != nil {
+		return nil, 0, err
+	}
+	quizIds := parseQuizIDs(ids)
+	if len(quizIds) >= num || int64(len(quizIds)) >= untried {
+		return quizIds, untried, nil
+	}
+	// 差值最大的题目大多已刷过，其余从未刷过的题目中随机补足
+	extra, _, err := redis.SampleUntriedQuiz(s.campaignID, uid, int64(num))
+	if err != nil {
+		return nil, 0, err
+	}
+	seen := make(map[int64]bool, len(quizIds))
+	for _, id := range quizIds {
+		seen[id] = true
+	}
+	for _, id := range parseQuizIDs(extra) {
+		if len(quizIds) >= num {
+			break
+		}
+		if !seen[id] {
+			seen[id] = true
+			quizIds = append(quizIds, id)
+		}
+	}
+	return quizIds, untried, nil
+}
+
+// UntriedCandidates 在redis中分批遍历未刷过的题目id，题目类型与优先级只存在mysql中，按批从mysql读取
+func (s redisQuizStore) UntriedCandidates(userID int64, fn func(page []*models.QuizCandidate) error) (int64, error) {
+	if err := ensureUserQuizSet(userID); err != nil {
+		return 0, err
+	}
+	return redis.ScanUntriedQuiz(s.campaignID, fmt.Sprint(userID), candidatePageSize, func(ids []string) error {
+		page, err := mysql.GetQuizCandidates(parseQuizIDs(ids))
+		if err != nil {
+			return err
+		}
+		return fn(page)
+	})
+}
+
+// parseQuizIDs 将redis中的题目id转换为int64，忽略无法解析的成员
+func parseQuizIDs(ids []string) []int64 {
+	quizIds := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if quizID, err := strconv.ParseInt(id, 10, 64); err == nil {
+			quizIds = append(quizIds, quizID)
+		}
+	}
+	return quizIds
+}
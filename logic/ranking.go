@@ -0,0 +1,265 @@
+package logic
+
+import (
+	"errors"
+	"go.uber.org/zap"
+	"scgptEval/config"
+	"scgptEval/dao/mysql"
+	"scgptEval/dao/redis"
+	"scgptEval/models"
+	"strconv"
+	"time"
+)
+
+// 刷题排行榜：除累计刷题量外，按日、周、月的统计窗口分别排名，
+// 窗口内的刷题量在提交时累加到redis中，窗口结束后由存档任务写入mysql，redis中的数据保留一段时间后过期
+
+var ErrorUnknownPeriod = errors.New("不支持的排行榜周期")
+
+// rankingPeriods 启用的按时间窗口统计的排行榜周期，未配置时为全部
+func rankingPeriods() []string {
+	configured := config.Conf.RankingConfig.RankingPeriods()
+	if len(configured) == 0 {
+		return models.RankingPeriods
+	}
+	periods := make([]string, 0, len(configured))
+	for _, p := range configured {
+		if models.WindowAt(p, time.Now()) == nil {
+			zap.L().Warn("unknown ranking period", zap.String("period", p))
+			continue
+		}
+		periods = append(periods, p)
+	}
+	return periods
+}
+
+// currentWindows t所在的各统计窗口
+func currentWindows(t time.Time) []*models.PeriodWindow {
+	t = t.In(config.Conf.RankingConfig.RankingLocation())
+	periods := rankingPeriods()
+	windows := make([]*models.PeriodWindow, 0, len(periods))
+	for _, p := range periods {
+		windows = append(windows, models.WindowAt(p, t))
+	}
+	return windows
+}
+
+// retainedWindows t所在及之前数据仍保留在redis中的各统计窗口
+func retainedWindows(t time.Time) []*models.PeriodWindow {
+	t = t.In(config.Conf.RankingConfig.RankingLocation())
+	retention := config.Conf.RankingConfig.RankingRetention()
+	var windows []*models.PeriodWindow
+	for _, p := range rankingPeriods() {
+		for w := models.WindowAt(p, t); w.EndAt.Add(retention).After(t); w = w.Prev() {
+			windows = append(windows, w)
+		}
+	}
+	return windows
+}
+
+// Ranking 刷题排行榜的一页及当前用户的名次
+type Ranking struct {
+	Window     *models.PeriodWindow `json:"window,omitempty"` // 统计窗口，累计刷题量时为空
+	Total      int64                `json:"total"`            // 上榜用户总数
+	Ranking    []map[string]string  `json:"ranking"`
+	Me         map[string]string    `json:"me"`         // 当前用户的名次与刷题量，未上榜时为null
+	Neighbours []map[string]string  `json:"neighbours"` // 当前用户及其前后各around名用户
+}
+
+// rankingSource 排行榜数据源：进行中或尚未存档的排行榜在redis中，已存档的在mysql中
+type rankingSource interface {
+	// page 获取第offset名之后的至多limit名及上榜用户总数
+	page(offset, limit int64) (rank []map[string]string, total int64, err error)
+	// userRank 获取用户从1开始的名次，未上榜时返回0
+	userRank(userID int64) (rank int64, err error)
+}
+
+type redisRanking struct {
+	campaignID int64
+	window     *models.PeriodWindow
+	byTrust    bool
+}
+
+func (r redisRanking) page(offset, limit int64) ([]map[string]string, int64, error) {
+	return redis.GetRanking(r.campaignID, r.window, offset, limit, r.byTrust)
+}
+
+func (r redisRanking) userRank(userID int64) (int64, error) {
+	return redis.GetUserRank(r.campaignID, r.window, strconv.FormatInt(userID, 10), r.byTrust)
+}
+
+type snapshotRanking struct {
+	campaignID int64
+	window     *models.PeriodWindow
+	byTrust    bool
+}
+
+func (r snapshotRanking) page(offset, limit int64) (rank []map[string]string, total int64, err error) {
+	list, total, err := mysql.GetRankingSnapshot(r.window.Period, r.window.Key, r.campaignID, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	rank = make([]map[string]string, 0, len(list))
+	for _, s := range list {
+		item := map[string]string{
+			"id":       strconv.FormatInt(s.UserID, 10),
+			"rank":     strconv.Itoa(s.Rank),
+			"username": s.Username,
+			"score":    strconv.FormatInt(s.Score, 10),
+		}
+		if r.byTrust {
+			item["weighted_score"] = strconv.FormatFloat(s.WeightedScore, 'f', 2, 64)
+		}
+		rank = append(rank, item)
+	}
+	return rank, total, nil
+}
+
+// userRank 存档中保存了窗口内每名用户的名次与刷题量，不在前几名的用户也能查到自己的名次
+func (r snapshotRanking) userRank(userID int64) (int64, error) {
+	return mysql.GetSnapshotRank(r.window.Period, r.window.Key, r.campaignID, userID)
+}
+
+// getRankingSource 获取评测项目的排行榜数据源，配置按信任分排名时按加权刷题量排名
+// period为空或all时为累计刷题量；否则为date(日期，为空时为当前)所在统计窗口的排行，已结束且已存档的窗口从存档中读取
+func getRankingSource(campaignID int64, period, date string) (src rankingSource, w *models.PeriodWindow, err error) {
+	byTrust := config.Conf.TrustConfig.RankingByTrust()
+	if period == "" || period == models.PeriodAll {
+		return redisRanking{campaignID: campaignID, byTrust: byTrust}, nil, nil
+	}
+	if !periodEnabled(period) {
+		return nil, nil, ErrorUnknownPeriod
+	}
+	loc := config.Conf.RankingConfig.RankingLocation()
+	now := time.Now().In(loc)
+	t := now
+	if date != "" {
+		if t, err = time.ParseInLocation("2006-01-02", date, loc); err != nil {
+			return nil, nil, ErrorInvalidTime
+		}
+	}
+	w = models.WindowAt(period, t)
+	if w.EndAt.After(now) {
+		return redisRanking{campaignID: campaignID, window: w, byTrust: byTrust}, w, nil
+	}
+	archived, err := mysql.RankingArchived(w.Period, w.Key, campaignID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if archived {
+		return snapshotRanking{campaignID: campaignID, window: w, byTrust: byTrust}, w, nil
+	}
+	// 尚未存档，redis中的数据过期前仍可读取
+	return redisRanking{campaignID: campaignID, window: w, byTrust: byTrust}, w, nil
+}
+
+// GetRanking 获取评测项目刷题排行榜中第offset名之后的至多limit名，以及用户的名次和前后各around名用户
+// period与date用于选择统计窗口，见getRankingSource；评测项目的排行榜仅对已参与的用户可见
+func GetRanking(campaignID, userID, offset, limit, around int64, period, date string) (res *Ranking, err error) {
+	if _, err = checkCampaignEnrolled(campaignID, userID); err != nil {
+		return nil, err
+	}
+	src, w, err := getRankingSource(campaignID, period, date)
+	if err != nil {
+		return nil, err
+	}
+	res = &Ranking{Window: w, Neighbours: make([]map[string]string, 0)}
+	if res.Ranking, res.Total, err = src.page(offset, limit); err != nil {
+		return nil, err
+	}
+	rank, err := src.userRank(userID)
+	if err != nil || rank == 0 {
+		return res, err
+	}
+	// 名次从1开始，用户前后各around名用户对应的偏移为[rank-1-around, rank-1+around]
+	start := rank - 1 - around
+	if start < 0 {
+		start = 0
+	}
+	if res.Neighbours, _, err = src.page(start, rank+around-start); err != nil {
+		return nil, err
+	}
+	id := strconv.FormatInt(userID, 10)
+	for _, item := range res.Neighbours {
+		if item["id"] == id {
+			res.Me = item
+			break
+		}
+	}
+	return res, nil
+}
+
+// periodEnabled 排行榜周期是否已启用
+func periodEnabled(period string) bool {
+	for _, p := range rankingPeriods() {
+		if p == period {
+			return true
+		}
+	}
+	return false
+}
+
+// RankingArchiveReport 一次排行榜存档的结果
+type RankingArchiveReport struct {
+	Rankings int `json:"rankings"` // 新存档的排行榜数
+	Entries  int `json:"entries"`  // 新存档的名次数
+}
+
+// ArchiveRankings 将全局与各评测项目已结束、尚未存档且仍在redis中的统计窗口排行榜存档到mysql
+func ArchiveRankings() (report *RankingArchiveReport, err error) {
+	campaignIDs, err := mysql.GetCampaignIDs()
+	if err != nil {
+		return nil, err
+	}
+	campaignIDs = append([]int64{0}, campaignIDs...)
+	now := time.Now().In(config.Conf.RankingConfig.RankingLocation())
+	retention := config.Conf.RankingConfig.RankingRetention()
+	batch := config.Conf.RankingConfig.ArchiveBatch()
+	byTrust := config.Conf.TrustConfig.RankingByTrust()
+
+	report = new(RankingArchiveReport)
+	for _, period := range rankingPeriods() {
+		// redis中的数据在窗口结束retention后过期，更早的窗口无需再检查
+		for w := models.WindowAt(period, now).Prev(); w.EndAt.Add(retention).After(now); w = w.Prev() {
+			for _, campaignID := range campaignIDs {
+				archived, err := archiveRanking(campaignID, w, batch, byTrust)
+				if err != nil {
+					return report, err
+				}
+				if archived > 0 {
+					report.Rankings++
+					report.Entries += archived
+				}
+			}
+		}
+	}
+	return report, nil
+}
+
+// archiveRanking 存档评测项目在统计窗口的完整排行榜，返回新存档的名次数，已存档或无数据时返回0
+// 从redis中每次读取batch名，全部读完后在一个事务中写入，避免只存档了一部分的排行榜被当作已存档
+func archiveRanking(campaignID int64, w *models.PeriodWindow, batch int64, byTrust bool) (archived int, err error) {
+	done, err := mysql.RankingArchived(w.Period, w.Key, campaignID)
+	if err != nil || done {
+		return 0, err
+	}
+	exist, err := redis.PeriodRankingExists(campaignID, w)
+	if err != nil || !exist {
+		return 0, err
+	}
+	var list []*models.RankingSnapshot
+	for offset := int64(0); ; offset += batch {
+		page, total, err := redis.GetPeriodSnapshot(campaignID, w, offset, batch, byTrust)
+		if err != nil {
+			return 0, err
+		}
+		list = append(list, page...)
+		if len(page) == 0 || offset+batch >= total {
+			break
+		}
+	}
+	if err = mysql.SaveRankingSnapshot(list); err != nil {
+		return 0, err
+	}
+	return len(list), nil
+}
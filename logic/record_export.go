@@ -0,0 +1,214 @@
+package logic
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"scgptEval/config"
+	"scgptEval/dao/mysql"
+	"scgptEval/models"
+	"strconv"
+	"time"
+)
+
+// 做题记录导出：联表题目内容，按JSONL或CSV流式写出，供训练/评测流水线使用
+
+var (
+	ErrorInvalidTime       = errors.New("时间格式有误，应为2006-01-02或RFC3339格式")
+	ErrorNoPseudonymSecret = errors.New("未设置环境变量" + config.PseudonymSecretEnv + "，无法假名化用户id")
+)
+
+// consensusMethods 导出的共识标签方法，CSV中每种方法占标签与置信度两列
+var consensusMethods = []string{models.ConsensusMajority, models.ConsensusWeighted, models.ConsensusDawidSkene}
+
+// exportColumns CSV导出的列，与exportRow的json字段一一对应，共识标签展开为每种方法两列
+var exportColumns = func() []string {
+	cols := []string{
+		"record_id", "quiz_id", "quiz_type", "content", "options",
+		"user_id", "selected_option", "created_at",
+	}
+	for _, m := range consensusMethods {
+		cols = append(cols, "consensus_"+m, "consensus_"+m+"_confidence")
+	}
+	return cols
+}()
+
+// exportBatchSize 导出时每批缓存的记录数，每批按其中的题目查询一次共识标签
+const exportBatchSize = 500
+
+// exportConsensus 导出记录所属题目在某种方法下的共识标签
+type exportConsensus struct {
+	Label      string  `json:"label"`
+	Confidence float64 `json:"confidence"`
+}
+
+// exportRow 导出数据集中的一条记录
+type exportRow struct {
+	RecordID       int64           `json:"record_id"`
+	QuizID         int64           `json:"quiz_id"`
+	QuizType       string          `json:"quiz_type"`
+	Content        string          `json:"content"`
+	Options        json.RawMessage `json:"options"`
+	UserID         string          `json:"user_id"` // 假名化时为HMAC值
+	SelectedOption string          `json:"selected_option"`
+	CreatedAt      time.Time       `json:"created_at"`
+
+	Consensus map[string]*exportConsensus `json:"consensus,omitempty"` // 方法 -> 共识标签，尚未聚合的题目为空
+}
+
+func (r *exportRow) csvRecord() []string {
+	record := []string{
+		strconv.FormatInt(r.RecordID, 10),
+		strconv.FormatInt(r.QuizID, 10),
+		r.QuizType,
+		r.Content,
+		string(r.Options),
+		r.UserID,
+		r.SelectedOption,
+		r.CreatedAt.Format(time.RFC3339),
+	}
+	for _, m := range consensusMethods {
+		if c, ok := r.Consensus[m]; ok {
+			record = append(record, c.Label, strconv.FormatFloat(c.Confidence, 'f', 4, 64))
+		} else {
+			record = append(record, "", "")
+		}
+	}
+	return record
+}
+
+// ParseTimeParam 解析时间参数，支持日期与RFC3339两种格式
+// 仅有日期且endOfDay为true时返回次日零点，便于作为不包含的结束时间
+func ParseTimeParam(s string, endOfDay bool) (t time.Time, err error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err = time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	if t, err = time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, ErrorInvalidTime
+}
+
+// pseudonymize 使用HMAC-SHA256对用户id做假名化，同一密钥下结果稳定，便于跨数据集关联
+// 保留完整的摘要，不截断以免降低碰撞与暴力枚举的难度
+func pseudonymize(secret string, userID int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(userID, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CheckExportParams 在开始写出之前校验导出参数
+func CheckExportParams(format string, pseudonymous bool) (err error) {
+	if format != FormatJSONL && format != FormatCSV {
+		return ErrorUnknownFormat
+	}
+	if pseudonymous && config.PseudonymSecret() == "" {
+		return ErrorNoPseudonymSecret
+	}
+	return nil
+}
+
+// ExportRecords 按筛选条件导出做题记录，返回导出的条数
+func ExportRecords(w io.Writer, format string, filter *models.RecordFilter, pseudonymous bool) (count int, err error) {
+	if err = CheckExportParams(format, pseudonymous); err != nil {
+		return 0, err
+	}
+	var secret string
+	if pseudonymous {
+		secret = config.PseudonymSecret()
+	}
+
+	var write func(r *exportRow) error
+	var flush func() error
+	switch format {
+	case FormatJSONL:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		write = func(r *exportRow) error { return enc.Encode(r) }
+		flush = func() error { return nil }
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err = cw.Write(exportColumns); err != nil {
+			return 0, err
+		}
+		write = func(r *exportRow) error { return cw.Write(r.csvRecord()) }
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	}
+
+	// 记录按批缓存，每批查询其中题目的共识标签后写出，内存占用与记录总数无关
+	batch := make([]*exportRow, 0, exportBatchSize)
+	writeBatch := func() error {
+		quizIds := make([]int64, 0, len(batch))
+		seen := make(map[int64]bool, len(batch))
+		for _, row := range batch {
+			if !seen[row.QuizID] {
+				seen[row.QuizID] = true
+				quizIds = append(quizIds, row.QuizID)
+			}
+		}
+		consensus, err := mysql.GetConsensusLabels(quizIds)
+		if err != nil {
+			return err
+		}
+		for _, row := range batch {
+			if labels := consensus[row.QuizID]; len(labels) > 0 {
+				row.Consensus = make(map[string]*exportConsensus, len(labels))
+				for m, l := range labels {
+					row.Consensus[m] = &exportConsensus{Label: l.Label, Confidence: l.Confidence}
+				}
+			}
+			if err := write(row); err != nil {
+				return fmt.Errorf("write record %d failed: %w", row.RecordID, err)
+			}
+			count++
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	err = mysql.IterateRecordDetails(filter, func(d *models.RecordDetail) error {
+		row := &exportRow{
+			RecordID:       d.RecordID,
+			QuizID:         d.QuizID,
+			QuizType:       d.QuizType,
+			Content:        d.Content,
+			Options:        json.RawMessage(d.Options),
+			UserID:         strconv.FormatInt(d.UserID, 10),
+			SelectedOption: d.SelectOption,
+			CreatedAt:      d.CreatedAt,
+		}
+		if !json.Valid(row.Options) {
+			// 历史数据中选项不是合法JSON时按字符串导出，避免整行JSON损坏
+			quoted, _ := json.Marshal(d.Options)
+			row.Options = quoted
+		}
+		if pseudonymous {
+			row.UserID = pseudonymize(secret, d.UserID)
+		}
+		if batch = append(batch, row); len(batch) < exportBatchSize {
+			return nil
+		}
+		return writeBatch()
+	})
+	if err == nil && len(batch) > 0 {
+		err = writeBatch()
+	}
+	if err != nil {
+		return count, err
+	}
+	return count, flush()
+}
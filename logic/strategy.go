@@ -0,0 +1,204 @@
+package logic
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"scgptEval/models"
+	"sort"
+)
+
+// 出题策略：从用户未刷过且仍可出题的题目中挑选本次要出的题目
+
+var ErrorUnknownStrategy = errors.New("不支持的出题策略")
+
+// 出题策略名称
+const (
+	QuizStrategyRandom     = "random"     // 默认：在未刷过的题目中随机抽取
+	QuizStrategyBalanced   = "balanced"   // 距目标作答数最远的题目优先，使各题的作答数趋于均衡
+	QuizStrategyStratified = "stratified" // 按题目类型分层抽取，各类型的题目数尽量相等
+	QuizStrategyPriority   = "priority"   // 优先级高的题目先出，同一优先级内随机
+	QuizStrategySequential = "sequential" // 按种子确定的固定顺序出题，同一种子下所有用户的顺序一致，便于复现实验
+)
+
+// QuizStore 出题策略读取题库的接口
+type QuizStore interface {
+	// SampleUntried 随机抽取用户未刷过的至多num个题目id，并返回未刷过的题目总数
+	SampleUntried(userID int64, num int) (quizIds []int64, untried int64, err error)
+	// NeediestUntried 获取用户未刷过的题目中距目标作答数最远的至多num个题目id，并返回未刷过的题目总数
+	NeediestUntried(userID int64, num int) (quizIds []int64, untried int64, err error)
+	// UntriedCandidates 分页遍历用户未刷过的题目信息，每页调用一次fn，返回未刷过的题目总数
+	UntriedCandidates(userID int64, fn func(page []*models.QuizCandidate) error) (untried int64, err error)
+}
+
+// SelectParams 出题参数
+type SelectParams struct {
+	UserID int64
+	Num    int
+	Seed   int64      // 仅sequential策略使用
+	Rand   *rand.Rand // stratified与priority策略的随机源，为nil时使用全局随机源
+}
+
+// intn 取[0, n)内的随机数
+func (p *SelectParams) intn(n int64) int64 {
+	if p.Rand != nil {
+		return p.Rand.Int63n(n)
+	}
+	return rand.Int63n(n)
+}
+
+// QuizStrategy 出题策略，返回挑选出的题目id及用户未刷过的题目总数
+type QuizStrategy interface {
+	Select(store QuizStore, p *SelectParams) (quizIds []int64, untried int64, err error)
+}
+
+var quizStrategies = map[string]QuizStrategy{
+	QuizStrategyRandom:     randomStrategy{},
+	QuizStrategyBalanced:   balancedStrategy{},
+	QuizStrategyStratified: stratifiedStrategy{},
+	QuizStrategyPriority:   priorityStrategy{},
+	QuizStrategySequential: sequentialStrategy{},
+}
+
+// GetQuizStrategy 根据名称获取出题策略
+func GetQuizStrategy(name string) (QuizStrategy, error) {
+	s, ok := quizStrategies[name]
+	if !ok {
+		return nil, ErrorUnknownStrategy
+	}
+	return s, nil
+}
+
+type randomStrategy struct{}
+
+func (randomStrategy) Select(store QuizStore, p *SelectParams) ([]int64, int64, error) {
+	return store.SampleUntried(p.UserID, p.Num)
+}
+
+type balancedStrategy struct{}
+
+func (balancedStrategy) Select(store QuizStore, p *SelectParams) ([]int64, int64, error) {
+	return store.NeediestUntried(p.UserID, p.Num)
+}
+
+type stratifiedStrategy struct{}
+
+func (stratifiedStrategy) Select(store QuizStore, p *SelectParams) ([]int64, int64, error) {
+	// 按类型分组，每组用蓄水池抽样随机保留至多num道，组内打乱后各组轮流取题，某类型取完后由其余类型补足
+	groups := make(map[string][]*models.QuizCandidate)
+	seen := make(map[string]int64)
+	untried, err := store.UntriedCandidates(p.UserID, func(page []*models.QuizCandidate) error {
+		for _, c := range page {
+			seen[c.Type]++
+			if len(groups[c.Type]) < p.Num {
+				groups[c.Type] = append(groups[c.Type], c)
+			} else if j := p.intn(seen[c.Type]); j < int64(p.Num) {
+				groups[c.Type][j] = c
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	types := make([]string, 0, len(seen))
+	for t := range seen {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		shuffleCandidates(groups[t], p)
+	}
+	quizIds := make([]int64, 0, p.Num)
+	for round := 0; len(quizIds) < p.Num; round++ {
+		picked := false
+		for _, t := range types {
+			if round < len(groups[t]) && len(quizIds) < p.Num {
+				quizIds = append(quizIds, groups[t][round].ID)
+				picked = true
+			}
+		}
+		if !picked {
+			break
+		}
+	}
+	return quizIds, untried, nil
+}
+
+// rankedCandidate 带排序键的候选题目
+type rankedCandidate struct {
+	*models.QuizCandidate
+	key uint64
+}
+
+// topCandidates 分页遍历未刷过的题目，只保留按less排在最前的至多num道，返回其id及未刷过的题目总数
+func topCandidates(store QuizStore, p *SelectParams, key func(c *models.QuizCandidate) uint64,
+	less func(a, b *rankedCandidate) bool) ([]int64, int64, error) {
+	kept := make([]*rankedCandidate, 0, 2*p.Num)
+	untried, err := store.UntriedCandidates(p.UserID, func(page []*models.QuizCandidate) error {
+		for _, c := range page {
+			kept = append(kept, &rankedCandidate{QuizCandidate: c, key: key(c)})
+		}
+		if len(kept) > p.Num {
+			sort.Slice(kept, func(i, j int) bool { return less(kept[i], kept[j]) })
+			kept = kept[:p.Num]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	sort.Slice(kept, func(i, j int) bool { return less(kept[i], kept[j]) })
+	quizIds := make([]int64, 0, len(kept))
+	for _, c := range kept {
+		quizIds = append(quizIds, c.ID)
+	}
+	return quizIds, untried, nil
+}
+
+type priorityStrategy struct{}
+
+func (priorityStrategy) Select(store QuizStore, p *SelectParams) ([]int64, int64, error) {
+	// 同一优先级内按随机键排序，即随机
+	return topCandidates(store, p, func(*models.QuizCandidate) uint64 {
+		return uint64(p.intn(math.MaxInt64))
+	}, func(a, b *rankedCandidate) bool {
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return a.key < b.key
+	})
+}
+
+type sequentialStrategy struct{}
+
+func (sequentialStrategy) Select(store QuizStore, p *SelectParams) ([]int64, int64, error) {
+	// 每道题的顺序只由种子与题目id决定，与题库中其他题目及用户无关，新增题目不会打乱已有题目的相对顺序
+	return topCandidates(store, p, func(c *models.QuizCandidate) uint64 {
+		return sequenceKey(p.Seed, c.ID)
+	}, func(a, b *rankedCandidate) bool {
+		if a.key != b.key {
+			return a.key < b.key
+		}
+		return a.ID < b.ID
+	})
+}
+
+// sequenceKey 种子与题目id的FNV-1a哈希，作为固定顺序下的排序键
+func sequenceKey(seed, quizID int64) uint64 {
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], uint64(seed))
+	binary.BigEndian.PutUint64(buf[8:], uint64(quizID))
+	h := fnv.New64a()
+	h.Write(buf[:])
+	return h.Sum64()
+}
+
+func shuffleCandidates(candidates []*models.QuizCandidate, p *SelectParams) {
+	for i := len(candidates) - 1; i > 0; i-- {
+		j := p.intn(int64(i + 1))
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	}
+}
@@ -0,0 +1,286 @@
+package logic
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"scgptEval/models"
+	"sort"
+	"testing"
+)
+
+// fakeQuizStore 内存中的题库，done为用户已刷过的题目
+type fakeQuizStore struct {
+	quizzes []*models.QuizCandidate
+	done    map[int64]bool
+	calls   []string // 策略调用过的数据源方法
+}
+
+func newFakeQuizStore(types []string, priorities []int, done ...int64) *fakeQuizStore {
+	s := &fakeQuizStore{done: make(map[int64]bool)}
+	for i, t := range types {
+		c := &models.QuizCandidate{ID: int64(i + 1), Type: t}
+		if priorities != nil {
+			c.Priority = priorities[i]
+		}
+		s.quizzes = append(s.quizzes, c)
+	}
+	for _, id := range done {
+		s.done[id] = true
+	}
+	return s
+}
+
+// fakePageSize 每页两道题，使策略跨页合并结果
+const fakePageSize = 2
+
+func (s *fakeQuizStore) untried() []*models.QuizCandidate {
+	var candidates []*models.QuizCandidate
+	for _, q := range s.quizzes {
+		if !s.done[q.ID] {
+			c := *q
+			candidates = append(candidates, &c)
+		}
+	}
+	return candidates
+}
+
+func (s *fakeQuizStore) UntriedCandidates(_ int64, fn func(page []*models.QuizCandidate) error) (int64, error) {
+	s.calls = append(s.calls, "candidates")
+	candidates := s.untried()
+	for i := 0; i < len(candidates); i += fakePageSize {
+		end := i + fakePageSize
+		if end > len(candidates) {
+			end = len(candidates)
+		}
+		if err := fn(candidates[i:end]); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(candidates)), nil
+}
+
+func (s *fakeQuizStore) SampleUntried(_ int64, num int) ([]int64, int64, error) {
+	s.calls = append(s.calls, "sample")
+	candidates := s.untried()
+	rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	return candidateIDs(candidates, num), int64(len(candidates)), nil
+}
+
+// NeediestUntried 按作答数从少到多，与redis和mysql数据源中不限作答数的题目的顺序一致
+func (s *fakeQuizStore) NeediestUntried(_ int64, num int) ([]int64, int64, error) {
+	s.calls = append(s.calls, "neediest")
+	candidates := s.untried()
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Judgements < candidates[j].Judgements
+	})
+	return candidateIDs(candidates, num), int64(len(candidates)), nil
+}
+
+func candidateIDs(candidates []*models.QuizCandidate, num int) []int64 {
+	ids := make([]int64, 0, num)
+	for _, c := range candidates {
+		if len(ids) >= num {
+			break
+		}
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func selectWith(t *testing.T, name string, store QuizStore, p *SelectParams) ([]int64, int64) {
+	t.Helper()
+	strategy, err := GetQuizStrategy(name)
+	if err != nil {
+		t.Fatalf("GetQuizStrategy(%q) failed: %v", name, err)
+	}
+	ids, untried, err := strategy.Select(store, p)
+	if err != nil {
+		t.Fatalf("%s Select failed: %v", name, err)
+	}
+	return ids, untried
+}
+
+func checkUntriedDistinct(t *testing.T, store *fakeQuizStore, ids []int64) {
+	t.Helper()
+	seen := make(map[int64]bool)
+	for _, id := range ids {
+		if store.done[id] {
+			t.Errorf("quiz %d already done but selected", id)
+		}
+		if seen[id] {
+			t.Errorf("quiz %d selected twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestGetQuizStrategyUnknown(t *testing.T) {
+	if _, err := GetQuizStrategy("nope"); !errors.Is(err, ErrorUnknownStrategy) {
+		t.Fatalf("expected ErrorUnknownStrategy, got %v", err)
+	}
+}
+
+func TestRandomStrategy(t *testing.T) {
+	store := newFakeQuizStore([]string{"1", "1", "2", "2", "3", "3"}, nil, 1, 2)
+	ids, untried := selectWith(t, QuizStrategyRandom, store, &SelectParams{Num: 3})
+	if len(ids) != 3 || untried != 4 {
+		t.Fatalf("got %d ids, untried %d; want 3, 4", len(ids), untried)
+	}
+	checkUntriedDistinct(t, store, ids)
+	// 随机策略直接在数据源中抽样，不遍历全部候选题目
+	if len(store.calls) != 1 || store.calls[0] != "sample" {
+		t.Fatalf("random strategy called %v, want [sample]", store.calls)
+	}
+}
+
+func TestStratifiedStrategy(t *testing.T) {
+	types := []string{"1", "1", "1", "1", "1", "1", "2", "2", "2", "3"}
+	store := newFakeQuizStore(types, nil)
+	ids, untried := selectWith(t, QuizStrategyStratified, store, &SelectParams{Num: 6})
+	if len(ids) != 6 || untried != 10 {
+		t.Fatalf("got %d ids, untried %d; want 6, 10", len(ids), untried)
+	}
+	checkUntriedDistinct(t, store, ids)
+	count := make(map[string]int)
+	for _, id := range ids {
+		count[types[id-1]]++
+	}
+	// 类型3只有1道题，其余名额由类型1、2平分
+	if count["1"] != 3 || count["2"] != 2 || count["3"] != 1 {
+		t.Fatalf("unexpected type distribution %v", count)
+	}
+
+	// 题目不足时返回全部未刷过的题目
+	ids, _ = selectWith(t, QuizStrategyStratified, store, &SelectParams{Num: 20})
+	if len(ids) != 10 {
+		t.Fatalf("got %d ids, want 10", len(ids))
+	}
+}
+
+func TestPriorityStrategy(t *testing.T) {
+	types := []string{"1", "1", "1", "1", "1", "1"}
+	store := newFakeQuizStore(types, []int{0, 5, 1, 5, 9, 0}, 5)
+	ids, untried := selectWith(t, QuizStrategyPriority, store, &SelectParams{Num: 3})
+	if untried != 5 {
+		t.Fatalf("untried %d, want 5", untried)
+	}
+	got := append([]int64(nil), ids...)
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	// 题目5已刷过，剩余优先级最高的是题目2、4(优先级5)和题目3(优先级1)
+	if len(got) != 3 || got[0] != 2 || got[1] != 3 || got[2] != 4 {
+		t.Fatalf("got %v, want [2 3 4]", got)
+	}
+	if ids[2] != 3 {
+		t.Fatalf("lower priority quiz should come last, got %v", ids)
+	}
+}
+
+func TestSequentialStrategy(t *testing.T) {
+	types := make([]string, 50)
+	for i := range types {
+		types[i] = "1"
+	}
+	store := newFakeQuizStore(types, nil)
+	first, _ := selectWith(t, QuizStrategySequential, store, &SelectParams{UserID: 1, Num: 10, Seed: 42})
+	second, _ := selectWith(t, QuizStrategySequential, store, &SelectParams{UserID: 2, Num: 10, Seed: 42})
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("same seed should give the same order: %v vs %v", first, second)
+		}
+	}
+	other, _ := selectWith(t, QuizStrategySequential, store, &SelectParams{UserID: 1, Num: 10, Seed: 7})
+	same := true
+	for i := range first {
+		if first[i] != other[i] {
+			same = false
+		}
+	}
+	if same {
+		t.Fatalf("different seeds should give different orders: %v", first)
+	}
+
+	// 刷过前几道题后，剩余题目保持原有的相对顺序
+	store.done[first[0]], store.done[first[1]] = true, true
+	next, _ := selectWith(t, QuizStrategySequential, store, &SelectParams{UserID: 1, Num: 8, Seed: 42})
+	for i := range next {
+		if next[i] != first[i+2] {
+			t.Fatalf("order changed after submitting: %v vs %v", next, first[2:])
+		}
+	}
+
+	// 顺序与候选题目的返回顺序无关
+	rand.Shuffle(len(store.quizzes), func(i, j int) {
+		store.quizzes[i], store.quizzes[j] = store.quizzes[j], store.quizzes[i]
+	})
+	again, _ := selectWith(t, QuizStrategySequential, store, &SelectParams{UserID: 3, Num: 8, Seed: 42})
+	for i := range again {
+		if again[i] != next[i] {
+			t.Fatalf("order depends on store order: %v vs %v", again, next)
+		}
+	}
+}
+
+func TestBalancedStrategy(t *testing.T) {
+	store := newFakeQuizStore([]string{"1", "1", "1", "1", "1"}, nil, 5)
+	for i, n := range []int64{3, 0, 2, 1, 0} {
+		store.quizzes[i].Judgements = n
+	}
+	ids, untried := selectWith(t, QuizStrategyBalanced, store, &SelectParams{Num: 2})
+	// 题目5作答数最少但已刷过，其余作答数最少的是题目2、4
+	if untried != 4 || len(ids) != 2 || ids[0] != 2 || ids[1] != 4 {
+		t.Fatalf("got %v (untried %d), want [2 4] (untried 4)", ids, untried)
+	}
+	if len(store.calls) != 1 || store.calls[0] != "neediest" {
+		t.Fatalf("balanced strategy called %v, want [neediest]", store.calls)
+	}
+	// 作答数较多的题目只在作答数少的题目取完后才出
+	ids, _ = selectWith(t, QuizStrategyBalanced, store, &SelectParams{Num: 4})
+	if len(ids) != 4 || ids[3] != 1 {
+		t.Fatalf("got %v, want the most judged quiz 1 last", ids)
+	}
+}
+
+// TestSeededStrategies 随机源相同时分层与优先级策略的结果确定，随机源不同时结果不同
+func TestSeededStrategies(t *testing.T) {
+	types := make([]string, 40)
+	for i := range types {
+		types[i] = []string{"1", "2", "3"}[i%3]
+	}
+	store := newFakeQuizStore(types, make([]int, len(types)))
+	for _, name := range []string{QuizStrategyStratified, QuizStrategyPriority} {
+		first, _ := selectWith(t, name, store, &SelectParams{Num: 9, Rand: rand.New(rand.NewSource(1))})
+		again, _ := selectWith(t, name, store, &SelectParams{Num: 9, Rand: rand.New(rand.NewSource(1))})
+		if !reflect.DeepEqual(first, again) {
+			t.Fatalf("%s with the same seed: %v vs %v", name, first, again)
+		}
+		other, _ := selectWith(t, name, store, &SelectParams{Num: 9, Rand: rand.New(rand.NewSource(2))})
+		if reflect.DeepEqual(first, other) {
+			t.Fatalf("%s with different seeds gave the same result %v", name, first)
+		}
+		checkUntriedDistinct(t, store, first)
+	}
+}
+
+// TestStratifiedUniform 分层抽样在每个类型内均匀：多次抽取后同类型的每道题都会被选中
+func TestStratifiedUniform(t *testing.T) {
+	types := []string{"1", "1", "1", "1", "1", "1", "2"}
+	store := newFakeQuizStore(types, nil)
+	r := rand.New(rand.NewSource(1))
+	picked := make(map[int64]int)
+	for i := 0; i < 300; i++ {
+		ids, _ := selectWith(t, QuizStrategyStratified, store, &SelectParams{Num: 2, Rand: r})
+		for _, id := range ids {
+			picked[id]++
+		}
+	}
+	// 每次类型2的唯一一道题必选，类型1的6道题各约50次
+	if picked[7] != 300 {
+		t.Fatalf("quiz 7 picked %d times, want 300", picked[7])
+	}
+	for id := int64(1); id <= 6; id++ {
+		if picked[id] < 25 || picked[id] > 75 {
+			t.Fatalf("quiz %d picked %d times, want about 50: %v", id, picked[id], picked)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package logic
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"scgptEval/config"
+	"scgptEval/dao/mysql"
+	"scgptEval/dao/redis"
+	"scgptEval/models"
+	"scgptEval/pkg/quizkind"
+	"scgptEval/pkg/trust"
+	"time"
+)
+
+// 标注员信任分：由金标准题正确率、与其他标注员多数答案的一致率以及提交间隔定期重新计算，写入mysql与redis
+// 用于为模型排行榜等汇总结果加权，可选地作为刷题排行榜的排名依据，并自动暂停信任分过低的标注员
+
+const (
+	defaultTrustPrior       = 5
+	defaultTrustMinInterval = 3 * time.Second
+	defaultTrustMinEvidence = 20
+	minTrustWeight          = 0.01 // 信任分作为权重时的下限，避免权重为0
+)
+
+// TrustReport 一次信任分计算的结果
+type TrustReport struct {
+	Users      int       `json:"users"`     // 计算了信任分的用户数
+	Suspended  []string  `json:"suspended"` // 本次被自动暂停的用户id
+	ComputedAt time.Time `json:"computed_at"`
+}
+
+// trustParams 信任分计算参数，未配置的项使用默认值
+func trustParams() (p trust.Params, minInterval time.Duration) {
+	cfg := config.Conf.TrustConfig
+	p = trust.Params{GoldWeight: 0.5, AgreementWeight: 0.3, TimingWeight: 0.2, PriorWeight: defaultTrustPrior}
+	minInterval = defaultTrustMinInterval
+	if cfg == nil {
+		return
+	}
+	if cfg.GoldWeight+cfg.AgreementWeight+cfg.TimingWeight > 0 {
+		p.GoldWeight, p.AgreementWeight, p.TimingWeight = cfg.GoldWeight, cfg.AgreementWeight, cfg.TimingWeight
+	}
+	if cfg.PriorWeight > 0 {
+		p.PriorWeight = cfg.PriorWeight
+	}
+	if cfg.MinInterval > 0 {
+		minInterval = time.Duration(cfg.MinInterval) * time.Second
+	}
+	return
+}
+
+// collectEvidence 由做题记录统计每个用户计算信任分所需的证据
+func collectEvidence(minInterval time.Duration) (evidence map[int64]*trust.Evidence, err error) {
+	evidence = make(map[int64]*trust.Evidence)
+	get := func(userID int64) *trust.Evidence {
+		e, ok := evidence[userID]
+		if !ok {
+			e = new(trust.Evidence)
+			evidence[userID] = e
+		}
+		return e
+	}
+
+	// 1.金标准题
+	answered, correct, err := mysql.CountGoldAnswers()
+	if err != nil {
+		return nil, err
+	}
+	for userID, n := range answered {
+		e := get(userID)
+		e.GoldAnswered, e.GoldCorrect = n, correct[userID]
+	}
+
+	// 2.与其他标注员多数答案的一致率，自由文本题无法比对
+	var curQuiz int64
+	var users []int64
+	var keys []string
+	flush := func() {
+		for i, res := range trust.LeaveOneOut(keys) {
+			if res == trust.NoConsensus {
+				continue
+			}
+			e := get(users[i])
+			e.ConsensusAnswered++
+			if res == trust.Agreed {
+				e.ConsensusAgreed++
+			}
+		}
+		users, keys = users[:0], keys[:0]
+	}
+	err = mysql.IterateQuizAnswers([]string{quizkind.CodeFreeText},
+		func(quizID int64, quizType string, userID int64, answer string) error {
+			if quizID != curQuiz {
+				flush()
+				curQuiz = quizID
+			}
+			kind, err := quizkind.Get(quizType)
+			if err != nil {
+				return nil
+			}
+			users = append(users, userID)
+			keys = append(keys, quizkind.AnswerKey(kind, answer))
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	flush()
+
+	// 3.提交间隔
+	var prevUser int64
+	var prevTime time.Time
+	err = mysql.IterateSubmissionTimes(func(userID int64, createdAt time.Time) error {
+		e := get(userID)
+		e.Submissions++
+		if userID == prevUser && createdAt.Sub(prevTime) < minInterval {
+			e.FastSubmissions++
+		}
+		prevUser, prevTime = userID, createdAt
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return evidence, nil
+}
+
+// RecomputeTrust 由做题记录重新计算全部用户的信任分，写入mysql与redis，并按配置自动暂停信任分过低的标注员
+func RecomputeTrust() (report *TrustReport, err error) {
+	p, minInterval := trustParams()
+	evidence, err := collectEvidence(minInterval)
+	if err != nil {
+		return nil, err
+	}
+	trusts := make([]*models.UserTrust, 0, len(evidence))
+	scores := make(map[int64]float64, len(evidence))
+	for userID, e := range evidence {
+		score := trust.Score(e, p)
+		scores[userID] = score
+		trusts = append(trusts, &models.UserTrust{
+			UserID:            userID,
+			Score:             score,
+			GoldAnswered:      e.GoldAnswered,
+			GoldCorrect:       e.GoldCorrect,
+			ConsensusAnswered: e.ConsensusAnswered,
+			ConsensusAgreed:   e.ConsensusAgreed,
+			Submissions:       e.Submissions,
+			FastSubmissions:   e.FastSubmissions,
+		})
+	}
+	if err = mysql.SaveUserTrusts(trusts); err != nil {
+		return nil, err
+	}
+	// mysql为准，redis写入失败只打log，下次计算时修复
+	campaignIDs, err := mysql.GetCampaignIDs()
+	if err != nil {
+		return nil, err
+	}
+	if err = redis.ResetUserTrusts(scores, campaignIDs, retainedWindows(time.Now())); err != nil {
+		zap.L().Error("redis.ResetUserTrusts() failed", zap.Error(err))
+	}
+
+	report = &TrustReport{Users: len(trusts), Suspended: make([]string, 0), ComputedAt: time.Now()}
+	cfg := config.Conf.TrustConfig
+	if cfg == nil || cfg.SuspendThreshold <= 0 {
+		return report, nil
+	}
+	minEvidence := cfg.MinEvidence
+	if minEvidence <= 0 {
+		minEvidence = defaultTrustMinEvidence
+	}
+	var lows []int64
+	for userID, e := range evidence {
+		if scores[userID] < cfg.SuspendThreshold && e.Amount() >= minEvidence {
+			lows = append(lows, userID)
+		}
+	}
+	suspended, err := mysql.SuspendAnnotators(lows)
+	if err != nil {
+		return nil, err
+	}
+	for _, userID := range suspended {
+		zap.L().Warn("annotator suspended for low trust score",
+			zap.Int64("user_id", userID), zap.Float64("score", scores[userID]))
+		if err = redis.RevokeUserSessions(userID); err != nil {
+			zap.L().Error("redis.RevokeUserSessions() failed", zap.Int64("user_id", userID), zap.Error(err))
+		}
+		report.Suspended = append(report.Suspended, fmt.Sprint(userID))
+	}
+	return report, nil
+}
+
+// trustWeights 启用信任分时返回各用户的信任分，未启用时返回nil(即不加权)
+func trustWeights() (weights map[int64]float64, err error) {
+	if !config.Conf.TrustConfig.TrustEnabled() {
+		return nil, nil
+	}
+	return mysql.GetTrustScores()
+}
+
+// trustWeight 用户标注结果的权重，未计算过信任分的用户按1计
+func trustWeight(weights map[int64]float64, userID int64) float64 {
+	w, ok := weights[userID]
+	if !ok {
+		return 1
+	}
+	if w < minTrustWeight {
+		return minTrustWeight
+	}
+	return w
+}
+
+// ListUserTrusts 分页获取用户信任分，信任分低的用户在前
+func ListUserTrusts(page, size int) (data map[string]interface{}, err error) {
+	list, total, err := mysql.ListUserTrusts(page, size)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"total": total,
+		"users": list,
+	}, nil
+}
+
+// SetUserStatus 暂停或恢复用户，暂停时吊销其全部会话
+// 恢复的用户若信任分仍低于阈值，下次计算信任分时会再次被自动暂停
+func SetUserStatus(userID int64, status string) (err error) {
+	changed, err := mysql.UpdateUserStatus(userID, status)
+	if err != nil || !changed || status != models.UserStatusSuspended {
+		return err
+	}
+	return redis.RevokeUserSessions(userID)
+}
@@ -0,0 +1,122 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"scgptEval/config"
+	"scgptEval/dao/mysql"
+	"scgptEval/dao/redis"
+	"scgptEval/models"
+	"scgptEval/pkg/jwt"
+	"scgptEval/pkg/snowflake"
+	"strconv"
+	"time"
+)
+
+// LogIn 用户登录：校验密码后开启一个新的会话(refresh token family)并签发token
+// 单一用户登录模式下，新会话会挤掉该用户之前的所有会话
+func LogIn(user *models.User, ip, userAgent string) (aToken, rToken string, err error) {
+	if err = mysql.LogIn(user); err != nil {
+		return "", "", err
+	}
+	session := &models.Session{
+		SessionID: genTokenID(),
+		UserID:    user.ID,
+		IP:        ip,
+		UserAgent: userAgent,
+		CreatedAt: time.Now().Unix(),
+	}
+	jti := genTokenID()
+	if aToken, rToken, err = jwt.GenToken(user.ID, user.Username, user.Role, session.SessionID, jti); err != nil {
+		// 过了登录验证但token生成失败，打log
+		zap.L().Error("jwt.GenToken() failed",
+			zap.Int64("userID", user.ID),
+			zap.String("username", user.Username),
+			zap.Error(err))
+		return "", "", err
+	}
+	if err = redis.CreateSession(session, jti, jwt.RefreshTokenExpireDuration,
+		config.Conf.AuthConfig.SessionLimit()); err != nil {
+		zap.L().Error("redis.CreateSession() failed", zap.Int64("userID", user.ID), zap.Error(err))
+		return "", "", err
+	}
+	return aToken, rToken, nil
+}
+
+// RefreshToken 轮换refresh token：旧的refresh token立即失效
+// 若已失效的refresh token被再次使用，说明token可能已泄露，整个family会被吊销
+func RefreshToken(rToken string) (newAToken, newRToken string, err error) {
+	claims, err := jwt.ParseRefreshToken(rToken)
+	if err != nil {
+		return "", "", err
+	}
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return "", "", jwt.ErrorInvalidToken
+	}
+	newJTI := genTokenID()
+	if err = redis.RotateRefreshToken(claims.FamilyID, userID, claims.Id, newJTI,
+		jwt.RefreshTokenExpireDuration); err != nil {
+		if errors.Is(err, redis.ErrorTokenReused) {
+			zap.L().Warn("refresh token reuse detected, token family revoked",
+				zap.Int64("userID", userID),
+				zap.String("familyID", claims.FamilyID))
+		}
+		return "", "", err
+	}
+	// 用户名与角色可能已修改，以数据库为准
+	user, err := mysql.GetUserByID(userID)
+	if err != nil {
+		return "", "", err
+	}
+	if user.Status == models.UserStatusSuspended {
+		return "", "", mysql.ErrorUserSuspended
+	}
+	return jwt.GenToken(user.ID, user.Username, user.Role, claims.FamilyID, newJTI)
+}
+
+// LogOut 登出：吊销当前会话
+func LogOut(userID int64, sessionID string) (err error) {
+	return redis.RevokeSession(userID, sessionID)
+}
+
+// GetSessions 获取用户的全部有效会话，并标记发起请求的会话
+func GetSessions(userID int64, currentID string) (sessions []*models.Session, err error) {
+	if sessions, err = redis.GetUserSessions(userID); err != nil {
+		return nil, err
+	}
+	for _, s := range sessions {
+		s.Current = s.SessionID == currentID
+	}
+	return sessions, nil
+}
+
+// RevokeSession 吊销用户的指定会话(例如踢掉其它设备)
+func RevokeSession(userID int64, sessionID string) (err error) {
+	return redis.RevokeSession(userID, sessionID)
+}
+
+// SetUserRole 设置用户角色，角色变化时吊销其全部会话
+// token中携带的角色在过期前不会更新，吊销后用户需重新登录，以新的角色签发token
+func SetUserRole(userID int64, role string) (err error) {
+	changed, err := mysql.UpdateUserRole(userID, role)
+	if err != nil || !changed {
+		return err
+	}
+	return redis.RevokeUserSessions(userID)
+}
+
+// SetUserRoleByName 按用户名设置用户角色，用于在命令行中将已注册的用户设为首个管理员
+func SetUserRoleByName(username, role string) (userID int64, err error) {
+	user, err := mysql.GetUserByName(username)
+	if err != nil {
+		return 0, err
+	}
+	return user.ID, SetUserRole(user.ID, role)
+}
+
+// genTokenID 生成token family id 与 jti
+func genTokenID() string {
+	return fmt.Sprint(snowflake.GenID())
+}
@@ -7,11 +7,15 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"scgptEval/cmd"
 	"scgptEval/config"
 	"scgptEval/controllers"
 	"scgptEval/dao/mysql"
 	"scgptEval/dao/redis"
 	"scgptEval/logger"
+	"scgptEval/logic"
+	"scgptEval/pkg/jobs"
+	"scgptEval/pkg/password"
 	"scgptEval/pkg/snowflake"
 	"scgptEval/routes"
 	"syscall"
@@ -47,16 +51,70 @@ func main() {
 		return
 	}
 	defer redis.Close() // 延迟关闭redis连接
+	// 以mysql为准重建redis中的题库集合
+	if err := logic.SyncQuizBase(); err != nil {
+		fmt.Printf("sync quiz base failed, err:%v\n", err)
+		return
+	}
 	// 雪花算法生成用户id和帖子id
 	if err := snowflake.Init(config.Conf.StartTime, config.Conf.MachineID); err != nil {
 		fmt.Printf("init snowflake failed, err:%v\n", err)
 		return
 	}
+	// 密码哈希算法
+	if err := password.Init(config.Conf.PasswordConfig); err != nil {
+		fmt.Printf("init password hasher failed, err:%v\n", err)
+		return
+	}
 	// 初始化gin框架内置的参数校验器使用的翻译器
 	if err := controllers.InitTrans("zh"); err != nil {
 		fmt.Printf("init validator trans failed, err:%v\n", err)
 		return
 	}
+	// 子命令模式(例如 ./scgpt_eval import -file xx.jsonl)：执行完毕即退出，不启动web服务
+	if len(os.Args) > 1 && cmd.IsCommand(os.Args[1]) {
+		if err := cmd.Run(os.Args[1], os.Args[2:]); err != nil {
+			fmt.Printf("run command %s failed, err:%v\n", os.Args[1], err)
+		}
+		return
+	}
+	// 多实例部署时每个定时任务在一个间隔内只在一个实例上执行
+	jobs.Lock = redis.JobLocker{}
+	// 定期重新计算标注员信任分
+	if interval := config.Conf.TrustConfig.TrustInterval(); interval > 0 {
+		stop := jobs.Every("recompute_trust", interval, func() error {
+			_, err := logic.RecomputeTrust()
+			return err
+		})
+		defer stop()
+	}
+	// 定期聚合题目的共识标签
+	if interval := config.Conf.ConsensusConfig.ConsensusInterval(); interval > 0 {
+		stop := jobs.Every("recompute_consensus", interval, func() error {
+			_, err := logic.RecomputeConsensus()
+			return err
+		})
+		defer stop()
+	}
+	// 定期重新计算模型排行榜，接口只读取缓存；启动时尚无缓存则先计算一次
+	go func() {
+		if err := logic.WarmModelLeaderboard(); err != nil {
+			zap.L().Error("logic.WarmModelLeaderboard() failed", zap.Error(err))
+		}
+	}()
+	stopRating := jobs.Every("recompute_model_leaderboard", config.Conf.RatingConfig.RatingInterval(), func() error {
+		_, err := logic.ComputeModelLeaderboard()
+		return err
+	})
+	defer stopRating()
+	// 定期存档已结束的统计窗口的刷题排行榜
+	if interval := config.Conf.RankingConfig.ArchiveInterval(); interval > 0 {
+		stop := jobs.Every("archive_rankings", interval, func() error {
+			_, err := logic.ArchiveRankings()
+			return err
+		})
+		defer stop()
+	}
 	// 5. 注册路由
 	r := routes.SetUp()
 	// 6. 启动服务（优雅关机、平滑重启）
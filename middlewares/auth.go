@@ -1,11 +1,14 @@
 package middlewares
 
 import (
+	"errors"
 	"scgptEval/controllers"
+	"scgptEval/dao/redis"
 	"scgptEval/pkg/jwt"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 // JWTAuthMiddleware 基于JWT的认证中间件
@@ -38,8 +41,41 @@ func JWTAuthMiddleware() func(c *gin.Context) {
 			c.Abort()
 			return
 		}
+		// 会话已登出、被吊销或被新登录挤掉时，token即使未过期也不再有效
+		if err = redis.CheckSession(mc.UserID, mc.FamilyID); err != nil {
+			if errors.Is(err, redis.ErrorTokenRevoked) {
+				controllers.ResponseError(c, controllers.CodeTokenRevoked)
+			} else {
+				zap.L().Error("redis.CheckSession() failed", zap.Int64("userID", mc.UserID), zap.Error(err))
+				controllers.ResponseError(c, controllers.CodeServerBusy)
+			}
+			c.Abort()
+			return
+		}
 		// 将当前请求的username信息保存到请求的上下文c上
 		c.Set(controllers.ContextUserIDKey, mc.UserID)
+		c.Set(controllers.ContextUserRoleKey, mc.Role)
+		c.Set(controllers.ContextFamilyIDKey, mc.FamilyID)
 		c.Next() // 在后续的处理请求的函数中 可以通过c.Get(ContextUserIDKey)来获取当前请求的用户信息（见request.go中）
 	}
 }
+
+// RequireRole 基于角色的访问控制中间件，需放在JWTAuthMiddleware之后使用
+func RequireRole(roles ...string) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		role, ok := c.Get(controllers.ContextUserRoleKey)
+		if !ok {
+			controllers.ResponseError(c, controllers.CodeNeedLogin)
+			c.Abort()
+			return
+		}
+		for _, r := range roles {
+			if role == r {
+				c.Next()
+				return
+			}
+		}
+		controllers.ResponseError(c, controllers.CodeNoPermission)
+		c.Abort()
+	}
+}
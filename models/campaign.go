@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Campaign 评测项目：拥有一部分题目、起止时间与参与的用户，出题、提交与刷题排行按评测项目隔离
+type Campaign struct {
+	ID          int64      `gorm:"primaryKey;autoIncrement" json:"campaign_id"`
+	Name        string     `gorm:"type:varchar(128);unique_index;not null" json:"name"`
+	Description string     `gorm:"type:text" json:"description"`
+	Strategy    string     `gorm:"type:varchar(16)" json:"strategy"` // 出题策略，为空时使用配置的默认策略
+	StartAt     time.Time  `gorm:"column:start_at" json:"start_at"`
+	EndAt       *time.Time `gorm:"column:end_at" json:"end_at"` // NULL表示不设结束时间
+	CreatedAt   time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// Open 评测项目在t时是否进行中
+func (c *Campaign) Open(t time.Time) bool {
+	return !t.Before(c.StartAt) && (c.EndAt == nil || c.EndAt.IsZero() || t.Before(*c.EndAt))
+}
+
+// CampaignUser 参与评测项目的用户
+type CampaignUser struct {
+	CampaignID int64     `gorm:"primary_key;auto_increment:false"`
+	UserID     int64     `gorm:"primary_key;auto_increment:false;index"`
+	CreatedAt  time.Time `gorm:"column:created_at"`
+}
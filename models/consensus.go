@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// 共识标签的聚合方法
+const (
+	ConsensusMajority   = "majority"    // 多数投票
+	ConsensusWeighted   = "weighted"    // 按标注员信任分加权投票
+	ConsensusDawidSkene = "dawid_skene" // Dawid–Skene EM
+)
+
+// ConsensusLabel 由多名用户的答案聚合得到的题目共识标签，每道题每种方法一条，由聚合任务定期重新计算
+type ConsensusLabel struct {
+	QuizID     int64     `gorm:"primary_key;auto_increment:false" json:"quiz_id,string"`
+	Method     string    `gorm:"primary_key;type:varchar(16)" json:"method"`
+	Label      string    `gorm:"type:text" json:"label"` // 规范化后的答案，模型对比题为胜出的模型id或tie
+	Confidence float64   `json:"confidence"`
+	Labels     int       `json:"labels"` // 参与聚合的答案数
+	UpdatedAt  time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
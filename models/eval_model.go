@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// EvalModel 被评测的模型，模型对比题中的model_id需先在此登记
+type EvalModel struct {
+	ID          int64     `gorm:"primaryKey;autoIncrement" json:"-"`
+	ModelID     string    `gorm:"type:varchar(64);unique_index;not null" json:"model_id"`
+	Name        string    `gorm:"type:varchar(128)" json:"name"`
+	Provider    string    `gorm:"type:varchar(64)" json:"provider"`
+	Description string    `gorm:"type:text" json:"description"`
+	CreatedAt   time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
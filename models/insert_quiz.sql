@@ -1,15 +1,15 @@
-insert into `quizzes` values (1, '1', '测试题1',
-                              '{"option1":"1", "option2":"2", "option3":"3"}',
-                              '2023-07-21 19:30:00');
-insert into `quizzes` values (2, '1', '测试题2',
-                              '{"option1":"1", "option2":"2", "option3":"3"}',
-                              '2023-07-21 19:30:00');
-insert into `quizzes` values (3, '2', '测试题3',
-                              '{"option1":"1", "option2":"2", "option3":"3"}',
-                              '2023-07-21 19:30:00');
-insert into `quizzes` values (4, '3', '测试题4',
-                              '{"option1":"1", "option2":"2", "option3":"3"}',
-                              '2023-07-21 19:30:00');
-insert into `quizzes` values (5, '2', '测试题5',
-                              '{"option1":"1", "option2":"2", "option3":"3"}',
-                              '2023-07-21 19:30:00');
\ No newline at end of file
+insert into `quizzes` (`id`, `type`, `content`, `options`, `created_at`) values (1, '1', '测试题1',
+                                                                                 '{"option1":"1", "option2":"2", "option3":"3"}',
+                                                                                 '2023-07-21 19:30:00');
+insert into `quizzes` (`id`, `type`, `content`, `options`, `created_at`) values (2, '1', '测试题2',
+                                                                                 '{"option1":"1", "option2":"2", "option3":"3"}',
+                                                                                 '2023-07-21 19:30:00');
+insert into `quizzes` (`id`, `type`, `content`, `options`, `created_at`) values (3, '2', '测试题3',
+                                                                                 '{"option1":"1", "option2":"2", "option3":"3"}',
+                                                                                 '2023-07-21 19:30:00');
+insert into `quizzes` (`id`, `type`, `content`, `options`, `created_at`) values (4, '3', '测试题4',
+                                                                                 '{"option1":"1", "option2":"2", "option3":"3"}',
+                                                                                 '2023-07-21 19:30:00');
+insert into `quizzes` (`id`, `type`, `content`, `options`, `created_at`) values (5, '2', '测试题5',
+                                                                                 '{"option1":"1", "option2":"2", "option3":"3"}',
+                                                                                 '2023-07-21 19:30:00');
\ No newline at end of file
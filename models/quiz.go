@@ -2,12 +2,49 @@ package models
 
 import "time"
 
+// 题目状态
+const (
+	QuizStatusActive  = "active"  // 正常出题
+	QuizStatusRetired = "retired" // 已下线，不再出题，历史记录保留
+)
+
 // Quiz 题库表结构体
 type Quiz struct {
-	ID   int64  `gorm:"primaryKey;autoIncrement" json:"quiz_id"`
-	Type string `gorm:"type:ENUM('1','2','3')" json:"quiz_type"`
+	ID     int64  `gorm:"primaryKey;autoIncrement" json:"quiz_id"`
+	Type   string `gorm:"column:type" json:"quiz_type"` // 题目类型编号，见pkg/quizkind，列类型由mysql.syncTable按已注册的题型设置为ENUM
+	Status string `gorm:"type:ENUM('active','retired');default:'active';index" json:"status"`
+
+	// 该题需要的作答数，达到后不再出题；0表示使用配置quiz.target_judgements的默认值
+	TargetJudgements int `gorm:"column:target_judgements;not null;default:0" json:"target_judgements"`
+	// 出题优先级，按优先级出题时数值越大越先出
+	Priority int `gorm:"not null;default:0;index" json:"priority"`
+
+	// 所属评测项目，0表示全局题库；只在所属评测项目中出题
+	CampaignID int64 `gorm:"column:campaign_id;not null;default:0;index" json:"campaign_id"`
+
+	// 金标准题：有标准答案，按配置的比例混入出题中，用于考察标注员的作答质量
+	IsGold     bool   `gorm:"column:is_gold;not null;default:false;index" json:"is_gold"`
+	GoldAnswer string `gorm:"column:gold_answer;type:text" json:"gold_answer,omitempty"` // 标准答案的规范形式，不下发给标注员
+
+	Content     string    `json:"content"`
+	Options     string    `gorm:"type:text" json:"options"`            // Golang map <--> mysql text
+	ContentHash string    `gorm:"type:char(64);unique_index" json:"-"` // 题目类型+题干+选项的sha256，唯一，用于导入去重；历史题目补齐前为NULL
+	CreatedAt   time.Time `gorm:"column:created_at"`
+	UpdatedAt   time.Time `gorm:"column:updated_at"`
+}
+
+// Target 该题实际的目标作答数，未单独设置时使用默认值，0表示不限
+func (q *Quiz) Target(defaultTarget int) int {
+	if q.TargetJudgements > 0 {
+		return q.TargetJudgements
+	}
+	return defaultTarget
+}
 
-	Content   string    `json:"content"`
-	Options   string    `gorm:"type:text" json:"options"` // Golang map <--> mysql text
-	CreatedAt time.Time `gorm:"column:created_at"`
+// QuizCandidate 出题策略挑选题目时所需的题目信息
+type QuizCandidate struct {
+	ID         int64
+	Type       string
+	Priority   int
+	Judgements int64 // 已收到的作答数
 }
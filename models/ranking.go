@@ -0,0 +1,70 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// 刷题排行榜的统计周期
+const (
+	PeriodAll     = "all"     // 累计刷题量
+	PeriodDaily   = "daily"   // 自然日
+	PeriodWeekly  = "weekly"  // 自然周，周一开始
+	PeriodMonthly = "monthly" // 自然月
+)
+
+// RankingPeriods 按时间窗口统计的排行榜周期
+var RankingPeriods = []string{PeriodDaily, PeriodWeekly, PeriodMonthly}
+
+// PeriodWindow 排行榜的一个统计窗口，包含StartAt，不包含EndAt
+type PeriodWindow struct {
+	Period  string    `json:"period"`
+	Key     string    `json:"period_key"` // 窗口的编号，日榜如20231002，周榜如2023W40(ISO周)，月榜如202310
+	StartAt time.Time `json:"start_at"`
+	EndAt   time.Time `json:"end_at"`
+}
+
+// WindowAt 获取t所在的统计窗口，窗口边界按t所在的时区计算；period不是按时间窗口统计的周期时返回nil
+func WindowAt(period string, t time.Time) *PeriodWindow {
+	y, m, d := t.Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	w := &PeriodWindow{Period: period}
+	switch period {
+	case PeriodDaily:
+		w.StartAt, w.EndAt = day, day.AddDate(0, 0, 1)
+		w.Key = day.Format("20060102")
+	case PeriodWeekly:
+		// time.Weekday以周日为0，换算为距周一的天数
+		w.StartAt = day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		w.EndAt = w.StartAt.AddDate(0, 0, 7)
+		year, week := w.StartAt.ISOWeek()
+		w.Key = fmt.Sprintf("%dW%02d", year, week)
+	case PeriodMonthly:
+		w.StartAt = time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+		w.EndAt = w.StartAt.AddDate(0, 1, 0)
+		w.Key = w.StartAt.Format("200601")
+	default:
+		return nil
+	}
+	return w
+}
+
+// Prev 上一个统计窗口
+func (w *PeriodWindow) Prev() *PeriodWindow {
+	return WindowAt(w.Period, w.StartAt.Add(-time.Nanosecond))
+}
+
+// RankingSnapshot 已结束的统计窗口的排行榜存档，每行为窗口内有刷题量的一名用户
+type RankingSnapshot struct {
+	Period        string    `gorm:"type:varchar(16);primary_key;auto_increment:false" json:"period"`
+	PeriodKey     string    `gorm:"column:period_key;type:varchar(16);primary_key;auto_increment:false" json:"period_key"`
+	CampaignID    int64     `gorm:"column:campaign_id;primary_key;auto_increment:false" json:"campaign_id"`
+	UserID        int64     `gorm:"column:user_id;primary_key;auto_increment:false;index" json:"user_id,string"`
+	Rank          int       `gorm:"not null" json:"rank"` // 从1开始的名次
+	Username      string    `gorm:"type:varchar(64)" json:"username"`
+	Score         int64     `gorm:"not null" json:"score"`                       // 窗口内的刷题量
+	WeightedScore float64   `gorm:"column:weighted_score" json:"weighted_score"` // 窗口内按信任分加权的刷题量
+	StartAt       time.Time `gorm:"column:start_at" json:"start_at"`
+	EndAt         time.Time `gorm:"column:end_at" json:"end_at"`
+	CreatedAt     time.Time `gorm:"column:created_at" json:"created_at"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowAt(t *testing.T) {
+	cst := time.FixedZone("CST", 8*3600)
+	date := func(loc *time.Location, y int, m time.Month, d, h int) time.Time {
+		return time.Date(y, m, d, h, 0, 0, 0, loc)
+	}
+	tests := []struct {
+		name       string
+		period     string
+		t          time.Time
+		key        string
+		start, end time.Time
+		prev       string
+	}{
+		{"daily", PeriodDaily, date(time.UTC, 2025, 1, 1, 12), "20250101",
+			date(time.UTC, 2025, 1, 1, 0), date(time.UTC, 2025, 1, 2, 0), "20241231"},
+		// 2024-12-30是周一，属于2025年第1周
+		{"iso week across year", PeriodWeekly, date(time.UTC, 2024, 12, 30, 0), "2025W01",
+			date(time.UTC, 2024, 12, 30, 0), date(time.UTC, 2025, 1, 6, 0), "2024W52"},
+		// 周日属于前一个周一开始的周
+		{"sunday", PeriodWeekly, date(time.UTC, 2025, 1, 5, 23), "2025W01",
+			date(time.UTC, 2024, 12, 30, 0), date(time.UTC, 2025, 1, 6, 0), "2024W52"},
+		{"monday", PeriodWeekly, date(time.UTC, 2025, 1, 6, 0), "2025W02",
+			date(time.UTC, 2025, 1, 6, 0), date(time.UTC, 2025, 1, 13, 0), "2025W01"},
+		// 2020年有53周
+		{"week 53", PeriodWeekly, date(time.UTC, 2021, 1, 3, 8), "2020W53",
+			date(time.UTC, 2020, 12, 28, 0), date(time.UTC, 2021, 1, 4, 0), "2020W52"},
+		{"month rollover", PeriodMonthly, date(time.UTC, 2024, 12, 31, 23), "202412",
+			date(time.UTC, 2024, 12, 1, 0), date(time.UTC, 2025, 1, 1, 0), "202411"},
+		{"leap february", PeriodMonthly, date(time.UTC, 2024, 3, 1, 0), "202403",
+			date(time.UTC, 2024, 3, 1, 0), date(time.UTC, 2024, 4, 1, 0), "202402"},
+		// UTC的3月31日17点在东八区已是4月1日，窗口边界按东八区的零点计算
+		{"timezone daily", PeriodDaily, date(time.UTC, 2024, 3, 31, 17).In(cst), "20240401",
+			date(cst, 2024, 4, 1, 0), date(cst, 2024, 4, 2, 0), "20240331"},
+		{"timezone monthly", PeriodMonthly, date(time.UTC, 2024, 3, 31, 17).In(cst), "202404",
+			date(cst, 2024, 4, 1, 0), date(cst, 2024, 5, 1, 0), "202403"},
+		{"timezone weekly", PeriodWeekly, date(time.UTC, 2024, 12, 29, 16).In(cst), "2025W01",
+			date(cst, 2024, 12, 30, 0), date(cst, 2025, 1, 6, 0), "2024W52"},
+	}
+	for _, tt := range tests {
+		w := WindowAt(tt.period, tt.t)
+		if w == nil {
+			t.Errorf("%s: WindowAt() = nil", tt.name)
+			continue
+		}
+		if w.Period != tt.period || w.Key != tt.key || !w.StartAt.Equal(tt.start) || !w.EndAt.Equal(tt.end) ||
+			w.StartAt.Location() != tt.t.Location() {
+			t.Errorf("%s: WindowAt() = %s [%v, %v), want %s [%v, %v)", tt.name, w.Key, w.StartAt, w.EndAt, tt.key, tt.start, tt.end)
+		}
+		prev := w.Prev()
+		if prev.Key != tt.prev || !prev.EndAt.Equal(w.StartAt) {
+			t.Errorf("%s: Prev() = %s ending %v, want %s ending %v", tt.name, prev.Key, prev.EndAt, tt.prev, w.StartAt)
+		}
+	}
+	for _, period := range []string{PeriodAll, "yearly"} {
+		if w := WindowAt(period, time.Now()); w != nil {
+			t.Errorf("WindowAt(%q) = %v, want nil", period, w)
+		}
+	}
+}
@@ -5,7 +5,50 @@ import "time"
 type Record struct {
 	ID           int64     `gorm:"primaryKey;autoIncrement" json:"record_id"`
 	QuizID       int64     `gorm:"column:quiz_id" json:"quiz_id"`
-	UserID       int64     `gorm:"column:user_id" json:"user_id"`
-	SelectOption string    `gorm:"column:selected_option"`
-	CreatedAt    time.Time `gorm:"column:created_at"`
+	UserID       int64     `gorm:"column:user_id;index:idx_records_user_created" json:"user_id"` // 与created_at组成索引，用于按时间分页查询用户的做题记录
+	SelectOption string    `gorm:"column:selected_option;type:text"`                             // 规范化后的答案(JSON)，自由文本答案最长2000字
+	GoldCorrect  *bool     `gorm:"column:gold_correct" json:"gold_correct,omitempty"`            // 金标准题是否答对，非金标准题为NULL
+	Revisions    int       `gorm:"column:revisions;not null;default:0" json:"revisions"`         // 修改答案的次数，被替换的答案见RecordRevision
+	CreatedAt    time.Time `gorm:"column:created_at;index:idx_records_user_created"`
+}
+
+// RecordRevision 做题记录被修改前的答案，做题记录中始终为最新的答案
+type RecordRevision struct {
+	ID           int64     `gorm:"primaryKey;autoIncrement" json:"revision_id"`
+	RecordID     int64     `gorm:"column:record_id;not null;index" json:"record_id"`
+	SelectOption string    `gorm:"column:selected_option;type:text" json:"selected_option"` // 与做题记录的答案字段一致
+	GoldCorrect  *bool     `gorm:"column:gold_correct" json:"-"`
+	AnsweredAt   time.Time `gorm:"column:answered_at" json:"answered_at"` // 该答案的提交时间
+	CreatedAt    time.Time `gorm:"column:created_at" json:"revised_at"`   // 该答案被替换的时间
+}
+
+// GoldAccuracy 用户在金标准题上的作答正确率
+type GoldAccuracy struct {
+	UserID   int64   `json:"user_id,string"`
+	Username string  `json:"username"`
+	Answered int64   `json:"answered"`
+	Correct  int64   `json:"correct"`
+	Accuracy float64 `json:"accuracy"`
+}
+
+// RecordFilter 做题记录的筛选条件，零值字段表示不筛选
+type RecordFilter struct {
+	Start      time.Time // 包含
+	End        time.Time // 不包含
+	QuizType   string
+	QuizID     int64
+	CampaignID int64 // 题目所属的评测项目
+	UserID     int64
+}
+
+// RecordDetail 做题记录及其对应的题目内容
+type RecordDetail struct {
+	RecordID     int64
+	QuizID       int64
+	QuizType     string
+	Content      string
+	Options      string
+	UserID       int64
+	SelectOption string
+	CreatedAt    time.Time
 }
@@ -0,0 +1,11 @@
+package models
+
+// Session 登录会话，一次登录对应一个refresh token family
+type Session struct {
+	SessionID string `json:"session_id"`
+	UserID    int64  `json:"user_id,string"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	CreatedAt int64  `json:"created_at"`
+	Current   bool   `json:"current"` // 是否为发起请求的会话
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// UserTrust 标注员信任分及其依据，由做题记录定期重新计算
+type UserTrust struct {
+	UserID            int64     `gorm:"primary_key;auto_increment:false" json:"user_id,string"`
+	Score             float64   `gorm:"not null;default:1;index" json:"score"`
+	GoldAnswered      int64     `json:"gold_answered"`
+	GoldCorrect       int64     `json:"gold_correct"`
+	ConsensusAnswered int64     `json:"consensus_answered"` // 可与其他标注员多数答案比对的作答数
+	ConsensusAgreed   int64     `json:"consensus_agreed"`
+	Submissions       int64     `json:"submissions"`
+	FastSubmissions   int64     `json:"fast_submissions"` // 与上一次提交间隔过短的提交数
+	UpdatedAt         time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// UserTrustDetail 信任分列表中的一项
+type UserTrustDetail struct {
+	UserTrust
+	Username string `json:"username"`
+	Status   string `json:"status"`
+}
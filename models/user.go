@@ -2,11 +2,26 @@ package models
 
 import "time"
 
+// 用户角色
+const (
+	RoleAnnotator = "annotator" // 标注员，默认角色
+	RoleReviewer  = "reviewer"  // 审核员
+	RoleAdmin     = "admin"     // 管理员
+)
+
+// 用户状态
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended" // 已暂停：不能登录，已有会话被吊销
+)
+
 // User 数据库user表结构体
 type User struct {
 	ID       int64  `gorm:"primaryKey;autoIncrement" json:"user_id,string"`
 	Username string `gorm:"unique" json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	Role     string `gorm:"type:ENUM('annotator','reviewer','admin');default:'annotator'" json:"role"`
+	Status   string `gorm:"type:ENUM('active','suspended');default:'active'" json:"status"`
 
 	Amount      int64     `json:"amount"`
 	FinalQATime time.Time `gorm:"column:final_qa_time" json:"final_qa_time"`
@@ -0,0 +1,348 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// analytics 标注一致性指标：百分比一致率、Fleiss' kappa、Cohen's kappa(标注员两两之间的平均值)
+// 与Krippendorff's alpha；alpha按数据的测量尺度选择距离，可处理多选与排序答案
+
+// Level 标注值的测量尺度，决定Krippendorff's alpha使用的距离
+type Level int
+
+const (
+	Nominal  Level = iota // 类别：值不同即距离为1
+	Interval              // 数值：距离为差的平方
+	Set                   // 集合(多选)：距离为1减去Jaccard相似度
+	Ranking               // 排序：距离为不一致的选项对所占比例(归一化的Kendall tau距离)
+)
+
+// Label 一个标注员对一个条目的标注
+type Label struct {
+	Unit   string   // 条目，如题目id
+	Coder  int64    // 标注员
+	Values []string // 标注值：类别与数值为单个元素，集合与排序为全部元素
+}
+
+// key 标注值的类别，kappa与百分比一致率按类别是否完全相同计算
+func (l *Label) key() string {
+	return strings.Join(l.Values, "\x1f")
+}
+
+// Agreement 一组标注的一致性指标，样本不足或无法定义的指标为nil
+type Agreement struct {
+	Units             int      `json:"units"`  // 至少两人标注的条目数
+	Labels            int      `json:"labels"` // 这些条目上的标注数
+	Coders            int      `json:"coders"`
+	PercentAgreement  *float64 `json:"percent_agreement"` // 同一条目上两两标注完全相同的比例
+	FleissKappa       *float64 `json:"fleiss_kappa"`
+	CohenKappa        *float64 `json:"cohen_kappa"` // 共同标注条目数足够的标注员两两之间Cohen's kappa的平均值
+	CoderPairs        int      `json:"coder_pairs"` // 参与计算Cohen's kappa的标注员对数
+	KrippendorffAlpha *float64 `json:"krippendorff_alpha"`
+}
+
+// UnitStat 单个条目上的标注分布
+type UnitStat struct {
+	Unit             string         `json:"unit"`
+	Labels           int            `json:"labels"`
+	PercentAgreement *float64       `json:"percent_agreement"` // 两两标注完全相同的比例，少于两人标注时为nil
+	TopShare         float64        `json:"top_share"`         // 最多人选择的答案所占比例
+	Distribution     map[string]int `json:"distribution"`      // 答案 -> 人数
+}
+
+// groupUnits 按条目分组，同一标注员对同一条目只保留第一次标注，条目按首次出现的顺序排列
+func groupUnits(labels []Label) (units [][]Label) {
+	index := make(map[string]int)
+	seen := make(map[string]map[int64]bool)
+	for _, l := range labels {
+		i, ok := index[l.Unit]
+		if !ok {
+			i = len(units)
+			index[l.Unit] = i
+			units = append(units, nil)
+			seen[l.Unit] = make(map[int64]bool)
+		}
+		if seen[l.Unit][l.Coder] {
+			continue
+		}
+		seen[l.Unit][l.Coder] = true
+		units[i] = append(units[i], l)
+	}
+	return units
+}
+
+// Compute 计算一组标注的一致性指标，minPairItems为计算Cohen's kappa时一对标注员最少的共同标注条目数
+func Compute(labels []Label, level Level, minPairItems int) *Agreement {
+	res := new(Agreement)
+	var pairable [][]Label
+	coders := make(map[int64]bool)
+	for _, u := range groupUnits(labels) {
+		if len(u) < 2 {
+			continue
+		}
+		pairable = append(pairable, u)
+		res.Labels += len(u)
+		for _, l := range u {
+			coders[l.Coder] = true
+		}
+	}
+	res.Units, res.Coders = len(pairable), len(coders)
+	if res.Units == 0 {
+		return res
+	}
+	res.PercentAgreement, res.FleissKappa = fleiss(pairable)
+	res.CohenKappa, res.CoderPairs = meanCohen(pairable, minPairItems)
+	res.KrippendorffAlpha = krippendorff(pairable, distanceFunc(level))
+	return res
+}
+
+// Units 逐条目统计标注分布，条目按首次出现的顺序排列
+func Units(labels []Label) []*UnitStat {
+	units := groupUnits(labels)
+	stats := make([]*UnitStat, 0, len(units))
+	for _, u := range units {
+		s := &UnitStat{Unit: u[0].Unit, Labels: len(u), Distribution: make(map[string]int)}
+		top := 0
+		for _, l := range u {
+			k := strings.Join(l.Values, ",")
+			s.Distribution[k]++
+			if s.Distribution[k] > top {
+				top = s.Distribution[k]
+			}
+		}
+		s.TopShare = float64(top) / float64(len(u))
+		if len(u) >= 2 {
+			agree := 0
+			for _, c := range s.Distribution {
+				agree += c * (c - 1)
+			}
+			s.PercentAgreement = float(float64(agree) / float64(len(u)*(len(u)-1)))
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// fleiss 每个条目的标注人数可以不同；返回两两一致率的平均值与Fleiss' kappa
+func fleiss(units [][]Label) (percent, kappa *float64) {
+	total := 0
+	categories := make(map[string]int)
+	sumP := 0.0
+	for _, u := range units {
+		counts := make(map[string]int)
+		for _, l := range u {
+			counts[l.key()]++
+			categories[l.key()]++
+		}
+		n := len(u)
+		agree := 0
+		for _, c := range counts {
+			agree += c * (c - 1)
+		}
+		sumP += float64(agree) / float64(n*(n-1))
+		total += n
+	}
+	pBar := sumP / float64(len(units))
+	pe := 0.0
+	for _, c := range categories {
+		p := float64(c) / float64(total)
+		pe += p * p
+	}
+	percent = float(pBar)
+	if pe < 1 {
+		kappa = float((pBar - pe) / (1 - pe))
+	}
+	return percent, kappa
+}
+
+type coderPair struct {
+	a, b int64
+}
+
+type pairStat struct {
+	n, agree int
+	ma, mb   map[string]int // 两名标注员各自的类别分布
+}
+
+// meanCohen 标注员两两之间Cohen's kappa的平均值(Light's kappa)，只统计共同标注条目数不少于minItems的标注员对
+func meanCohen(units [][]Label, minItems int) (kappa *float64, pairs int) {
+	if minItems < 1 {
+		minItems = 1
+	}
+	stats := make(map[coderPair]*pairStat)
+	for _, u := range units {
+		for i := 0; i < len(u); i++ {
+			for j := i + 1; j < len(u); j++ {
+				a, b := u[i], u[j]
+				if a.Coder > b.Coder {
+					a, b = b, a
+				}
+				p := coderPair{a.Coder, b.Coder}
+				s, ok := stats[p]
+				if !ok {
+					s = &pairStat{ma: make(map[string]int), mb: make(map[string]int)}
+					stats[p] = s
+				}
+				s.n++
+				s.ma[a.key()]++
+				s.mb[b.key()]++
+				if a.key() == b.key() {
+					s.agree++
+				}
+			}
+		}
+	}
+	sum := 0.0
+	for _, s := range stats {
+		if s.n < minItems {
+			continue
+		}
+		po := float64(s.agree) / float64(s.n)
+		pe := 0.0
+		for k, ca := range s.ma {
+			pe += float64(ca) / float64(s.n) * float64(s.mb[k]) / float64(s.n)
+		}
+		if pe >= 1 {
+			// 两人始终给出同一个类别，kappa无定义
+			continue
+		}
+		sum += (po - pe) / (1 - pe)
+		pairs++
+	}
+	if pairs == 0 {
+		return nil, 0
+	}
+	return float(sum / float64(pairs)), pairs
+}
+
+// krippendorff Krippendorff's alpha = 1 - 观测到的不一致 / 期望的不一致
+func krippendorff(units [][]Label, delta func(a, b []string) float64) *float64 {
+	// 期望不一致按不同的标注值计算，避免对全部标注两两比较
+	values := make(map[string][]string)
+	counts := make(map[string]int)
+	n := 0
+	observed := 0.0
+	for _, u := range units {
+		m := len(u)
+		sum := 0.0
+		for i := 0; i < m; i++ {
+			for j := i + 1; j < m; j++ {
+				sum += 2 * delta(u[i].Values, u[j].Values)
+			}
+		}
+		observed += sum / float64(m-1)
+		for _, l := range u {
+			values[l.key()] = l.Values
+			counts[l.key()]++
+		}
+		n += m
+	}
+	observed /= float64(n)
+
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	expected := 0.0
+	for i := 0; i < len(keys); i++ {
+		for j := i + 1; j < len(keys); j++ {
+			expected += 2 * float64(counts[keys[i]]*counts[keys[j]]) * delta(values[keys[i]], values[keys[j]])
+		}
+	}
+	expected /= float64(n * (n - 1))
+	if expected == 0 {
+		// 全部标注相同，alpha无定义
+		return nil
+	}
+	return float(1 - observed/expected)
+}
+
+func distanceFunc(level Level) func(a, b []string) float64 {
+	switch level {
+	case Interval:
+		return intervalDistance
+	case Set:
+		return setDistance
+	case Ranking:
+		return rankingDistance
+	}
+	return nominalDistance
+}
+
+func nominalDistance(a, b []string) float64 {
+	if strings.Join(a, "\x1f") == strings.Join(b, "\x1f") {
+		return 0
+	}
+	return 1
+}
+
+// intervalDistance 无法解析为数值时按类别比较
+func intervalDistance(a, b []string) float64 {
+	if len(a) != 1 || len(b) != 1 {
+		return nominalDistance(a, b)
+	}
+	x, errX := strconv.ParseFloat(a[0], 64)
+	y, errY := strconv.ParseFloat(b[0], 64)
+	if errX != nil || errY != nil {
+		return nominalDistance(a, b)
+	}
+	return (x - y) * (x - y)
+}
+
+func setDistance(a, b []string) float64 {
+	set := make(map[string]bool, len(a))
+	for _, v := range a {
+		set[v] = true
+	}
+	inter := 0
+	union := len(set)
+	for _, v := range b {
+		if set[v] {
+			inter++
+		} else {
+			union++
+		}
+	}
+	if union == 0 {
+		return 0
+	}
+	return 1 - float64(inter)/float64(union)
+}
+
+// rankingDistance 只比较两个排序中共同出现的选项，共同选项不足两个时按类别比较
+func rankingDistance(a, b []string) float64 {
+	pos := make(map[string]int, len(b))
+	for i, v := range b {
+		pos[v] = i
+	}
+	var common []int // a中的共同选项在b中的位置，按a中的顺序
+	for _, v := range a {
+		if p, ok := pos[v]; ok {
+			common = append(common, p)
+		}
+	}
+	if len(common) < 2 {
+		return nominalDistance(a, b)
+	}
+	discordant := 0
+	for i := 0; i < len(common); i++ {
+		for j := i + 1; j < len(common); j++ {
+			if common[i] > common[j] {
+				discordant++
+			}
+		}
+	}
+	return float64(discordant) / float64(len(common)*(len(common)-1)/2)
+}
+
+// float 指标无法定义(NaN)时返回nil，便于JSON序列化
+func float(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return &v
+}
@@ -0,0 +1,141 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// countsLabels 由每个条目上各类别的标注人数构造标注，类别为下标，标注员按条目内的顺序编号
+func countsLabels(table [][]int) []Label {
+	var labels []Label
+	for i, row := range table {
+		coder := int64(0)
+		for category, n := range row {
+			for k := 0; k < n; k++ {
+				coder++
+				labels = append(labels, Label{Unit: fmt.Sprint(i), Coder: coder, Values: []string{fmt.Sprint(category + 1)}})
+			}
+		}
+	}
+	return labels
+}
+
+// matrixLabels 由"标注员 x 条目"的矩阵构造标注，空字符串表示缺失
+func matrixLabels(rows [][]string) []Label {
+	var labels []Label
+	for unit := range rows[0] {
+		for coder, row := range rows {
+			if row[unit] != "" {
+				labels = append(labels, Label{Unit: fmt.Sprint(unit), Coder: int64(coder + 1), Values: []string{row[unit]}})
+			}
+		}
+	}
+	return labels
+}
+
+func near(p *float64, want, tolerance float64) bool {
+	return p != nil && math.Abs(*p-want) <= tolerance
+}
+
+// TestFleissKappa Fleiss (1971)的例子：14名标注员将10个条目分为5类，P̄=0.378，P̄e=0.213，kappa=0.210
+func TestFleissKappa(t *testing.T) {
+	labels := countsLabels([][]int{
+		{0, 0, 0, 0, 14},
+		{0, 2, 6, 4, 2},
+		{0, 0, 3, 5, 6},
+		{0, 3, 9, 2, 0},
+		{2, 2, 8, 1, 1},
+		{7, 7, 0, 0, 0},
+		{3, 2, 6, 3, 0},
+		{2, 5, 3, 2, 2},
+		{6, 5, 2, 1, 0},
+		{0, 2, 2, 3, 7},
+	})
+	got := Compute(labels, Nominal, 1)
+	if got.Units != 10 || got.Labels != 140 {
+		t.Fatalf("units = %d labels = %d, want 10 140", got.Units, got.Labels)
+	}
+	if !near(got.PercentAgreement, 0.378, 5e-4) || !near(got.FleissKappa, 0.210, 5e-4) {
+		t.Fatalf("percent = %v kappa = %v, want 0.378 0.210", deref(got.PercentAgreement), deref(got.FleissKappa))
+	}
+}
+
+// TestCohenKappa 两名标注员对50个条目作是/否判断：同为是20，A是B否5，A否B是10，同为否15，
+// po=0.7，pe=0.5×0.6+0.5×0.4=0.5，kappa=0.4
+func TestCohenKappa(t *testing.T) {
+	var labels []Label
+	add := func(n int, a, b string) {
+		for i := 0; i < n; i++ {
+			unit := fmt.Sprint(len(labels) / 2)
+			labels = append(labels, Label{Unit: unit, Coder: 1, Values: []string{a}}, Label{Unit: unit, Coder: 2, Values: []string{b}})
+		}
+	}
+	add(20, "yes", "yes")
+	add(5, "yes", "no")
+	add(10, "no", "yes")
+	add(15, "no", "no")
+	got := Compute(labels, Nominal, 1)
+	if got.CoderPairs != 1 || !near(got.CohenKappa, 0.4, 1e-9) || !near(got.PercentAgreement, 0.7, 1e-9) {
+		t.Fatalf("pairs = %d kappa = %v percent = %v, want 1 0.4 0.7", got.CoderPairs, deref(got.CohenKappa), deref(got.PercentAgreement))
+	}
+	// 共同标注的条目数不足时不计算
+	if got = Compute(labels, Nominal, 51); got.CohenKappa != nil || got.CoderPairs != 0 {
+		t.Fatalf("kappa with too few items = %v, want nil", deref(got.CohenKappa))
+	}
+}
+
+// TestKrippendorffAlpha Krippendorff (2011) "Computing Krippendorff's alpha-reliability"中
+// 4名标注员、12个条目且有缺失值的例子：名义尺度alpha=0.743，等距尺度alpha=0.849；只有一人标注的条目12不参与计算
+func TestKrippendorffAlpha(t *testing.T) {
+	labels := matrixLabels([][]string{
+		{"1", "2", "3", "3", "2", "1", "4", "1", "2", "", "", ""},
+		{"1", "2", "3", "3", "2", "2", "4", "1", "2", "5", "", "3"},
+		{"", "3", "3", "3", "2", "3", "4", "2", "2", "5", "1", ""},
+		{"1", "2", "3", "3", "2", "4", "4", "1", "2", "5", "1", ""},
+	})
+	nominal := Compute(labels, Nominal, 1)
+	if nominal.Units != 11 || nominal.Labels != 40 {
+		t.Fatalf("units = %d labels = %d, want 11 40", nominal.Units, nominal.Labels)
+	}
+	if !near(nominal.KrippendorffAlpha, 0.743, 5e-4) {
+		t.Fatalf("nominal alpha = %v, want 0.743", deref(nominal.KrippendorffAlpha))
+	}
+	if interval := Compute(labels, Interval, 1); !near(interval.KrippendorffAlpha, 0.849, 5e-4) {
+		t.Fatalf("interval alpha = %v, want 0.849", deref(interval.KrippendorffAlpha))
+	}
+}
+
+func TestAgreementDegenerate(t *testing.T) {
+	// 没有标注
+	if got := Compute(nil, Nominal, 1); got.Units != 0 || got.PercentAgreement != nil || got.FleissKappa != nil ||
+		got.CohenKappa != nil || got.KrippendorffAlpha != nil {
+		t.Fatalf("Compute(nil) = %+v, want all metrics nil", got)
+	}
+	// 只有一名标注员，每个条目都只有一个标注；同一标注员的重复标注只保留第一次
+	single := labelsOf("u1:1:x", "u2:1:y", "u3:1:x", "u1:1:y")
+	if got := Compute(single, Nominal, 1); got.Units != 0 || got.Coders != 0 || got.FleissKappa != nil || got.KrippendorffAlpha != nil {
+		t.Fatalf("single coder = %+v, want no pairable units", got)
+	}
+	// 全部标注相同：一致率为1，期望一致率也为1，kappa与alpha无定义
+	same := labelsOf("u1:1:x", "u1:2:x", "u2:1:x", "u2:2:x", "u2:3:x")
+	got := Compute(same, Nominal, 1)
+	if !near(got.PercentAgreement, 1, 1e-12) || got.FleissKappa != nil || got.CohenKappa != nil || got.KrippendorffAlpha != nil {
+		t.Fatalf("unanimous = percent %v kappa %v cohen %v alpha %v, want 1 and nil",
+			deref(got.PercentAgreement), deref(got.FleissKappa), deref(got.CohenKappa), deref(got.KrippendorffAlpha))
+	}
+	// 每个条目内一致但条目之间答案不同：各指标均为1
+	agreed := labelsOf("u1:1:x", "u1:2:x", "u2:1:y", "u2:2:y", "u3:1:x", "u3:2:x")
+	got = Compute(agreed, Nominal, 1)
+	if !near(got.FleissKappa, 1, 1e-12) || !near(got.CohenKappa, 1, 1e-12) || !near(got.KrippendorffAlpha, 1, 1e-12) {
+		t.Fatalf("perfect agreement = kappa %v cohen %v alpha %v, want 1",
+			deref(got.FleissKappa), deref(got.CohenKappa), deref(got.KrippendorffAlpha))
+	}
+}
+
+func deref(p *float64) interface{} {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
@@ -0,0 +1,191 @@
+package analytics
+
+import "math"
+
+// 共识标签：把多名标注员对同一条目的标注合并为一个最终标签
+// 标注值按类别处理(值完全相同才视为同一答案)，多选与排序答案整体作为一个类别
+
+// Consensus 一个条目的共识标签
+type Consensus struct {
+	Unit       string
+	Values     []string // 共识标注值
+	Confidence float64  // 共识标签的置信度：投票时为得票占比，Dawid–Skene为后验概率
+	Labels     int      // 条目上的标注数
+}
+
+// Vote 逐条目投票，weight为nil时为简单多数投票，否则每个标注员的票按weight(coder)计
+// 得票相同时取最先出现的答案；条目按首次出现的顺序排列
+func Vote(labels []Label, weight func(coder int64) float64) []*Consensus {
+	units := groupUnits(labels)
+	res := make([]*Consensus, 0, len(units))
+	for _, u := range units {
+		scores := make(map[string]float64)
+		var order []string
+		values := make(map[string][]string)
+		total := 0.0
+		for _, l := range u {
+			w := 1.0
+			if weight != nil {
+				w = weight(l.Coder)
+			}
+			k := l.key()
+			if _, ok := values[k]; !ok {
+				values[k] = l.Values
+				order = append(order, k)
+			}
+			scores[k] += w
+			total += w
+		}
+		best := order[0]
+		for _, k := range order[1:] {
+			if scores[k] > scores[best] {
+				best = k
+			}
+		}
+		c := &Consensus{Unit: u[0].Unit, Values: values[best], Labels: len(u)}
+		if total > 0 {
+			c.Confidence = scores[best] / total
+		}
+		res = append(res, c)
+	}
+	return res
+}
+
+// dsSmoothing 混淆矩阵每个格子的伪计数，避免只标注过少量条目的标注员出现概率为0的答案
+const dsSmoothing = 0.01
+
+// dsCell 混淆矩阵的一个格子：标注员coder在真实类别为truth时给出答案answer
+type dsCell struct{ coder, truth, answer int }
+
+// dsRow 混淆矩阵的一行：标注员coder在真实类别为truth时的全部答案
+type dsRow struct{ coder, truth int }
+
+// DawidSkene 用EM算法拟合Dawid–Skene模型：为每个标注员估计混淆矩阵(真实类别 -> 标注答案的概率)，
+// 由此得到每个条目真实类别的后验分布，取后验概率最大的类别作为共识标签
+// 每个条目的候选类别只包括该条目上出现过的答案，共识标签总是某名标注员给出的答案；
+// 混淆矩阵只保存在同一条目上共同出现过的(真实类别, 答案)组合，多选与排序题答案组合很多时也不会按类别数的平方分配内存
+// 以多数投票的结果初始化，最多迭代iterations轮；条目按首次出现的顺序排列
+func DawidSkene(labels []Label, iterations int) []*Consensus {
+	units := groupUnits(labels)
+	if len(units) == 0 {
+		return []*Consensus{}
+	}
+	// 类别与标注员编号
+	classIndex := make(map[string]int)
+	var classValues [][]string
+	coderIndex := make(map[int64]int)
+	type obs struct{ coder, class int }
+	observed := make([][]obs, len(units))
+	candidates := make([][]int, len(units)) // 条目上出现过的类别，按首次出现的顺序
+	for i, u := range units {
+		seen := make(map[int]bool)
+		for _, l := range u {
+			k := l.key()
+			c, ok := classIndex[k]
+			if !ok {
+				c = len(classValues)
+				classIndex[k] = c
+				classValues = append(classValues, l.Values)
+			}
+			j, ok := coderIndex[l.Coder]
+			if !ok {
+				j = len(coderIndex)
+				coderIndex[l.Coder] = j
+			}
+			observed[i] = append(observed[i], obs{coder: j, class: c})
+			if !seen[c] {
+				seen[c] = true
+				candidates[i] = append(candidates[i], c)
+			}
+		}
+	}
+	nClass := len(classValues)
+
+	// 初始化：后验为各条目的答案分布，posterior[i][n]对应候选类别candidates[i][n]
+	posterior := make([][]float64, len(units))
+	for i, os := range observed {
+		posterior[i] = make([]float64, len(candidates[i]))
+		for _, o := range os {
+			for n, c := range candidates[i] {
+				if c == o.class {
+					posterior[i][n] += 1 / float64(len(os))
+				}
+			}
+		}
+	}
+	prior := make([]float64, nClass)
+	var counts map[dsCell]float64
+	var rows map[dsRow]float64
+	// confusion 标注员coder在真实类别为truth时给出答案answer的概率，未保存的格子只有伪计数
+	confusion := func(coder, truth, answer int) float64 {
+		return (counts[dsCell{coder, truth, answer}] + dsSmoothing) /
+			(rows[dsRow{coder, truth}] + dsSmoothing*float64(nClass))
+	}
+	logp := make([]float64, 0)
+	for iter := 0; iter < iterations; iter++ {
+		// M步：由后验估计类别先验与混淆矩阵
+		for k := range prior {
+			prior[k] = 0
+		}
+		counts = make(map[dsCell]float64)
+		rows = make(map[dsRow]float64)
+		for i, os := range observed {
+			for n, k := range candidates[i] {
+				p := posterior[i][n]
+				prior[k] += p
+				for _, o := range os {
+					counts[dsCell{o.coder, k, o.class}] += p
+					rows[dsRow{o.coder, k}] += p
+				}
+			}
+		}
+		for k := range prior {
+			prior[k] /= float64(len(units))
+		}
+		// E步：在对数空间计算后验，避免标注数多时下溢
+		maxDiff := 0.0
+		for i, os := range observed {
+			logp = logp[:0]
+			top := math.Inf(-1)
+			for _, k := range candidates[i] {
+				lp := math.Log(prior[k] + 1e-12)
+				for _, o := range os {
+					lp += math.Log(confusion(o.coder, k, o.class))
+				}
+				logp = append(logp, lp)
+				top = math.Max(top, lp)
+			}
+			sum := 0.0
+			for n := range logp {
+				logp[n] = math.Exp(logp[n] - top)
+				sum += logp[n]
+			}
+			for n := range logp {
+				p := logp[n] / sum
+				maxDiff = math.Max(maxDiff, math.Abs(p-posterior[i][n]))
+				posterior[i][n] = p
+			}
+		}
+		if maxDiff < 1e-6 {
+			break
+		}
+	}
+
+	res := make([]*Consensus, 0, len(units))
+	for i, u := range units {
+		// 后验相同时取条目上最先出现的答案，与投票的规则一致
+		best := 0
+		for n, p := range posterior[i] {
+			if p > posterior[i][best] {
+				best = n
+			}
+		}
+		res = append(res, &Consensus{
+			Unit:       u[0].Unit,
+			Values:     classValues[candidates[i][best]],
+			Confidence: posterior[i][best],
+			Labels:     len(u),
+		})
+	}
+	return res
+}
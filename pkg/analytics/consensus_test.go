@@ -0,0 +1,126 @@
+package analytics
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// labelsOf 由"条目:标注员:答案"构造标注，多个答案以|分隔
+func labelsOf(specs ...string) []Label {
+	labels := make([]Label, 0, len(specs))
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 3)
+		var coder int64
+		for _, c := range parts[1] {
+			coder = coder*10 + int64(c-'0')
+		}
+		labels = append(labels, Label{Unit: parts[0], Coder: coder, Values: strings.Split(parts[2], "|")})
+	}
+	return labels
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestVote(t *testing.T) {
+	labels := labelsOf("u1:1:x", "u1:2:y", "u1:3:y", "u2:1:x", "u2:2:y")
+	got := Vote(labels, nil)
+	if len(got) != 2 || got[0].Values[0] != "y" || !approx(got[0].Confidence, 2.0/3) {
+		t.Fatalf("majority vote on u1 = %+v", got[0])
+	}
+	// 得票相同时取最先出现的答案
+	if got[1].Values[0] != "x" || !approx(got[1].Confidence, 0.5) {
+		t.Fatalf("tie on u2 = %+v", got[1])
+	}
+	weighted := Vote(labels, func(coder int64) float64 {
+		if coder == 1 {
+			return 3
+		}
+		return 1
+	})
+	if weighted[0].Values[0] != "x" || !approx(weighted[0].Confidence, 0.6) {
+		t.Fatalf("weighted vote on u1 = %+v", weighted[0])
+	}
+}
+
+func TestDawidSkeneEmpty(t *testing.T) {
+	if got := DawidSkene(nil, 10); len(got) != 0 {
+		t.Fatalf("DawidSkene(nil) = %v, want empty", got)
+	}
+}
+
+// TestDawidSkeneOneIteration 手算一轮EM，类别x、y，伪计数为s：
+// u1: 标注员1、2答x，标注员3答y；u2: 标注员1、2答y；u3: 标注员1答x，标注员2答y
+// 初始后验 u1=(2/3,1/3) u2=(0,1) u3=(1/2,1/2)，M步得先验(7/18, 11/18)，
+// 标注员1: x行 x:7/6，合计7/6；y行 x:5/6 y:1，合计11/6
+// 标注员2: x行 x:2/3 y:1/2，合计7/6；y行 x:1/3 y:3/2，合计11/6
+// 混淆概率为(计数+s)/(合计+2s)，E步中u3的后验 x ∝ 7/18·c1(x→x)·c2(x→y)，y ∝ 11/18·c1(y→x)·c2(y→y)
+// u2只有一个候选类别y，后验为1
+func TestDawidSkeneOneIteration(t *testing.T) {
+	labels := labelsOf("u1:1:x", "u1:2:x", "u1:3:y", "u2:1:y", "u2:2:y", "u3:1:x", "u3:2:y")
+	got := DawidSkene(labels, 1)
+	if len(got) != 3 {
+		t.Fatalf("got %d units, want 3", len(got))
+	}
+	s := dsSmoothing
+	px := 7.0 / 18 * (7.0/6 + s) / (7.0/6 + 2*s) * (0.5 + s) / (7.0/6 + 2*s)
+	py := 11.0 / 18 * (5.0/6 + s) / (11.0/6 + 2*s) * (1.5 + s) / (11.0/6 + 2*s)
+	if got[2].Values[0] != "y" || !approx(got[2].Confidence, py/(px+py)) {
+		t.Fatalf("u3 = %v %.9f, want y %.9f", got[2].Values, got[2].Confidence, py/(px+py))
+	}
+	if got[1].Values[0] != "y" || !approx(got[1].Confidence, 1) {
+		t.Fatalf("u2 = %v %.9f, want y 1", got[1].Values, got[1].Confidence)
+	}
+}
+
+// TestDawidSkeneObservedCandidates 标注员3总是给出与其他人不同的答案，
+// 只有标注员3标注的条目也不会被改成没有人给出的答案
+func TestDawidSkeneObservedCandidates(t *testing.T) {
+	labels := labelsOf(
+		"u1:1:x", "u1:2:x", "u1:3:y",
+		"u2:1:x", "u2:2:x", "u2:3:y",
+		"u3:1:y", "u3:2:y", "u3:3:x",
+		"u4:1:y", "u4:2:y", "u4:3:x",
+		"u5:3:y",
+	)
+	got := DawidSkene(labels, 50)
+	for i, want := range []string{"x", "x", "y", "y"} {
+		if got[i].Values[0] != want {
+			t.Fatalf("%s = %v, want %s", got[i].Unit, got[i].Values, want)
+		}
+	}
+	if got[4].Values[0] != "y" || !approx(got[4].Confidence, 1) {
+		t.Fatalf("u5 = %v %.3f, want the only observed answer y with confidence 1", got[4].Values, got[4].Confidence)
+	}
+}
+
+// TestDawidSkeneReliableCoder 可靠的标注员在分歧时胜过不可靠的多数
+func TestDawidSkeneReliableCoder(t *testing.T) {
+	labels := labelsOf(
+		// 标注员1、2、3在前四个条目上一致，标注员4、5与他们的答案无关
+		"u1:1:a", "u1:2:a", "u1:3:a", "u1:4:b", "u1:5:b",
+		"u2:1:b", "u2:2:b", "u2:3:b", "u2:4:a", "u2:5:a",
+		"u3:1:a", "u3:2:a", "u3:3:a", "u3:4:a", "u3:5:b",
+		"u4:1:b", "u4:2:b", "u4:3:b", "u4:4:b", "u4:5:a",
+		// 多数票为b，但三名可靠的标注员中两名答a
+		"u5:1:a", "u5:2:a", "u5:3:b", "u5:4:b", "u5:5:b",
+	)
+	got := DawidSkene(labels, 50)
+	if got[4].Values[0] != "a" {
+		t.Fatalf("u5 = %v %.3f, want a", got[4].Values, got[4].Confidence)
+	}
+	if vote := Vote(labels, nil); vote[4].Values[0] != "b" {
+		t.Fatalf("majority vote on u5 = %v, want b", vote[4].Values)
+	}
+}
+
+// TestDawidSkeneSetValues 多选答案整体作为一个类别，原样返回
+func TestDawidSkeneSetValues(t *testing.T) {
+	labels := labelsOf("u1:1:a|b", "u1:2:a|b", "u1:3:a")
+	got := DawidSkene(labels, 10)
+	if strings.Join(got[0].Values, "|") != "a|b" || got[0].Labels != 3 {
+		t.Fatalf("u1 = %+v, want a|b", got[0])
+	}
+}
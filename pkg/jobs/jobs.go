@@ -0,0 +1,62 @@
+package jobs
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// jobs 后台定时任务：按固定间隔在独立的goroutine中执行，出错只打log
+// 多实例部署时设置Lock，同一任务在一个间隔内只在取得锁的实例上执行一次
+
+// Locker 分布式锁：在ttl内只有一个调用方能取得名为name的锁，锁到期自动释放
+type Locker interface {
+	TryLock(name string, ttl time.Duration) (ok bool, err error)
+}
+
+// Lock 任务使用的分布式锁，为nil时每个实例都会执行
+var Lock Locker
+
+// Every 每隔interval执行一次fn(启动时不立即执行)，调用返回的stop后不再执行，并等待正在执行的一次结束
+func Every(name string, interval time.Duration, fn func() error) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// 锁在一个间隔后到期，不主动释放，以免其它实例在同一间隔内再次执行；执行时间超过间隔时可能与其它实例重叠
+				if Lock != nil {
+					ok, err := Lock.TryLock(name, interval)
+					if err != nil {
+						zap.L().Error("job lock failed", zap.String("job", name), zap.Error(err))
+						continue
+					}
+					if !ok {
+						continue
+					}
+				}
+				start := time.Now()
+				if err := fn(); err != nil {
+					zap.L().Error("job failed", zap.String("job", name), zap.Error(err))
+					continue
+				}
+				zap.L().Info("job finished", zap.String("job", name), zap.Duration("cost", time.Since(start)))
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
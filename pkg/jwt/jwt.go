@@ -3,11 +3,23 @@ package jwt
 import (
 	"errors"
 	"github.com/dgrijalva/jwt-go"
+	"strconv"
 	"time"
 )
 
 // 定义JWT的过期时间
-const TokenExpireDuration = time.Hour * 24 * 7
+const (
+	TokenExpireDuration        = time.Hour * 24 * 7
+	RefreshTokenExpireDuration = time.Hour * 24 * 30
+)
+
+// 用audience区分两种token，防止refresh token被当作access token使用(反之亦然)
+const (
+	audienceAccess  = "access"
+	audienceRefresh = "refresh"
+)
+
+var ErrorInvalidToken = errors.New("invalid token")
 
 // 定义密钥
 var mySecret = []byte("SC GPT Evaluation")
@@ -22,33 +34,57 @@ func keyFunc(_ *jwt.Token) (i interface{}, err error) {
 type MyClaims struct {
 	UserID   int64  `json:"user_id"`
 	Username string `json:"username"`
+	Role     string `json:"role"`
+	FamilyID string `json:"fid"` // 所属的登录会话(即refresh token family)，会话失效后token随之失效
+	jwt.StandardClaims
+}
+
+// RefreshClaims refresh token的声明，Subject为用户id，Id(jti)为本次签发的唯一id
+// 同一次登录后轮换出的所有refresh token属于同一个family
+type RefreshClaims struct {
+	FamilyID string `json:"fid"`
 	jwt.StandardClaims
 }
 
 // GenToken 生成access token 和 refresh token
-func GenToken(userID int64, username string) (aToken, rToken string, err error) {
+func GenToken(userID int64, username, role, familyID, jti string) (aToken, rToken string, err error) {
+	now := time.Now()
 	// 创建一个自定义的结构体数据
 	c := MyClaims{
 		userID, // 自定义字段
 		username,
+		role,
+		familyID,
 		jwt.StandardClaims{ // JWT标准字段
-			ExpiresAt: time.Now().Add(TokenExpireDuration).Unix(), // 过期时间
-			Issuer:    "moker_reader",                             // 签发人
+			Audience:  audienceAccess,
+			ExpiresAt: now.Add(TokenExpireDuration).Unix(), // 过期时间
+			Issuer:    "moker_reader",                      // 签发人
 		},
 	}
 	// 使用指定的签名方法和secret签名, 加密并获得完整的编码后的字符串token
-	aToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString(mySecret)
+	if aToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString(mySecret); err != nil {
+		return "", "", err
+	}
 
-	// refresh token 不需要任何自定义数据
-	rToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
-		ExpiresAt: time.Now().Add(time.Second * 30).Unix(), // 过期时间
-		Issuer:    "bluebell",                              // 签发人
-	}).SignedString(mySecret)
-	// 使用指定的secret签名并获得完整的编码后的字符串token
-	return
+	// refresh token 绑定用户与jti，便于服务端轮换与吊销
+	rc := RefreshClaims{
+		familyID,
+		jwt.StandardClaims{
+			Audience:  audienceRefresh,
+			Id:        jti,
+			Subject:   strconv.FormatInt(userID, 10),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(RefreshTokenExpireDuration).Unix(),
+			Issuer:    "moker_reader",
+		},
+	}
+	if rToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, rc).SignedString(mySecret); err != nil {
+		return "", "", err
+	}
+	return aToken, rToken, nil
 }
 
-// ParseToken 解析JWT
+// ParseToken 解析access token
 func ParseToken(tokenString string) (claims *MyClaims, err error) {
 	// 解析token
 	var token *jwt.Token
@@ -57,26 +93,22 @@ func ParseToken(tokenString string) (claims *MyClaims, err error) {
 	if err != nil {
 		return
 	}
-	if !token.Valid { // 校验token
-		err = errors.New("invalid token")
+	if !token.Valid || !claims.VerifyAudience(audienceAccess, true) { // 校验token
+		err = ErrorInvalidToken
 	}
 	return
 }
 
-func RefreshToken(aToken, rToken string) (newAToken, newRToken string, err error) {
-	// refresh token无效则直接返回
-	if _, err = jwt.Parse(rToken, keyFunc); err != nil {
-		return
+// ParseRefreshToken 解析refresh token
+func ParseRefreshToken(tokenString string) (claims *RefreshClaims, err error) {
+	claims = new(RefreshClaims)
+	token, err := jwt.ParseWithClaims(tokenString, claims, keyFunc)
+	if err != nil {
+		return nil, ErrorInvalidToken
 	}
-
-	// 从旧的Access Token中解析出claims数据
-	var claims MyClaims
-	_, err = jwt.ParseWithClaims(aToken, &claims, keyFunc)
-	v, _ := err.(*jwt.ValidationError)
-
-	// 当Access Token是过期错误 并且 Refresh Token没有过期时就创建一个新的Access Token
-	if v.Errors == jwt.ValidationErrorExpired {
-		return GenToken(claims.UserID, claims.Username)
+	if !token.Valid || !claims.VerifyAudience(audienceRefresh, true) ||
+		claims.Id == "" || claims.FamilyID == "" {
+		return nil, ErrorInvalidToken
 	}
-	return
+	return claims, nil
 }
@@ -0,0 +1,94 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	argon2SaltLen = 16
+	argon2KeyLen  = 32
+)
+
+// argon2idHasher argon2id算法，哈希串采用PHC格式: $argon2id$v=19$m=65536,t=3,p=2$<盐值>$<哈希>
+type argon2idHasher struct {
+	memory  uint32 // KiB
+	time    uint32
+	threads uint8
+}
+
+func newArgon2idHasher(memory, time uint32, threads uint8) *argon2idHasher {
+	if memory == 0 {
+		memory = 64 * 1024
+	}
+	if time == 0 {
+		time = 3
+	}
+	if threads == 0 {
+		threads = 2
+	}
+	return &argon2idHasher{memory: memory, time: time, threads: threads}
+}
+
+func (h *argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.time, h.memory, h.threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.memory, h.time, h.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *argon2idHasher) Verify(password, encoded string) (bool, error) {
+	p, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	other := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (h *argon2idHasher) Match(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (h *argon2idHasher) NeedsRehash(encoded string) bool {
+	p, _, _, err := decodeArgon2id(encoded)
+	return err != nil || *p != *h
+}
+
+// decodeArgon2id 从PHC格式哈希串中解析出参数、盐值与哈希
+func decodeArgon2id(encoded string) (p *argon2idHasher, salt, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return nil, nil, nil, ErrorInvalidHash
+	}
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, ErrorInvalidHash
+	}
+	p = new(argon2idHasher)
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil {
+		return nil, nil, nil, ErrorInvalidHash
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, nil, nil, ErrorInvalidHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, nil, nil, ErrorInvalidHash
+	}
+	// t或p为0时argon2.IDKey会panic；盐值或哈希为空时任意密码都能通过比较
+	if p.time < 1 || p.threads < 1 || len(salt) == 0 || len(key) == 0 {
+		return nil, nil, nil, ErrorInvalidHash
+	}
+	return p, salt, key, nil
+}
@@ -0,0 +1,34 @@
+package password
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+)
+
+// legacySecret 旧版本固定盐值，仅用于校验历史密码，校验通过后会被升级为当前算法
+const legacySecret = "moker_reader"
+
+// legacyHasher 旧版本MD5+固定盐值算法(只校验不生成)，哈希串不带$前缀
+type legacyHasher struct{}
+
+func (legacyHasher) Hash(string) (string, error) {
+	return "", ErrorUnknownAlgorithm
+}
+
+func (legacyHasher) Verify(password, encoded string) (bool, error) {
+	// 与旧版encryptPassword保持一致
+	h := md5.New()
+	h.Write([]byte(legacySecret))
+	expected := hex.EncodeToString(h.Sum([]byte(password)))
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(encoded)) == 1, nil
+}
+
+func (legacyHasher) Match(encoded string) bool {
+	return encoded != "" && !strings.HasPrefix(encoded, "$")
+}
+
+func (legacyHasher) NeedsRehash(string) bool {
+	return true
+}
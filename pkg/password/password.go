@@ -0,0 +1,76 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"scgptEval/config"
+)
+
+// password 密码哈希子系统：所有哈希串均自带算法标识、参数与盐值，
+// 校验时根据哈希串自动选择对应算法，旧算法或旧参数生成的哈希串会被标记为需要重新哈希
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+var (
+	ErrorUnknownAlgorithm = errors.New("未知的密码哈希算法")
+	ErrorInvalidHash      = errors.New("密码哈希格式有误")
+)
+
+// Hasher 密码哈希算法接口
+type Hasher interface {
+	// Hash 生成包含算法、参数与盐值的编码哈希串
+	Hash(password string) (string, error)
+	// Verify 校验明文密码与编码哈希串是否匹配
+	Verify(password, encoded string) (bool, error)
+	// Match 判断编码哈希串是否由该算法生成
+	Match(encoded string) bool
+	// NeedsRehash 判断编码哈希串的参数是否与当前配置不一致
+	NeedsRehash(encoded string) bool
+}
+
+// current 当前用于生成新哈希的算法；hashers 校验时按顺序匹配的全部算法(legacy兜底，必须放最后)
+var (
+	current Hasher = newBcryptHasher(0)
+	hashers        = []Hasher{current, legacyHasher{}}
+)
+
+// Init 根据配置初始化密码哈希算法
+func Init(cfg *config.PasswordConfig) (err error) {
+	if cfg == nil {
+		cfg = new(config.PasswordConfig)
+	}
+	bh := newBcryptHasher(cfg.BcryptCost)
+	ah := newArgon2idHasher(cfg.Argon2Memory, cfg.Argon2Time, cfg.Argon2Threads)
+	switch cfg.Algorithm {
+	case "", AlgorithmArgon2id:
+		current = ah
+	case AlgorithmBcrypt:
+		current = bh
+	default:
+		return fmt.Errorf("%w: %s", ErrorUnknownAlgorithm, cfg.Algorithm)
+	}
+	hashers = []Hasher{ah, bh, legacyHasher{}}
+	return nil
+}
+
+// Hash 使用当前算法对明文密码进行哈希
+func Hash(password string) (string, error) {
+	return current.Hash(password)
+}
+
+// Verify 校验密码，rehash表示校验通过但哈希串已过时，调用方应使用Hash重新生成并保存
+func Verify(password, encoded string) (ok, rehash bool, err error) {
+	for _, h := range hashers {
+		if !h.Match(encoded) {
+			continue
+		}
+		if ok, err = h.Verify(password, encoded); err != nil || !ok {
+			return false, false, err
+		}
+		return true, h != current || h.NeedsRehash(encoded), nil
+	}
+	return false, false, ErrorInvalidHash
+}
@@ -0,0 +1,113 @@
+package password
+
+import (
+	"errors"
+	"scgptEval/config"
+	"strings"
+	"testing"
+)
+
+// fastArgon2id 测试用的低成本参数
+func fastArgon2id() *argon2idHasher {
+	return newArgon2idHasher(64, 1, 1)
+}
+
+// TestLegacyVerify 旧版encryptPassword把明文作为md5.Sum的前缀，得到的是明文的十六进制
+// 接上md5("moker_reader")，而不是明文加盐后的摘要；以下哈希串由旧版代码生成
+func TestLegacyVerify(t *testing.T) {
+	const encoded = "313233343536e85a3e36b3b1fccd692cc2ab24959b08"
+	h := legacyHasher{}
+	if !h.Match(encoded) || !h.NeedsRehash(encoded) {
+		t.Fatalf("legacy hash should match and need rehash")
+	}
+	if ok, err := h.Verify("123456", encoded); err != nil || !ok {
+		t.Fatalf("Verify(123456) = %v, %v, want true", ok, err)
+	}
+	if ok, _ := h.Verify("1234567", encoded); ok {
+		t.Fatalf("Verify(1234567) = true, want false")
+	}
+}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	h := fastArgon2id()
+	encoded, err := h.Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash() failed: %v", err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=64,t=1,p=1$") || !h.Match(encoded) {
+		t.Fatalf("Hash() = %q", encoded)
+	}
+	p, salt, key, err := decodeArgon2id(encoded)
+	if err != nil || *p != *h || len(salt) != argon2SaltLen || len(key) != argon2KeyLen {
+		t.Fatalf("decodeArgon2id() = %+v %d %d %v", p, len(salt), len(key), err)
+	}
+	if ok, err := h.Verify("secret", encoded); err != nil || !ok {
+		t.Fatalf("Verify(secret) = %v, %v", ok, err)
+	}
+	if ok, err := h.Verify("Secret", encoded); err != nil || ok {
+		t.Fatalf("Verify(Secret) = %v, %v, want false", ok, err)
+	}
+	if again, _ := h.Hash("secret"); again == encoded {
+		t.Fatalf("two hashes share a salt")
+	}
+}
+
+func TestDecodeArgon2idInvalid(t *testing.T) {
+	const salt, key = "c2FsdHNhbHRzYWx0c2FsdA", "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+	for _, encoded := range []string{
+		"$argon2id$v=19$m=64,t=1,p=1$" + salt,              // 缺少哈希
+		"$argon2id$v=18$m=64,t=1,p=1$" + salt + "$" + key,  // 版本不符
+		"$argon2id$v=19$m=64,t=0,p=1$" + salt + "$" + key,  // t=0
+		"$argon2id$v=19$m=64,t=1,p=0$" + salt + "$" + key,  // p=0
+		"$argon2id$v=19$m=64,t=1,p=1$$" + key,              // 空盐值
+		"$argon2id$v=19$m=64,t=1,p=1$" + salt + "$",        // 空哈希
+		"$argon2id$v=19$m=64,t=1,p=1$" + salt + "$!" + key, // 非base64
+	} {
+		if _, _, _, err := decodeArgon2id(encoded); !errors.Is(err, ErrorInvalidHash) {
+			t.Errorf("decodeArgon2id(%q) error = %v, want ErrorInvalidHash", encoded, err)
+		}
+		if ok, err := fastArgon2id().Verify("", encoded); ok || !errors.Is(err, ErrorInvalidHash) {
+			t.Errorf("Verify(%q) = %v, %v, want ErrorInvalidHash", encoded, ok, err)
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	old := fastArgon2id()
+	encoded, _ := old.Hash("secret")
+	if old.NeedsRehash(encoded) {
+		t.Fatalf("hash with current parameters needs rehash")
+	}
+	for _, h := range []*argon2idHasher{newArgon2idHasher(128, 1, 1), newArgon2idHasher(64, 2, 1), newArgon2idHasher(64, 1, 2)} {
+		if !h.NeedsRehash(encoded) {
+			t.Errorf("%+v: NeedsRehash(%q) = false, want true", *h, encoded)
+		}
+	}
+	b := newBcryptHasher(4)
+	bcrypted, _ := b.Hash("secret")
+	if b.NeedsRehash(bcrypted) || !newBcryptHasher(5).NeedsRehash(bcrypted) {
+		t.Fatalf("bcrypt NeedsRehash does not follow the cost")
+	}
+
+	// 校验通过后，其他算法或旧参数生成的哈希串需要重新哈希
+	if err := Init(&config.PasswordConfig{Algorithm: AlgorithmArgon2id, Argon2Memory: 64, Argon2Time: 1, Argon2Threads: 1}); err != nil {
+		t.Fatalf("Init() failed: %v", err)
+	}
+	for _, tt := range []struct {
+		encoded string
+		rehash  bool
+	}{
+		{encoded, false},
+		{bcrypted, true},
+		{"313233343536e85a3e36b3b1fccd692cc2ab24959b08", true},
+	} {
+		password := "secret"
+		if !strings.HasPrefix(tt.encoded, "$") {
+			password = "123456"
+		}
+		ok, rehash, err := Verify(password, tt.encoded)
+		if err != nil || !ok || rehash != tt.rehash {
+			t.Errorf("Verify(%q) = %v %v %v, want true %v", tt.encoded, ok, rehash, err, tt.rehash)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package quizkind
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChoiceOptions 单选、多选与排序题的选项，key为选项标识，value为选项内容，如{"option1":"xx"}
+type ChoiceOptions map[string]string
+
+// choiceKind 单选：恰好选一个选项；多选：至少选一个选项，不可重复；排序：按顺序给出全部选项
+type choiceKind struct {
+	code string
+	name string
+}
+
+func (k *choiceKind) Code() string { return k.code }
+
+func (k *choiceKind) Name() string { return k.name }
+
+func (k *choiceKind) NormalizeOptions(raw []byte) (string, error) {
+	var m ChoiceOptions
+	if err := json.Unmarshal(raw, &m); err != nil || len(m) == 0 {
+		return "", fmt.Errorf("%w: 选项需为非空的JSON对象，且选项内容均为字符串", ErrorInvalidOptions)
+	}
+	for key := range m {
+		if key == "" {
+			return "", fmt.Errorf("%w: 选项标识不能为空", ErrorInvalidOptions)
+		}
+	}
+	if k.code == CodeRanking && len(m) < 2 {
+		return "", fmt.Errorf("%w: 排序题至少需要两个选项", ErrorInvalidOptions)
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrorInvalidOptions, err)
+	}
+	return buf.String(), nil
+}
+
+func (k *choiceKind) Render(options string) (interface{}, error) {
+	var m ChoiceOptions
+	if err := json.Unmarshal([]byte(options), &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// parseChoices 解析提交的选项，支持JSON字符串数组(如["option1","option2"])与逗号分隔(如option1,option2)两种写法
+func parseChoices(answer string) (items []string, err error) {
+	answer = strings.TrimSpace(answer)
+	if strings.HasPrefix(answer, "[") {
+		if err = json.Unmarshal([]byte(answer), &items); err != nil {
+			return nil, fmt.Errorf("%w: JSON格式有误", ErrorInvalidAnswer)
+		}
+	} else if answer != "" {
+		items = strings.Split(answer, ",")
+	}
+	for i := range items {
+		items[i] = strings.TrimSpace(items[i])
+	}
+	return items, nil
+}
+
+// NormalizeAnswer 规范形式为JSON字符串数组，多选题按选项标识排序，排序题保持提交的顺序
+func (k *choiceKind) NormalizeAnswer(options, answer string) (string, error) {
+	var m ChoiceOptions
+	if err := json.Unmarshal([]byte(options), &m); err != nil {
+		return "", err
+	}
+	items, err := parseChoices(answer)
+	if err != nil {
+		return "", err
+	}
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if _, ok := m[item]; !ok {
+			return "", fmt.Errorf("%w: 选项%q不存在", ErrorInvalidAnswer, item)
+		}
+		if seen[item] {
+			return "", fmt.Errorf("%w: 选项%q重复", ErrorInvalidAnswer, item)
+		}
+		seen[item] = true
+	}
+
+	switch k.code {
+	case CodeSingleChoice:
+		if len(items) != 1 {
+			return "", fmt.Errorf("%w: 单选题需选择一个选项", ErrorInvalidAnswer)
+		}
+	case CodeMultiChoice:
+		if len(items) == 0 {
+			return "", fmt.Errorf("%w: 多选题至少选择一个选项", ErrorInvalidAnswer)
+		}
+		sort.Strings(items)
+	case CodeRanking:
+		if len(items) != len(m) {
+			return "", fmt.Errorf("%w: 排序题需按顺序给出全部%d个选项", ErrorInvalidAnswer, len(m))
+		}
+	}
+	return marshalString(items)
+}
@@ -0,0 +1,164 @@
+package quizkind
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strings"
+)
+
+// maxComparisonResponses 模型对比题最多的回答数
+const maxComparisonResponses = 8
+
+// ModelResponse 某个模型对题干(prompt)的回答
+type ModelResponse struct {
+	ModelID string `json:"model_id"`
+	Content string `json:"content"`
+}
+
+// ComparisonOptions 模型对比题的选项：题干为prompt，在多个模型的回答中选出最好的一个
+type ComparisonOptions struct {
+	Responses []ModelResponse `json:"responses"`
+	AllowTie  bool            `json:"allow_tie,omitempty"` // 是否允许选择"一样好"
+}
+
+// ComparisonSlot 下发给前端的一个回答，不暴露模型id
+type ComparisonSlot struct {
+	Label   string `json:"label"` // 展示位置，A、B、C...
+	Content string `json:"content"`
+}
+
+// ComparisonView 下发给前端的模型对比题选项
+type ComparisonView struct {
+	Responses []ComparisonSlot `json:"responses"`
+	AllowTie  bool             `json:"allow_tie"`
+}
+
+// ComparisonAnswer 模型对比题答案的存储形式
+type ComparisonAnswer struct {
+	Choice  string   `json:"choice"`   // 用户选择的展示位置，或tie
+	ModelID string   `json:"model_id"` // 选择的展示位置对应的模型，tie时为空
+	Order   []string `json:"order"`    // 展示给该用户的模型顺序，用于分析位置偏差
+}
+
+type comparisonKind struct{}
+
+func (comparisonKind) Code() string { return CodeComparison }
+
+func (comparisonKind) Name() string { return "model_comparison" }
+
+func (comparisonKind) NormalizeOptions(raw []byte) (string, error) {
+	var o ComparisonOptions
+	if err := decodeStrict(raw, &o); err != nil {
+		return "", err
+	}
+	if len(o.Responses) < 2 || len(o.Responses) > maxComparisonResponses {
+		return "", fmt.Errorf("%w: 模型回答数需在2到%d之间", ErrorInvalidOptions, maxComparisonResponses)
+	}
+	seen := make(map[string]bool, len(o.Responses))
+	for _, r := range o.Responses {
+		if strings.TrimSpace(r.ModelID) == "" || strings.TrimSpace(r.Content) == "" {
+			return "", fmt.Errorf("%w: 模型id与回答内容均不能为空", ErrorInvalidOptions)
+		}
+		if seen[r.ModelID] {
+			return "", fmt.Errorf("%w: 模型%q重复", ErrorInvalidOptions, r.ModelID)
+		}
+		seen[r.ModelID] = true
+	}
+	return marshalString(o)
+}
+
+// Render 未指定用户时按原始顺序展示，出题时应使用RenderFor
+func (k comparisonKind) Render(options string) (interface{}, error) {
+	return k.RenderFor(options, 0, 0)
+}
+
+// NormalizeAnswer 未指定用户时按原始顺序解释答案，提交时应使用NormalizeAnswerFor
+func (k comparisonKind) NormalizeAnswer(options, answer string) (string, error) {
+	return k.NormalizeAnswerFor(options, answer, 0, 0)
+}
+
+func (comparisonKind) parse(options string, userID, quizID int64) (*ComparisonOptions, []int, error) {
+	var o ComparisonOptions
+	if err := json.Unmarshal([]byte(options), &o); err != nil {
+		return nil, nil, err
+	}
+	return &o, displayOrder(len(o.Responses), userID, quizID), nil
+}
+
+func (k comparisonKind) RenderFor(options string, userID, quizID int64) (interface{}, error) {
+	o, order, err := k.parse(options, userID, quizID)
+	if err != nil {
+		return nil, err
+	}
+	view := &ComparisonView{Responses: make([]ComparisonSlot, 0, len(order)), AllowTie: o.AllowTie}
+	for pos, i := range order {
+		view.Responses = append(view.Responses, ComparisonSlot{Label: slotLabel(pos), Content: o.Responses[i].Content})
+	}
+	return view, nil
+}
+
+// NormalizeAnswerFor 答案为展示位置(A、B...)或tie，存储时换算为对应的模型
+func (k comparisonKind) NormalizeAnswerFor(options, answer string, userID, quizID int64) (string, error) {
+	o, order, err := k.parse(options, userID, quizID)
+	if err != nil {
+		return "", err
+	}
+	res := &ComparisonAnswer{Order: make([]string, 0, len(order))}
+	for _, i := range order {
+		res.Order = append(res.Order, o.Responses[i].ModelID)
+	}
+	choice := strings.TrimSpace(answer)
+	if strings.EqualFold(choice, PairwiseTie) {
+		if !o.AllowTie {
+			return "", fmt.Errorf("%w: 该题不允许选择一样好", ErrorInvalidAnswer)
+		}
+		res.Choice = PairwiseTie
+		return marshalString(res)
+	}
+	for pos := range order {
+		if strings.EqualFold(choice, slotLabel(pos)) {
+			res.Choice = slotLabel(pos)
+			res.ModelID = res.Order[pos]
+			return marshalString(res)
+		}
+	}
+	return "", fmt.Errorf("%w: 需为A到%s之间的展示位置", ErrorInvalidAnswer, slotLabel(len(order)-1))
+}
+
+// AnswerKey 展示顺序因用户而异，按选择的模型比对，无法解析的答案原样返回
+func (comparisonKind) AnswerKey(answer string) string {
+	var a ComparisonAnswer
+	if json.Unmarshal([]byte(answer), &a) != nil {
+		return answer
+	}
+	if a.Choice == PairwiseTie {
+		return PairwiseTie
+	}
+	return a.ModelID
+}
+
+// slotLabel 第pos个展示位置的标签
+func slotLabel(pos int) string {
+	return string(rune('A' + pos))
+}
+
+// displayOrder 某个用户看到的回答顺序(原始下标)，由用户id与题目id确定，同一用户多次获取时顺序不变
+// userID为0时返回原始顺序
+func displayOrder(n int, userID, quizID int64) []int {
+	if userID == 0 {
+		order := make([]int, n)
+		for i := range order {
+			order[i] = i
+		}
+		return order
+	}
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], uint64(userID))
+	binary.BigEndian.PutUint64(buf[8:], uint64(quizID))
+	h := fnv.New64a()
+	h.Write(buf[:])
+	return rand.New(rand.NewSource(int64(h.Sum64()))).Perm(n)
+}
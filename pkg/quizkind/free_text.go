@@ -0,0 +1,71 @@
+package quizkind
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultMaxTextLength 未设置最大长度时自由文本答案的最大字数
+const defaultMaxTextLength = 2000
+
+// FreeTextOptions 自由文本题的选项，可为空对象
+type FreeTextOptions struct {
+	MinLength   int    `json:"min_length,omitempty"` // 答案最少字数，至少为1
+	MaxLength   int    `json:"max_length,omitempty"` // 答案最多字数，0表示默认值2000
+	Placeholder string `json:"placeholder,omitempty"`
+}
+
+func (o *FreeTextOptions) lengthRange() (min, max int) {
+	min, max = o.MinLength, o.MaxLength
+	if min < 1 {
+		min = 1
+	}
+	if max == 0 {
+		max = defaultMaxTextLength
+	}
+	return min, max
+}
+
+type freeTextKind struct{}
+
+func (freeTextKind) Code() string { return CodeFreeText }
+
+func (freeTextKind) Name() string { return "free_text" }
+
+func (freeTextKind) NormalizeOptions(raw []byte) (string, error) {
+	var o FreeTextOptions
+	if err := decodeStrict(raw, &o); err != nil {
+		return "", err
+	}
+	if o.MinLength < 0 || o.MaxLength < 0 {
+		return "", fmt.Errorf("%w: 字数限制不能为负数", ErrorInvalidOptions)
+	}
+	if min, max := o.lengthRange(); min > max {
+		return "", fmt.Errorf("%w: min_length不能大于max_length", ErrorInvalidOptions)
+	}
+	return marshalString(o)
+}
+
+func (freeTextKind) Render(options string) (interface{}, error) {
+	var o FreeTextOptions
+	if err := json.Unmarshal([]byte(options), &o); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// NormalizeAnswer 规范形式为去掉首尾空白后的JSON字符串
+func (freeTextKind) NormalizeAnswer(options, answer string) (string, error) {
+	var o FreeTextOptions
+	if err := json.Unmarshal([]byte(options), &o); err != nil {
+		return "", err
+	}
+	answer = strings.TrimSpace(answer)
+	min, max := o.lengthRange()
+	if n := utf8.RuneCountInString(answer); n < min || n > max {
+		return "", fmt.Errorf("%w: 字数需在%d到%d之间", ErrorInvalidAnswer, min, max)
+	}
+	return marshalString(answer)
+}
@@ -0,0 +1,146 @@
+package quizkind
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// quizkind 题目类型注册表：每种题型定义自己的选项结构、新建题目时的选项校验、
+// 出题时下发给前端的选项形式以及作答校验规则
+
+// 题目类型编号，存储于quizzes.type
+const (
+	CodeSingleChoice = "1" // 单选
+	CodeMultiChoice  = "2" // 多选
+	CodeRanking      = "3" // 排序
+	CodeLikert       = "4" // 李克特量表
+	CodeFreeText     = "5" // 自由文本
+	CodePairwise     = "6" // 两两比较
+	CodeComparison   = "7" // 模型对比：多个模型对同一prompt的回答中选出最好的
+)
+
+var (
+	ErrorUnknownKind    = errors.New("题目类型有误")
+	ErrorInvalidOptions = errors.New("选项格式有误")
+	ErrorInvalidAnswer  = errors.New("答案不合法")
+)
+
+// Kind 题目类型
+type Kind interface {
+	// Code 类型编号
+	Code() string
+	// Name 类型名称，下发给前端用于选择渲染组件
+	Name() string
+	// NormalizeOptions 校验选项定义，返回存储用的JSON串
+	NormalizeOptions(raw []byte) (string, error)
+	// Render 将存储的选项转换为下发给前端的结构
+	Render(options string) (interface{}, error)
+	// NormalizeAnswer 按选项校验答案，返回存储用的规范形式(JSON)
+	NormalizeAnswer(options, answer string) (string, error)
+}
+
+// PerUserKind 选项展示顺序因用户而异的题型，出题与提交时需按用户换算
+type PerUserKind interface {
+	Kind
+	RenderFor(options string, userID, quizID int64) (interface{}, error)
+	NormalizeAnswerFor(options, answer string, userID, quizID int64) (string, error)
+}
+
+// RenderFor 按用户渲染题目选项
+func RenderFor(k Kind, options string, userID, quizID int64) (interface{}, error) {
+	if pk, ok := k.(PerUserKind); ok {
+		return pk.RenderFor(options, userID, quizID)
+	}
+	return k.Render(options)
+}
+
+// NormalizeAnswerFor 按用户校验并规范化答案
+func NormalizeAnswerFor(k Kind, options, answer string, userID, quizID int64) (string, error) {
+	if pk, ok := k.(PerUserKind); ok {
+		return pk.NormalizeAnswerFor(options, answer, userID, quizID)
+	}
+	return k.NormalizeAnswer(options, answer)
+}
+
+// AnswerKeyer 规范形式中含有与作答内容无关信息的题型，需提取用于比对答案的键
+// 其余题型直接按规范形式比对
+type AnswerKeyer interface {
+	AnswerKey(answer string) string
+}
+
+// AnswerKey 规范化后的答案用于比对的键，键相同的两个答案视为一致
+func AnswerKey(k Kind, answer string) string {
+	if ak, ok := k.(AnswerKeyer); ok {
+		return ak.AnswerKey(answer)
+	}
+	return answer
+}
+
+// MatchGold 判断规范化后的答案与标准答案是否一致
+func MatchGold(k Kind, answer, gold string) bool {
+	return AnswerKey(k, answer) == AnswerKey(k, gold)
+}
+
+var kinds = make(map[string]Kind)
+
+// Register 注册题目类型，编号重复时panic
+func Register(k Kind) {
+	if _, ok := kinds[k.Code()]; ok {
+		panic(fmt.Sprintf("quizkind: kind %q registered twice", k.Code()))
+	}
+	kinds[k.Code()] = k
+}
+
+// Get 根据类型编号获取题目类型
+func Get(code string) (Kind, error) {
+	k, ok := kinds[code]
+	if !ok {
+		return nil, ErrorUnknownKind
+	}
+	return k, nil
+}
+
+// Codes 全部已注册的类型编号，升序
+func Codes() []string {
+	codes := make([]string, 0, len(kinds))
+	for code := range kinds {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+func init() {
+	Register(&choiceKind{code: CodeSingleChoice, name: "single_choice"})
+	Register(&choiceKind{code: CodeMultiChoice, name: "multi_choice"})
+	Register(&choiceKind{code: CodeRanking, name: "ranking"})
+	Register(likertKind{})
+	Register(freeTextKind{})
+	Register(pairwiseKind{})
+	Register(comparisonKind{})
+}
+
+// decodeStrict 严格解析JSON对象，不允许未知字段
+func decodeStrict(raw []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrorInvalidOptions, err)
+	}
+	if dec.More() {
+		return fmt.Errorf("%w: 存在多余的内容", ErrorInvalidOptions)
+	}
+	return nil
+}
+
+// marshalString 将规范化后的结构序列化为JSON串
+func marshalString(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,174 @@
+package quizkind
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func mustGet(t *testing.T, code string) Kind {
+	t.Helper()
+	k, err := Get(code)
+	if err != nil {
+		t.Fatalf("Get(%q): %v", code, err)
+	}
+	return k
+}
+
+func TestRegistry(t *testing.T) {
+	want := []string{CodeSingleChoice, CodeMultiChoice, CodeRanking, CodeLikert, CodeFreeText, CodePairwise, CodeComparison}
+	if got := Codes(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("Codes() = %v, want %v", got, want)
+	}
+	if _, err := Get("0"); !errors.Is(err, ErrorUnknownKind) {
+		t.Fatalf("Get(\"0\") err = %v, want ErrorUnknownKind", err)
+	}
+}
+
+func TestNormalizeOptions(t *testing.T) {
+	tests := []struct {
+		code string
+		raw  string
+		want string // 为空时期望ErrorInvalidOptions
+	}{
+		{CodeSingleChoice, `{"a": "x", "b": "y"}`, `{"a":"x","b":"y"}`},
+		{CodeSingleChoice, `{}`, ""},
+		{CodeSingleChoice, `["a"]`, ""},
+		{CodeSingleChoice, `{"": "x"}`, ""},
+		{CodeSingleChoice, `{"a": 1}`, ""},
+		{CodeMultiChoice, `{"a": "x"}`, `{"a":"x"}`},
+		{CodeRanking, `{"a": "x"}`, ""},
+		{CodeRanking, `{"a": "x", "b": "y"}`, `{"a":"x","b":"y"}`},
+		{CodeLikert, `{"min": 1, "max": 5, "labels": {"1": "差", "5": "好"}}`, `{"min":1,"max":5,"labels":{"1":"差","5":"好"}}`},
+		{CodeLikert, `{"min": 5, "max": 5}`, ""},
+		{CodeLikert, `{"min": 0, "max": 11}`, ""},
+		{CodeLikert, `{"min": 1, "max": 5, "labels": {"6": "x"}}`, ""},
+		{CodeLikert, `{"min": 1, "max": 5, "step": 1}`, ""},
+		{CodeFreeText, `{}`, `{}`},
+		{CodeFreeText, `{"min_length": 10, "max_length": 5}`, ""},
+		{CodeFreeText, `{"max_length": -1}`, ""},
+		{CodePairwise, `{"a": "x", "b": "y", "allow_tie": true}`, `{"a":"x","b":"y","allow_tie":true}`},
+		{CodePairwise, `{"a": "x", "b": " "}`, ""},
+		{CodeComparison, `{"responses": [{"model_id": "m1", "content": "x"}, {"model_id": "m2", "content": "y"}]}`,
+			`{"responses":[{"model_id":"m1","content":"x"},{"model_id":"m2","content":"y"}]}`},
+		{CodeComparison, `{"responses": [{"model_id": "m1", "content": "x"}]}`, ""},
+		{CodeComparison, `{"responses": [{"model_id": "m1", "content": "x"}, {"model_id": "m1", "content": "y"}]}`, ""},
+	}
+	for _, tt := range tests {
+		got, err := mustGet(t, tt.code).NormalizeOptions([]byte(tt.raw))
+		if tt.want == "" {
+			if !errors.Is(err, ErrorInvalidOptions) {
+				t.Errorf("kind %s NormalizeOptions(%s) = %q, %v, want ErrorInvalidOptions", tt.code, tt.raw, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("kind %s NormalizeOptions(%s) = %q, %v, want %q", tt.code, tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+func TestNormalizeAnswer(t *testing.T) {
+	const (
+		choice     = `{"a":"x","b":"y","c":"z"}`
+		likert     = `{"min":1,"max":5}`
+		freeText   = `{"min_length":2,"max_length":4}`
+		pairwise   = `{"a":"x","b":"y"}`
+		pairTie    = `{"a":"x","b":"y","allow_tie":true}`
+		comparison = `{"responses":[{"model_id":"m1","content":"x"},{"model_id":"m2","content":"y"}],"allow_tie":true}`
+	)
+	tests := []struct {
+		code    string
+		options string
+		answer  string
+		want    string // 为空时期望ErrorInvalidAnswer
+	}{
+		{CodeSingleChoice, choice, "b", `["b"]`},
+		{CodeSingleChoice, choice, `["b"]`, `["b"]`},
+		{CodeSingleChoice, choice, "a,b", ""},
+		{CodeSingleChoice, choice, "d", ""},
+		{CodeSingleChoice, choice, "", ""},
+		{CodeMultiChoice, choice, "c, a", `["a","c"]`},
+		{CodeMultiChoice, choice, `["c","a"]`, `["a","c"]`},
+		{CodeMultiChoice, choice, "a,a", ""},
+		{CodeMultiChoice, choice, `["a"`, ""},
+		{CodeRanking, choice, "c,a,b", `["c","a","b"]`},
+		{CodeRanking, choice, "c,a", ""},
+		{CodeLikert, likert, "3", `"3"`},
+		{CodeLikert, likert, ` "5" `, `"5"`},
+		{CodeLikert, likert, "0", ""},
+		{CodeLikert, likert, "6", ""},
+		{CodeLikert, likert, "2.5", ""},
+		{CodeFreeText, freeText, "  好的  ", `"好的"`},
+		{CodeFreeText, freeText, "一", ""},
+		{CodeFreeText, freeText, "一二三四五", ""},
+		{CodeFreeText, `{}`, strings.Repeat("字", defaultMaxTextLength), `"` + strings.Repeat("字", defaultMaxTextLength) + `"`},
+		{CodeFreeText, `{}`, strings.Repeat("字", defaultMaxTextLength+1), ""},
+		{CodePairwise, pairwise, " A ", `"a"`},
+		{CodePairwise, pairwise, "tie", ""},
+		{CodePairwise, pairTie, "tie", `"tie"`},
+		{CodePairwise, pairwise, "c", ""},
+		{CodeComparison, comparison, "b", `{"choice":"B","model_id":"m2","order":["m1","m2"]}`},
+		{CodeComparison, comparison, "TIE", `{"choice":"tie","model_id":"","order":["m1","m2"]}`},
+		{CodeComparison, comparison, "C", ""},
+	}
+	for _, tt := range tests {
+		got, err := mustGet(t, tt.code).NormalizeAnswer(tt.options, tt.answer)
+		if tt.want == "" {
+			if !errors.Is(err, ErrorInvalidAnswer) {
+				t.Errorf("kind %s NormalizeAnswer(%q) = %q, %v, want ErrorInvalidAnswer", tt.code, tt.answer, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("kind %s NormalizeAnswer(%q) = %q, %v, want %q", tt.code, tt.answer, got, err, tt.want)
+		}
+	}
+}
+
+func TestMatchGold(t *testing.T) {
+	tests := []struct {
+		code   string
+		answer string
+		gold   string
+		want   bool
+	}{
+		{CodeSingleChoice, `["a"]`, `["a"]`, true},
+		{CodeMultiChoice, `["a","b"]`, `["a"]`, false},
+		{CodeLikert, `"3"`, `"3"`, true},
+		{CodeLikert, `"3"`, `3`, true}, // 早期以数值形式存储的标准答案
+		{CodeLikert, `"3"`, `"4"`, false},
+		{CodePairwise, `"a"`, `"b"`, false},
+		// 不同用户看到的顺序不同，按选择的模型比对
+		{CodeComparison, `{"choice":"A","model_id":"m2","order":["m2","m1"]}`, `{"choice":"B","model_id":"m2","order":["m1","m2"]}`, true},
+		{CodeComparison, `{"choice":"A","model_id":"m1","order":["m1","m2"]}`, `{"choice":"tie","model_id":"","order":["m1","m2"]}`, false},
+	}
+	for _, tt := range tests {
+		if got := MatchGold(mustGet(t, tt.code), tt.answer, tt.gold); got != tt.want {
+			t.Errorf("kind %s MatchGold(%s, %s) = %v, want %v", tt.code, tt.answer, tt.gold, got, tt.want)
+		}
+	}
+}
+
+func TestComparisonDisplayOrder(t *testing.T) {
+	k := mustGet(t, CodeComparison).(PerUserKind)
+	options := `{"responses":[{"model_id":"m1","content":"x"},{"model_id":"m2","content":"y"},{"model_id":"m3","content":"z"}]}`
+	first, err := k.NormalizeAnswerFor(options, "A", 42, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := k.NormalizeAnswerFor(options, "A", 42, 7)
+	if first != again {
+		t.Fatalf("display order changed between calls: %s vs %s", first, again)
+	}
+	// 用户选择的展示位置应换算为该用户看到的对应模型
+	view, err := k.RenderFor(options, 42, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := view.(*ComparisonView).Responses[0].Content
+	model := map[string]string{"x": "m1", "y": "m2", "z": "m3"}[content]
+	if got := AnswerKey(k, first); got != model {
+		t.Fatalf("answer A maps to %s, want %s shown at A", got, model)
+	}
+}
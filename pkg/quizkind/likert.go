@@ -0,0 +1,82 @@
+package quizkind
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxLikertPoints 量表最多的刻度数
+const maxLikertPoints = 11
+
+// LikertOptions 李克特量表题的选项：作答为[Min, Max]内的整数，Labels为部分刻度的文字描述
+type LikertOptions struct {
+	Min    int               `json:"min"`
+	Max    int               `json:"max"`
+	Labels map[string]string `json:"labels,omitempty"` // 刻度值 -> 描述，如{"1":"非常不同意","5":"非常同意"}
+}
+
+type likertKind struct{}
+
+func (likertKind) Code() string { return CodeLikert }
+
+func (likertKind) Name() string { return "likert" }
+
+func (likertKind) NormalizeOptions(raw []byte) (string, error) {
+	var o LikertOptions
+	if err := decodeStrict(raw, &o); err != nil {
+		return "", err
+	}
+	if o.Max <= o.Min || o.Max-o.Min+1 > maxLikertPoints {
+		return "", fmt.Errorf("%w: 量表需满足min<max且刻度数不超过%d", ErrorInvalidOptions, maxLikertPoints)
+	}
+	for key := range o.Labels {
+		v, err := strconv.Atoi(key)
+		if err != nil || v < o.Min || v > o.Max {
+			return "", fmt.Errorf("%w: 刻度描述%q不在量表范围内", ErrorInvalidOptions, key)
+		}
+	}
+	return marshalString(o)
+}
+
+func (likertKind) Render(options string) (interface{}, error) {
+	var o LikertOptions
+	if err := json.Unmarshal([]byte(options), &o); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// likertValue 解析刻度值，兼容JSON字符串"3"与数值3两种写法
+func likertValue(answer string) (int, error) {
+	answer = strings.TrimSpace(answer)
+	var str string
+	if json.Unmarshal([]byte(answer), &str) == nil {
+		answer = strings.TrimSpace(str)
+	}
+	return strconv.Atoi(answer)
+}
+
+// NormalizeAnswer 与其他单值题型一致，规范形式为刻度值的JSON字符串，如"3"
+func (likertKind) NormalizeAnswer(options, answer string) (string, error) {
+	var o LikertOptions
+	if err := json.Unmarshal([]byte(options), &o); err != nil {
+		return "", err
+	}
+	v, err := likertValue(answer)
+	if err != nil || v < o.Min || v > o.Max {
+		return "", fmt.Errorf("%w: 需为%d到%d之间的整数", ErrorInvalidAnswer, o.Min, o.Max)
+	}
+	return marshalString(strconv.Itoa(v))
+}
+
+// AnswerKey 早期的答案以数值3的形式存储，统一为JSON字符串后比对
+func (likertKind) AnswerKey(answer string) string {
+	v, err := likertValue(answer)
+	if err != nil {
+		return answer
+	}
+	key, _ := marshalString(strconv.Itoa(v))
+	return key
+}
@@ -0,0 +1,64 @@
+package quizkind
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// 两两比较题的答案
+const (
+	PairwiseA   = "a"
+	PairwiseB   = "b"
+	PairwiseTie = "tie"
+)
+
+// PairwiseOptions 两两比较题的选项：在A、B两个候选中选出更好的一个
+type PairwiseOptions struct {
+	A        string `json:"a"`
+	B        string `json:"b"`
+	AllowTie bool   `json:"allow_tie,omitempty"` // 是否允许选择"一样好"
+}
+
+type pairwiseKind struct{}
+
+func (pairwiseKind) Code() string { return CodePairwise }
+
+func (pairwiseKind) Name() string { return "pairwise" }
+
+func (pairwiseKind) NormalizeOptions(raw []byte) (string, error) {
+	var o PairwiseOptions
+	if err := decodeStrict(raw, &o); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(o.A) == "" || strings.TrimSpace(o.B) == "" {
+		return "", fmt.Errorf("%w: 两个候选内容均不能为空", ErrorInvalidOptions)
+	}
+	return marshalString(o)
+}
+
+func (pairwiseKind) Render(options string) (interface{}, error) {
+	var o PairwiseOptions
+	if err := json.Unmarshal([]byte(options), &o); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// NormalizeAnswer 规范形式为JSON字符串"a"、"b"或"tie"
+func (pairwiseKind) NormalizeAnswer(options, answer string) (string, error) {
+	var o PairwiseOptions
+	if err := json.Unmarshal([]byte(options), &o); err != nil {
+		return "", err
+	}
+	switch answer = strings.ToLower(strings.TrimSpace(answer)); answer {
+	case PairwiseA, PairwiseB:
+	case PairwiseTie:
+		if !o.AllowTie {
+			return "", fmt.Errorf("%w: 该题不允许选择一样好", ErrorInvalidAnswer)
+		}
+	default:
+		return "", fmt.Errorf("%w: 需为a、b或tie", ErrorInvalidAnswer)
+	}
+	return marshalString(answer)
+}
@@ -0,0 +1,232 @@
+package rating
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// rating 由两两比较结果计算模型排名：胜率、Elo、Bradley–Terry，以及bootstrap置信区间
+
+// Comparison 一次两两比较的结果
+type Comparison struct {
+	A      string
+	B      string
+	Score  float64 // A的得分：1为A胜，0为B胜，0.5为平局
+	Weight float64 // 该次比较的权重(如标注员的信任分)，0视为1；胜负统计不加权
+}
+
+func (c *Comparison) weight() float64 {
+	if c.Weight <= 0 {
+		return 1
+	}
+	return c.Weight
+}
+
+// WinStat 模型的胜负统计
+type WinStat struct {
+	Wins    int     `json:"wins"`
+	Losses  int     `json:"losses"`
+	Ties    int     `json:"ties"`
+	Games   int     `json:"games"`
+	WinRate float64 `json:"win_rate"` // 平局按半场胜利计
+}
+
+// WinRates 统计每个模型的胜负
+func WinRates(comps []Comparison) map[string]*WinStat {
+	stats := make(map[string]*WinStat)
+	get := func(model string) *WinStat {
+		s, ok := stats[model]
+		if !ok {
+			s = new(WinStat)
+			stats[model] = s
+		}
+		return s
+	}
+	for _, c := range comps {
+		a, b := get(c.A), get(c.B)
+		a.Games++
+		b.Games++
+		switch {
+		case c.Score > 0.5:
+			a.Wins++
+			b.Losses++
+		case c.Score < 0.5:
+			a.Losses++
+			b.Wins++
+		default:
+			a.Ties++
+			b.Ties++
+		}
+	}
+	for _, s := range stats {
+		if s.Games > 0 {
+			s.WinRate = (float64(s.Wins) + 0.5*float64(s.Ties)) / float64(s.Games)
+		}
+	}
+	return stats
+}
+
+// 分数的刻度与Elo一致：相差400分时胜率约为10:1
+const (
+	InitialRating = 1000.0
+	ratingScale   = 400.0
+)
+
+// Elo 按比较发生的顺序依次更新Elo分数，k为每场比较的最大调整幅度，按比较的权重缩放
+func Elo(comps []Comparison, k float64) map[string]float64 {
+	ratings := make(map[string]float64)
+	get := func(model string) float64 {
+		r, ok := ratings[model]
+		if !ok {
+			r = InitialRating
+			ratings[model] = r
+		}
+		return r
+	}
+	for _, c := range comps {
+		ra, rb := get(c.A), get(c.B)
+		expected := 1 / (1 + math.Pow(10, (rb-ra)/ratingScale))
+		delta := k * c.weight() * (c.Score - expected)
+		ratings[c.A] = ra + delta
+		ratings[c.B] = rb - delta
+	}
+	return ratings
+}
+
+// BradleyTerry 用MM算法拟合Bradley–Terry模型，平局按双方各胜半场计，每次比较按其权重计场次，结果换算为Elo刻度
+// 与Elo不同，结果与比较发生的顺序无关
+func BradleyTerry(comps []Comparison, iterations int) map[string]float64 {
+	models := make(map[string]int)
+	var names []string
+	for _, c := range comps {
+		for _, m := range []string{c.A, c.B} {
+			if _, ok := models[m]; !ok {
+				models[m] = len(names)
+				names = append(names, m)
+			}
+		}
+	}
+	n := len(names)
+	if n == 0 {
+		return map[string]float64{}
+	}
+	wins := make([]float64, n)
+	games := make([][]float64, n) // games[i][j] i与j之间的比较次数(按权重计)
+	for i := range games {
+		games[i] = make([]float64, n)
+	}
+	for _, c := range comps {
+		i, j := models[c.A], models[c.B]
+		w := c.weight()
+		wins[i] += w * c.Score
+		wins[j] += w * (1 - c.Score)
+		games[i][j] += w
+		games[j][i] += w
+	}
+	// 为保证全胜或全负的模型也有有限的分数，给每对模型加上一场虚拟平局作为先验
+	const prior = 0.5
+	strength := make([]float64, n)
+	for i := range strength {
+		strength[i] = 1
+	}
+	next := make([]float64, n)
+	for iter := 0; iter < iterations; iter++ {
+		for i := 0; i < n; i++ {
+			num := wins[i] + prior*float64(n-1)
+			den := 0.0
+			for j := 0; j < n; j++ {
+				if i != j {
+					den += (games[i][j] + 2*prior) / (strength[i] + strength[j])
+				}
+			}
+			next[i] = num / den
+		}
+		// 归一化使几何平均为1，即平均分为InitialRating
+		logSum := 0.0
+		for i := range next {
+			logSum += math.Log(next[i])
+		}
+		mean := math.Exp(logSum / float64(n))
+		maxDiff := 0.0
+		for i := range next {
+			v := next[i] / mean
+			maxDiff = math.Max(maxDiff, math.Abs(v-strength[i]))
+			strength[i] = v
+		}
+		if maxDiff < 1e-9 {
+			break
+		}
+	}
+	ratings := make(map[string]float64, n)
+	for i, name := range names {
+		ratings[name] = InitialRating + ratingScale*math.Log10(strength[i])
+	}
+	return ratings
+}
+
+// Interval 置信区间
+type Interval struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+// Flatten 将按作答记录分组的比较结果合并为一个切片
+func Flatten(groups [][]Comparison) []Comparison {
+	n := 0
+	for _, g := range groups {
+		n += len(g)
+	}
+	comps := make([]Comparison, 0, n)
+	for _, g := range groups {
+		comps = append(comps, g...)
+	}
+	return comps
+}
+
+// Bootstrap 以作答记录为单位有放回地重采样rounds次，每次用fn计算分数，返回每个模型分数的置信区间
+// groups中每组为同一条作答记录展开的两两比较，彼此相关，需整组重采样，否则置信区间偏窄
+// confidence为置信水平，如0.95；同一seed下结果可复现
+func Bootstrap(groups [][]Comparison, rounds int, confidence float64, seed int64,
+	fn func(comps []Comparison) map[string]float64) map[string]Interval {
+	samples := make(map[string][]float64)
+	if len(groups) == 0 || rounds <= 0 {
+		return map[string]Interval{}
+	}
+	r := rand.New(rand.NewSource(seed))
+	n := 0
+	for _, g := range groups {
+		n += len(g)
+	}
+	resampled := make([]Comparison, 0, n)
+	for round := 0; round < rounds; round++ {
+		resampled = resampled[:0]
+		for range groups {
+			resampled = append(resampled, groups[r.Intn(len(groups))]...)
+		}
+		for model, score := range fn(resampled) {
+			samples[model] = append(samples[model], score)
+		}
+	}
+	alpha := (1 - confidence) / 2
+	intervals := make(map[string]Interval, len(samples))
+	for model, s := range samples {
+		sort.Float64s(s)
+		intervals[model] = Interval{Lower: quantile(s, alpha), Upper: quantile(s, 1-alpha)}
+	}
+	return intervals
+}
+
+// quantile 已排序样本的分位数(线性插值)
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	if lo == hi {
+		return sorted[lo]
+	}
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
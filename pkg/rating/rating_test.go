@@ -0,0 +1,156 @@
+package rating
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestWinRates(t *testing.T) {
+	stats := WinRates([]Comparison{
+		{A: "a", B: "b", Score: 1},
+		{A: "b", B: "a", Score: 1},
+		{A: "a", B: "c", Score: 0.5},
+		{A: "c", B: "a", Score: 0, Weight: 5}, // 胜负统计不加权
+	})
+	a := stats["a"]
+	if a.Wins != 2 || a.Losses != 1 || a.Ties != 1 || a.Games != 4 || !approx(a.WinRate, 2.5/4) {
+		t.Fatalf("a = %+v", a)
+	}
+	if c := stats["c"]; c.Losses != 1 || c.Ties != 1 || !approx(c.WinRate, 0.25) {
+		t.Fatalf("c = %+v", c)
+	}
+}
+
+func TestElo(t *testing.T) {
+	// 同分时期望得分为0.5，胜者加k/2
+	got := Elo([]Comparison{{A: "a", B: "b", Score: 1}}, 4)
+	if !approx(got["a"], 1002) || !approx(got["b"], 998) {
+		t.Fatalf("after one game = %v", got)
+	}
+	// 第二场a再胜，期望得分为1/(1+10^(-4/400))，权重2使调整幅度加倍
+	got = Elo([]Comparison{{A: "a", B: "b", Score: 1}, {A: "b", B: "a", Score: 0, Weight: 2}}, 4)
+	expected := 1 / (1 + math.Pow(10, 4.0/400))
+	if want := 998 - 4*2*expected; !approx(got["b"], want) {
+		t.Fatalf("b after two games = %v, want %v", got["b"], want)
+	}
+	if !approx(got["a"]+got["b"], 2*InitialRating) {
+		t.Fatalf("Elo is not zero-sum: %v", got)
+	}
+	// 平局不改变同分双方的分数
+	got = Elo([]Comparison{{A: "a", B: "b", Score: 0.5}}, 4)
+	if !approx(got["a"], InitialRating) || !approx(got["b"], InitialRating) {
+		t.Fatalf("after a tie = %v", got)
+	}
+}
+
+func TestBradleyTerry(t *testing.T) {
+	if got := BradleyTerry(nil, 10); len(got) != 0 {
+		t.Fatalf("BradleyTerry(nil) = %v, want empty", got)
+	}
+	// 两个模型时不动点为 s_a/s_b = (胜场a+0.5)/(胜场b+0.5)，几何平均归一化为1
+	comps := []Comparison{
+		{A: "a", B: "b", Score: 1},
+		{A: "a", B: "b", Score: 1},
+		{A: "b", B: "a", Score: 0},
+		{A: "b", B: "a", Score: 1},
+	}
+	got := BradleyTerry(comps, 200)
+	diff := ratingScale * math.Log10(3.5/1.5)
+	if !approx(got["a"], InitialRating+diff/2) || !approx(got["b"], InitialRating-diff/2) {
+		t.Fatalf("BradleyTerry = %v, want a-b = %.4f around %v", got, diff, InitialRating)
+	}
+	// 与比较的顺序无关
+	reversed := make([]Comparison, len(comps))
+	for i, c := range comps {
+		reversed[len(comps)-1-i] = c
+	}
+	again := BradleyTerry(reversed, 200)
+	for m := range got {
+		if !approx(got[m], again[m]) {
+			t.Fatalf("BradleyTerry depends on order: %v vs %v", got, again)
+		}
+	}
+	// 权重等价于重复比较
+	weighted := BradleyTerry([]Comparison{{A: "a", B: "b", Score: 1, Weight: 3}, {A: "b", B: "a", Score: 1}}, 200)
+	if !approx(weighted["a"], got["a"]) {
+		t.Fatalf("weighted = %v, want %v", weighted, got)
+	}
+	// 全胜的模型分数仍有限
+	all := BradleyTerry([]Comparison{{A: "a", B: "b", Score: 1}, {A: "a", B: "c", Score: 1}}, 200)
+	if math.IsInf(all["a"], 0) || math.IsNaN(all["a"]) || all["a"] <= all["b"] {
+		t.Fatalf("undefeated = %v", all)
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	if got := Bootstrap(nil, 100, 0.95, 1, func([]Comparison) map[string]float64 { return nil }); len(got) != 0 {
+		t.Fatalf("Bootstrap(nil) = %v, want empty", got)
+	}
+	groups := make([][]Comparison, 0, 40)
+	for i := 0; i < 40; i++ {
+		groups = append(groups, []Comparison{{A: "a", B: "b", Score: float64(i % 4 / 3)}}) // a胜1/4
+	}
+	comps := Flatten(groups)
+	fn := func(c []Comparison) map[string]float64 { return BradleyTerry(c, 100) }
+	ci := Bootstrap(groups, 200, 0.95, 1, fn)
+	if again := Bootstrap(groups, 200, 0.95, 1, fn); !reflect.DeepEqual(ci, again) {
+		t.Fatalf("same seed gave different intervals: %v vs %v", ci, again)
+	}
+	point := fn(comps)
+	for _, m := range []string{"a", "b"} {
+		if ci[m].Lower > point[m] || ci[m].Upper < point[m] || ci[m].Lower >= ci[m].Upper {
+			t.Fatalf("%s interval %+v does not contain %v", m, ci[m], point[m])
+		}
+	}
+	// 置信水平越高区间越宽
+	wide := Bootstrap(groups, 200, 0.99, 1, fn)
+	if wide["a"].Upper-wide["a"].Lower < ci["a"].Upper-ci["a"].Lower {
+		t.Fatalf("99%% interval %+v narrower than 95%% %+v", wide["a"], ci["a"])
+	}
+}
+
+// TestBootstrapRecords 三个模型的作答记录展开为两次比较，重采样时整条记录一起抽取
+func TestBootstrapRecords(t *testing.T) {
+	groups := [][]Comparison{
+		{{A: "a", B: "b", Score: 1}, {A: "a", B: "c", Score: 1}},
+		{{A: "b", B: "a", Score: 1}, {A: "b", B: "c", Score: 1}},
+		{{A: "c", B: "a", Score: 1}, {A: "c", B: "b", Score: 1}},
+		{{A: "a", B: "b", Score: 1}, {A: "a", B: "c", Score: 1}},
+	}
+	rounds := 0
+	Bootstrap(groups, 50, 0.95, 1, func(c []Comparison) map[string]float64 {
+		rounds++
+		if len(c) != 2*len(groups) {
+			t.Fatalf("resample has %d comparisons, want %d", len(c), 2*len(groups))
+		}
+		for i := 0; i < len(c); i += 2 {
+			if c[i].A != c[i+1].A || c[i].B == c[i+1].B {
+				t.Fatalf("comparisons %+v and %+v are not from the same record", c[i], c[i+1])
+			}
+		}
+		return BradleyTerry(c, 50)
+	})
+	if rounds != 50 {
+		t.Fatalf("fn called %d times, want 50", rounds)
+	}
+	if got := Flatten(groups); len(got) != 8 || got[2] != groups[1][0] {
+		t.Fatalf("Flatten = %v", got)
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	s := []float64{1, 2, 3, 4}
+	for q, want := range map[float64]float64{0: 1, 0.5: 2.5, 1: 4, 1.0 / 3: 2} {
+		if got := quantile(s, q); !approx(got, want) {
+			t.Errorf("quantile(%v) = %v, want %v", q, got, want)
+		}
+	}
+	if got := quantile(nil, 0.5); got != 0 {
+		t.Errorf("quantile(nil) = %v, want 0", got)
+	}
+}
@@ -0,0 +1,87 @@
+package trust
+
+// trust 标注员信任分：由金标准题正确率、与其他标注员多数答案的一致率以及提交间隔综合得出
+
+// Evidence 计算信任分所需的统计
+type Evidence struct {
+	GoldAnswered      int64 // 作答的金标准题数
+	GoldCorrect       int64 // 答对的金标准题数
+	ConsensusAnswered int64 // 可与其他标注员多数答案比对的作答数
+	ConsensusAgreed   int64 // 与多数答案一致的作答数
+	Submissions       int64 // 提交总数
+	FastSubmissions   int64 // 与上一次提交间隔过短的提交数
+}
+
+// Amount 可用于判断作答质量的证据数
+func (e *Evidence) Amount() int64 {
+	return e.GoldAnswered + e.ConsensusAnswered
+}
+
+// Params 各项的权重，以及平滑用的满分虚拟样本数
+type Params struct {
+	GoldWeight      float64
+	AgreementWeight float64
+	TimingWeight    float64
+	PriorWeight     float64
+}
+
+// Score 信任分，取值[0, 1]
+// 每项比率都加上PriorWeight个满分虚拟样本做平滑，证据越少越接近1，避免新用户因少量失误被误判
+func Score(e *Evidence, p Params) float64 {
+	total := p.GoldWeight + p.AgreementWeight + p.TimingWeight
+	if total <= 0 {
+		return 1
+	}
+	score := p.GoldWeight*smoothed(e.GoldCorrect, e.GoldAnswered, p.PriorWeight) +
+		p.AgreementWeight*smoothed(e.ConsensusAgreed, e.ConsensusAnswered, p.PriorWeight) +
+		p.TimingWeight*smoothed(e.Submissions-e.FastSubmissions, e.Submissions, p.PriorWeight)
+	return score / total
+}
+
+func smoothed(hit, total int64, prior float64) float64 {
+	if float64(total)+prior <= 0 {
+		return 1
+	}
+	return (float64(hit) + prior) / (float64(total) + prior)
+}
+
+// 与多数答案比对的结果
+const (
+	NoConsensus = iota // 其他标注员不足两人或多数答案并列，不参与比对
+	Agreed
+	Disagreed
+)
+
+// LeaveOneOut 将同一道题的每个答案与其余标注员的多数答案比对，keys为各标注员答案的比对键
+func LeaveOneOut(keys []string) []int {
+	counts := make(map[string]int, len(keys))
+	for _, k := range keys {
+		counts[k]++
+	}
+	res := make([]int, len(keys))
+	if len(keys) < 3 {
+		return res
+	}
+	for i, k := range keys {
+		counts[k]--
+		top, best, unique := "", 0, false
+		for key, n := range counts {
+			switch {
+			case n > best:
+				top, best, unique = key, n, true
+			case n == best:
+				unique = false
+			}
+		}
+		counts[k]++
+		if !unique {
+			continue
+		}
+		if top == k {
+			res[i] = Agreed
+		} else {
+			res[i] = Disagreed
+		}
+	}
+	return res
+}
@@ -0,0 +1,59 @@
+package trust
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	p := Params{GoldWeight: 2, AgreementWeight: 1, TimingWeight: 1, PriorWeight: 5}
+	tests := []struct {
+		name string
+		e    Evidence
+		p    Params
+		want float64
+	}{
+		{"no evidence", Evidence{}, p, 1},
+		{"no weights", Evidence{GoldAnswered: 10}, Params{}, 1},
+		{"perfect", Evidence{GoldAnswered: 10, GoldCorrect: 10, ConsensusAnswered: 10, ConsensusAgreed: 10, Submissions: 20}, p, 1},
+		// 金标准题 (0+5)/(10+5)，一致率 (5+5)/(10+5)，提交间隔 (20-10+5)/(20+5)，按2:1:1加权
+		{"mixed", Evidence{GoldAnswered: 10, ConsensusAnswered: 10, ConsensusAgreed: 5, Submissions: 20, FastSubmissions: 10}, p,
+			(2*5.0/15 + 10.0/15 + 15.0/25) / 4},
+		// 没有虚拟样本时为原始比率
+		{"no prior", Evidence{GoldAnswered: 4, GoldCorrect: 1}, Params{GoldWeight: 1}, 0.25},
+	}
+	for _, tt := range tests {
+		if got := Score(&tt.e, tt.p); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%s: Score = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	// 证据越多，同样的比率越远离1
+	few := Score(&Evidence{GoldAnswered: 2, GoldCorrect: 1}, p)
+	many := Score(&Evidence{GoldAnswered: 200, GoldCorrect: 100}, p)
+	if !(many < few && few < 1) {
+		t.Errorf("Score with more evidence = %v, want below %v", many, few)
+	}
+}
+
+func TestLeaveOneOut(t *testing.T) {
+	tests := []struct {
+		keys []string
+		want []int
+	}{
+		{nil, []int{}},
+		// 其他标注员不足两人
+		{[]string{"a", "b"}, []int{NoConsensus, NoConsensus}},
+		{[]string{"a", "a", "b"}, []int{NoConsensus, NoConsensus, Disagreed}},
+		{[]string{"a", "a", "a"}, []int{Agreed, Agreed, Agreed}},
+		{[]string{"a", "a", "a", "b"}, []int{Agreed, Agreed, Agreed, Disagreed}},
+		// 去掉自己后其余答案并列时不参与比对
+		{[]string{"a", "b", "c"}, []int{NoConsensus, NoConsensus, NoConsensus}},
+		{[]string{"a", "a", "b", "b"}, []int{Disagreed, Disagreed, Disagreed, Disagreed}},
+	}
+	for _, tt := range tests {
+		if got := LeaveOneOut(tt.keys); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("LeaveOneOut(%v) = %v, want %v", tt.keys, got, tt.want)
+		}
+	}
+}
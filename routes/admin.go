@@ -0,0 +1,46 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"scgptEval/controllers"
+	"scgptEval/middlewares"
+	"scgptEval/models"
+)
+
+func InitAdmin(admin *gin.RouterGroup) {
+	admin.Use(middlewares.JWTAuthMiddleware(), middlewares.RequireRole(models.RoleAdmin))
+	admin.POST("/user/role", controllers.SetUserRole)
+	admin.POST("/user/status", controllers.SetUserStatus)
+
+	admin.GET("/trust/list", controllers.ListUserTrusts)
+	admin.POST("/trust/recompute", controllers.RecomputeTrust)
+
+	admin.GET("/quiz/list", controllers.ListQuizzes)
+	admin.POST("/quiz/create", controllers.CreateQuiz)
+	admin.POST("/quiz/update", controllers.UpdateQuiz)
+	admin.POST("/quiz/retire", controllers.RetireQuiz)
+	admin.POST("/quiz/import", controllers.ImportQuizzes)
+	admin.POST("/quiz/sync_cache", controllers.SyncQuizBase)
+
+	admin.GET("/campaign/list", controllers.ListCampaigns)
+	admin.POST("/campaign/create", controllers.CreateCampaign)
+	admin.POST("/campaign/update", controllers.UpdateCampaign)
+	admin.POST("/campaign/enroll", controllers.EnrollUsers)
+	admin.POST("/campaign/unenroll", controllers.UnenrollUser)
+	admin.POST("/campaign/quizzes", controllers.AssignQuizzes)
+
+	admin.POST("/ranking/archive", controllers.ArchiveRankings)
+
+	admin.GET("/gold/accuracy", controllers.ListGoldAccuracy)
+
+	admin.GET("/agreement/summary", controllers.GetAgreementSummary)
+	admin.GET("/agreement/quizzes", controllers.ListQuizAgreement)
+	admin.GET("/consensus/list", controllers.ListConsensusLabels)
+	admin.POST("/consensus/recompute", controllers.RecomputeConsensus)
+
+	admin.GET("/model/list", controllers.ListEvalModels)
+	admin.POST("/model/create", controllers.CreateEvalModel)
+	admin.POST("/model/recompute", controllers.RecomputeModelLeaderboard)
+
+	admin.GET("/record/export", controllers.ExportRecords)
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"scgptEval/controllers"
+	"scgptEval/middlewares"
+)
+
+func InitModel(model *gin.RouterGroup) {
+	model.Use(middlewares.JWTAuthMiddleware())
+	model.GET("/leaderboard", controllers.GetModelLeaderboard)
+}
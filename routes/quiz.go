@@ -10,7 +10,10 @@ func InitQuiz(quiz *gin.RouterGroup) {
 	quiz.Use(middlewares.JWTAuthMiddleware())
 	quiz.GET("/get_amount", controllers.GetUserAmount)
 	quiz.GET("/get_ranking", controllers.GetRanking)
+	quiz.GET("/history", controllers.GetHistory)
+	quiz.GET("/history/detail", controllers.GetHistoryDetail)
 
 	quiz.POST("/submit_quiz", controllers.SubmitQuiz)
+	quiz.POST("/revise_answer", controllers.ReviseAnswer)
 	quiz.POST("/get_quizzes", controllers.GetUntriedQuizzes)
 }
@@ -17,6 +17,12 @@ func SetUp() *gin.Engine {
 	quiz := r.Group("/quiz")
 	InitQuiz(quiz)
 
+	model := r.Group("/models")
+	InitModel(model)
+
+	admin := r.Group("/admin")
+	InitAdmin(admin)
+
 	r.GET("/", func(c *gin.Context) {
 		c.String(http.StatusOK, "ok")
 	})
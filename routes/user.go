@@ -9,8 +9,12 @@ import (
 func InitUser(user *gin.RouterGroup) {
 	user.POST("/signup", controllers.SignUp)
 	user.POST("/login", controllers.LogIn)
-	// access token过期时刷新，需要携带过期token
+	// access token过期时刷新，每次刷新都会轮换refresh token
 	user.GET("/refresh_token", controllers.RefreshTokenHandler)
 	user.Use(middlewares.JWTAuthMiddleware())
 	user.POST("/change_name", controllers.ChangeName)
+	user.POST("/logout", controllers.LogOut)
+	user.GET("/sessions", controllers.GetSessions)
+	user.POST("/sessions/revoke", controllers.RevokeSession)
+	user.GET("/campaigns", controllers.ListUserCampaigns)
 }